@@ -1,16 +1,33 @@
 package simpleeventworker
 
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+)
+
 const (
 	AccountStatusOutstanding = "Outstanding"
 	AccountStatusRecalled    = "Recalled"
 	AccountStatusSettled     = "Settled"
 	AccountStatusOverpaid    = "Overpaid"
+	// AccountStatusWrittenOff is the status of a recalled account whose debt was written off. See writeoff.go.
+	AccountStatusWrittenOff = "WrittenOff"
 )
 
 type Account struct {
 	ID      string
 	status  string
 	balance int
+	// items are the open items of the account, oldest first, and credit its payments not allocated to any of them.
+	// The balance is always the sum of their remaining amounts minus the credit. See invoice.go.
+	items  []OpenItem
+	credit int
+	// writtenOff is the debt moved out of the balance when the account was written off, and recovered the part of it
+	// paid since.
+	writtenOff int
+	recovered  int
 }
 
 func NewAccount(id string, balance int) *Account {
@@ -28,14 +45,20 @@ func NewAccount(id string, balance int) *Account {
 // At the time of writing, both charges and payments are positive integers from input.
 // Ensure `amount` is positive for charges and negative for payments.
 // The account's status is updated based on the new balance.
-// Returns an error if the account is already recalled.
+// Returns an error if the account is already recalled or written off.
+//
+// A charge is an undated open item without an invoice, and a payment is allocated to the oldest open items first.
+// See RecordCharge and RecordPayment.
 func (a *Account) RecordTransaction(amount int) error {
-	if a.IsRecalled() {
-		return &ErrCannotTransactWithRecalledAccount{AccountID: a.ID}
+	if amount < 0 {
+		return a.RecordPayment("", -amount)
 	}
 
-	a.balance += amount
+	return a.RecordCharge("", amount, time.Time{})
+}
 
+// updateStatus sets the status of the account from its balance.
+func (a *Account) updateStatus() {
 	if a.balance == 0 {
 		a.status = AccountStatusSettled
 	} else if a.balance < 0 {
@@ -43,8 +66,6 @@ func (a *Account) RecordTransaction(amount int) error {
 	} else {
 		a.status = AccountStatusOutstanding
 	}
-
-	return nil
 }
 
 func (a *Account) Status() string {
@@ -59,6 +80,131 @@ func (a *Account) IsRecalled() bool {
 	return a.status == AccountStatusRecalled
 }
 
+// Recall freezes the account. A written-off account was recalled already, and stays written off.
 func (a *Account) Recall() {
+	if a.IsWrittenOff() {
+		return
+	}
 	a.status = AccountStatusRecalled
 }
+
+// accountJSON is the JSON representation of an Account, as in snapshots. It holds the open items and credit of the
+// account, unless they are the ones NewAccount makes of its balance, e.g. for accounts without charges.
+type accountJSON struct {
+	ID         string         `json:"ID"`
+	Status     string         `json:"Status"`
+	Balance    int            `json:"Balance"`
+	OpenItems  []openItemJSON `json:"OpenItems,omitempty"`
+	Credit     int            `json:"Credit,omitempty"`
+	WrittenOff int            `json:"WrittenOff,omitempty"`
+	Recovered  int            `json:"Recovered,omitempty"`
+}
+
+type openItemJSON struct {
+	Invoice   string     `json:"Invoice,omitempty"`
+	Amount    int        `json:"Amount"`
+	Remaining int        `json:"Remaining"`
+	ChargedAt *time.Time `json:"ChargedAt,omitempty"`
+}
+
+func newAccountJSON(a Account) accountJSON {
+	j := accountJSON{ID: a.ID, Status: a.status, Balance: a.balance, WrittenOff: a.writtenOff, Recovered: a.recovered}
+
+	implied := NewAccount(a.ID, a.balance)
+	if a.credit == implied.credit && slices.Equal(a.items, implied.items) {
+		return j
+	}
+
+	j.Credit = a.credit
+	for _, item := range a.items {
+		i := openItemJSON{Invoice: item.Invoice, Amount: item.Amount, Remaining: item.Remaining}
+		if !item.ChargedAt.IsZero() {
+			i.ChargedAt = &item.ChargedAt
+		}
+		j.OpenItems = append(j.OpenItems, i)
+	}
+
+	return j
+}
+
+// account rebuilds the Account. Its status must agree with its balance, unless it is recalled or written off.
+// Only a written-off account has written-off and recovered amounts, and never a positive balance.
+// Its open items and credit, if any, must add up to its balance.
+func (a accountJSON) account() (Account, *ErrInvalidAccount) {
+	if a.ID == "" {
+		return Account{}, &ErrInvalidAccount{AccountID: a.ID, Reason: "empty ID"}
+	}
+
+	account := NewAccount(a.ID, a.Balance)
+	if a.Status == AccountStatusWrittenOff {
+		if a.Balance > 0 {
+			return Account{}, &ErrInvalidAccount{AccountID: a.ID, Reason: fmt.Sprintf("written off with balance %d", a.Balance)}
+		}
+		if a.WrittenOff < 0 || a.Recovered < 0 || a.Recovered > a.WrittenOff {
+			return Account{}, &ErrInvalidAccount{AccountID: a.ID, Reason: fmt.Sprintf("recovered %d of written-off %d", a.Recovered, a.WrittenOff)}
+		}
+		account.status = AccountStatusWrittenOff
+		account.writtenOff = a.WrittenOff
+		account.recovered = a.Recovered
+	} else if a.WrittenOff != 0 || a.Recovered != 0 {
+		return Account{}, &ErrInvalidAccount{AccountID: a.ID, Reason: fmt.Sprintf(`written-off amount of account with status "%s"`, a.Status)}
+	} else if a.Status == AccountStatusRecalled {
+		account.Recall()
+	} else if a.Status != account.Status() {
+		return Account{}, &ErrInvalidAccount{AccountID: a.ID, Reason: fmt.Sprintf(`status "%s" does not match balance %d`, a.Status, a.Balance)}
+	}
+
+	if len(a.OpenItems) == 0 && a.Credit == 0 {
+		return *account, nil
+	}
+	if a.Credit < 0 {
+		return Account{}, &ErrInvalidAccount{AccountID: a.ID, Reason: fmt.Sprintf("negative credit %d", a.Credit)}
+	}
+	if len(a.OpenItems) > 0 && a.Credit > 0 {
+		return Account{}, &ErrInvalidAccount{AccountID: a.ID, Reason: fmt.Sprintf("open items along with credit %d", a.Credit)}
+	}
+	var items []OpenItem
+	total := -a.Credit
+	for _, i := range a.OpenItems {
+		if i.Remaining <= 0 || i.Remaining > i.Amount {
+			return Account{}, &ErrInvalidAccount{AccountID: a.ID, Reason: fmt.Sprintf("open item with remaining amount %d of %d", i.Remaining, i.Amount)}
+		}
+		item := OpenItem{Invoice: i.Invoice, Amount: i.Amount, Remaining: i.Remaining}
+		if i.ChargedAt != nil {
+			item.ChargedAt = *i.ChargedAt
+		}
+		items = append(items, item)
+		total += i.Remaining
+	}
+	if total != a.Balance {
+		return Account{}, &ErrInvalidAccount{AccountID: a.ID, Reason: fmt.Sprintf("open items and credit add up to %d, not balance %d", total, a.Balance)}
+	}
+	account.items = items
+	account.credit = a.Credit
+
+	return *account, nil
+}
+
+// MarshalJSON encodes the account as {"ID":..., "Status":..., "Balance":...}, with its "OpenItems" and "Credit" unless
+// NewAccount makes them of the balance, and its "WrittenOff" and "Recovered" amounts if it is written off.
+func (a Account) MarshalJSON() ([]byte, error) {
+	return json.Marshal(newAccountJSON(a))
+}
+
+// UnmarshalJSON decodes an account encoded by MarshalJSON.
+// It is rejected with ErrInvalidAccount if its ID is empty, or its status, open items or credit do not agree with its
+// balance.
+func (a *Account) UnmarshalJSON(data []byte) error {
+	aux := accountJSON{}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	account, err := aux.account()
+	if err != nil {
+		return err
+	}
+	*a = account
+
+	return nil
+}
@@ -1,12 +1,25 @@
 package simpleeventworker_test
 
 import (
+	"encoding/json"
 	"testing"
+	"time"
 
 	event "github.com/nogurenn/assorted-programs/simple-event-worker"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+// accountFromJSON builds an account with the open items and credit of its JSON representation.
+func accountFromJSON(t *testing.T, data string) *event.Account {
+	t.Helper()
+
+	account := &event.Account{}
+	require.NoError(t, json.Unmarshal([]byte(data), account))
+
+	return account
+}
+
 func TestAccount_NewAccount(t *testing.T) {
 	subtests := []struct {
 		name    string
@@ -85,9 +98,8 @@ func TestAccount_RecordTransaction_Success(t *testing.T) {
 			name:         "OverpaidToOutstanding",
 			startBalance: -100,
 			amount:       101,
-			want: func() *event.Account {
-				return event.NewAccount("Jack", 1)
-			}(),
+			// The credit pays the charge first.
+			want: accountFromJSON(t, `{"ID":"Jack","Status":"Outstanding","Balance":1,"OpenItems":[{"Amount":101,"Remaining":1}]}`),
 		},
 		{
 			name:         "OverpaidToSettled",
@@ -101,9 +113,7 @@ func TestAccount_RecordTransaction_Success(t *testing.T) {
 			name:         "OutstandingToOutstanding",
 			startBalance: 100,
 			amount:       50,
-			want: func() *event.Account {
-				return event.NewAccount("Jack", 150)
-			}(),
+			want:         accountFromJSON(t, `{"ID":"Jack","Status":"Outstanding","Balance":150,"OpenItems":[{"Amount":100,"Remaining":100},{"Amount":50,"Remaining":50}]}`),
 		},
 		{
 			name:         "SettledToSettled",
@@ -179,3 +189,105 @@ func TestAccount_Recall(t *testing.T) {
 		})
 	}
 }
+
+func TestAccount_MarshalJSON(t *testing.T) {
+	recalled := event.NewAccount("Olivia", 50)
+	recalled.Recall()
+	writtenOff := writtenOffAccount("Lucy", 80)
+	_ = writtenOff.RecordRecovery(20)
+	invoiced := event.NewAccount("Jen", 0)
+	_ = invoiced.RecordCharge("INV-1", 25, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC))
+	_ = invoiced.RecordPayment("", 10)
+	credited := event.NewAccount("Emma", 30)
+	_ = credited.RecordPayment("", 40)
+	_ = credited.RecordTransaction(5)
+
+	subtests := []struct {
+		name    string
+		account event.Account
+		want    string
+	}{
+		{name: "Outstanding", account: *event.NewAccount("Jack", 100), want: `{"ID":"Jack","Status":"Outstanding","Balance":100}`},
+		{name: "Overpaid", account: *event.NewAccount("Jen", -10), want: `{"ID":"Jen","Status":"Overpaid","Balance":-10}`},
+		{name: "Recalled", account: *recalled, want: `{"ID":"Olivia","Status":"Recalled","Balance":50}`},
+		{name: "WrittenOff", account: *writtenOff, want: `{"ID":"Lucy","Status":"WrittenOff","Balance":0,"WrittenOff":80,"Recovered":20}`},
+		{name: "OpenItems", account: *invoiced, want: `{"ID":"Jen","Status":"Outstanding","Balance":15,"OpenItems":[{"Invoice":"INV-1","Amount":25,"Remaining":15,"ChargedAt":"2026-03-01T00:00:00Z"}]}`},
+		// Credit left after the charges it paid is the balance NewAccount makes, so it is left out.
+		{name: "Credit", account: *credited, want: `{"ID":"Emma","Status":"Overpaid","Balance":-5}`},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.account)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+
+			// Pointers and map values are encoded the same way.
+			got, err = json.Marshal(map[string]*event.Account{"a": &tt.account})
+			assert.NoError(t, err)
+			assert.Equal(t, `{"a":`+tt.want+`}`, string(got))
+
+			var account event.Account
+			assert.NoError(t, json.Unmarshal([]byte(tt.want), &account))
+			assert.Equal(t, tt.account, account)
+		})
+	}
+}
+
+func TestAccount_UnmarshalJSON_CustomErrors(t *testing.T) {
+	subtests := []struct {
+		name  string
+		input string
+		want  error
+	}{
+		{
+			name:  "EmptyID",
+			input: `{"ID":"","Status":"Settled","Balance":0}`,
+			want:  &event.ErrInvalidAccount{AccountID: "", Reason: "empty ID"},
+		},
+		{
+			name:  "StatusDoesNotMatchBalance",
+			input: `{"ID":"Jack","Status":"Settled","Balance":10}`,
+			want:  &event.ErrInvalidAccount{AccountID: "Jack", Reason: `status "Settled" does not match balance 10`},
+		},
+		{
+			name:  "UnknownStatus",
+			input: `{"ID":"Jack","Status":"Frozen","Balance":10}`,
+			want:  &event.ErrInvalidAccount{AccountID: "Jack", Reason: `status "Frozen" does not match balance 10`},
+		},
+		{
+			name:  "WrittenOffWithDebt",
+			input: `{"ID":"Jack","Status":"WrittenOff","Balance":10,"WrittenOff":40}`,
+			want:  &event.ErrInvalidAccount{AccountID: "Jack", Reason: "written off with balance 10"},
+		},
+		{
+			name:  "RecoveredMoreThanWrittenOff",
+			input: `{"ID":"Jack","Status":"WrittenOff","Balance":0,"WrittenOff":40,"Recovered":50}`,
+			want:  &event.ErrInvalidAccount{AccountID: "Jack", Reason: "recovered 50 of written-off 40"},
+		},
+		{
+			name:  "WrittenOffAmountOfRecalledAccount",
+			input: `{"ID":"Jack","Status":"Recalled","Balance":0,"WrittenOff":40}`,
+			want:  &event.ErrInvalidAccount{AccountID: "Jack", Reason: `written-off amount of account with status "Recalled"`},
+		},
+		{
+			name:  "OpenItemsDoNotMatchBalance",
+			input: `{"ID":"Jack","Status":"Outstanding","Balance":10,"OpenItems":[{"Amount":25,"Remaining":5}]}`,
+			want:  &event.ErrInvalidAccount{AccountID: "Jack", Reason: "open items and credit add up to 5, not balance 10"},
+		},
+		{
+			name:  "OpenItemsAlongWithCredit",
+			input: `{"ID":"Jack","Status":"Outstanding","Balance":10,"OpenItems":[{"Amount":25,"Remaining":15}],"Credit":5}`,
+			want:  &event.ErrInvalidAccount{AccountID: "Jack", Reason: "open items along with credit 5"},
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			var account event.Account
+			err := json.Unmarshal([]byte(tt.input), &account)
+			assert.ErrorAs(t, err, new(*event.ErrInvalidAccount))
+			assert.EqualError(t, err, tt.want.Error())
+		})
+	}
+}
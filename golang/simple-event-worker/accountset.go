@@ -0,0 +1,139 @@
+package simpleeventworker
+
+import (
+	"cmp"
+	"encoding/json"
+	"iter"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// AccountSet is a read-only collection of accounts, e.g. as returned by ProcessEvents, with the lookups and queries
+// shared by the layers presenting accounts. Accounts are keyed as in the map the set is built from, usually by ID,
+// and iterated in the order of their keys.
+type AccountSet struct {
+	accounts map[string]Account
+	// keys holds the keys of accounts, sorted.
+	keys []string
+}
+
+// NewAccountSet builds a set of the accounts in `accounts`, which is copied.
+func NewAccountSet(accounts map[string]Account) *AccountSet {
+	s := &AccountSet{
+		accounts: make(map[string]Account, len(accounts)),
+		keys:     slices.Sorted(maps.Keys(accounts)),
+	}
+	maps.Copy(s.accounts, accounts)
+
+	return s
+}
+
+// subset builds a set of the accounts of `s` under `keys`, which are sorted already.
+func (s *AccountSet) subset(keys []string) *AccountSet {
+	accounts := make(map[string]Account, len(keys))
+	for _, key := range keys {
+		accounts[key] = s.accounts[key]
+	}
+
+	return &AccountSet{accounts: accounts, keys: keys}
+}
+
+func (s *AccountSet) Len() int {
+	return len(s.keys)
+}
+
+// Get returns the account under `key`, and whether there is one.
+func (s *AccountSet) Get(key string) (Account, bool) {
+	account, ok := s.accounts[key]
+	return account, ok
+}
+
+// Keys returns the keys of the accounts, sorted.
+func (s *AccountSet) Keys() []string {
+	return slices.Clone(s.keys)
+}
+
+// All iterates over the accounts by key, in the order of their keys.
+func (s *AccountSet) All() iter.Seq2[string, Account] {
+	return func(yield func(string, Account) bool) {
+		for _, key := range s.keys {
+			if !yield(key, s.accounts[key]) {
+				return
+			}
+		}
+	}
+}
+
+// Sorted iterates over the accounts by key, in the order of `compare`. Ties are broken by key.
+func (s *AccountSet) Sorted(compare func(a, b Account) int) iter.Seq2[string, Account] {
+	keys := slices.Clone(s.keys)
+	slices.SortStableFunc(keys, func(a, b string) int {
+		return compare(s.accounts[a], s.accounts[b])
+	})
+
+	return func(yield func(string, Account) bool) {
+		for _, key := range keys {
+			if !yield(key, s.accounts[key]) {
+				return
+			}
+		}
+	}
+}
+
+// CompareBalance orders accounts by balance, lowest first. Reverse it for the accounts owing the most first.
+func CompareBalance(a, b Account) int {
+	return cmp.Compare(a.balance, b.balance)
+}
+
+// AccountFilter reports whether an account belongs in the result of AccountSet.Filter.
+type AccountFilter func(Account) bool
+
+// StatusIn keeps the accounts with one of `statuses`.
+func StatusIn(statuses ...string) AccountFilter {
+	return func(a Account) bool {
+		return slices.Contains(statuses, a.status)
+	}
+}
+
+// BalanceBetween keeps the accounts with a balance from `low` to `high`, both included.
+func BalanceBetween(low, high int) AccountFilter {
+	return func(a Account) bool {
+		return a.balance >= low && a.balance <= high
+	}
+}
+
+// Filter returns the set of the accounts kept by all of `filters`.
+func (s *AccountSet) Filter(filters ...AccountFilter) *AccountSet {
+	keys := []string{}
+	for _, key := range s.keys {
+		account := s.accounts[key]
+		if !slices.ContainsFunc(filters, func(keep AccountFilter) bool { return !keep(account) }) {
+			keys = append(keys, key)
+		}
+	}
+
+	return s.subset(keys)
+}
+
+// Prefix returns the set of the accounts whose key starts with `prefix`, e.g. the accounts of a tenant
+// keyed by "<tenant>/<account>". It takes O(log n) to find the first one.
+func (s *AccountSet) Prefix(prefix string) *AccountSet {
+	start, _ := slices.BinarySearch(s.keys, prefix)
+	end := start
+	for end < len(s.keys) && strings.HasPrefix(s.keys[end], prefix) {
+		end++
+	}
+
+	return s.subset(slices.Clone(s.keys[start:end]))
+}
+
+// Map returns the accounts by key, as the map the set could be built from.
+func (s *AccountSet) Map() map[string]Account {
+	return maps.Clone(s.accounts)
+}
+
+// MarshalJSON encodes the set as a JSON object of accounts by key, in the order of their keys.
+func (s *AccountSet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.accounts)
+}
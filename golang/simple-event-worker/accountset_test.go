@@ -0,0 +1,166 @@
+package simpleeventworker_test
+
+import (
+	"encoding/json"
+	"iter"
+	"testing"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testAccountSet() *event.AccountSet {
+	recalled := event.NewAccount("Olivia", 50)
+	recalled.Recall()
+
+	return event.NewAccountSet(map[string]event.Account{
+		"Jack":      *event.NewAccount("Jack", 75),
+		"Jen":       *event.NewAccount("Jen", -10),
+		"Jenny":     *event.NewAccount("Jenny", 0),
+		"Olivia":    *recalled,
+		"acme/Jack": *event.NewAccount("Jack", 20),
+	})
+}
+
+func TestAccountSet_Get(t *testing.T) {
+	s := testAccountSet()
+
+	account, ok := s.Get("Jen")
+	assert.True(t, ok)
+	assert.Equal(t, *event.NewAccount("Jen", -10), account)
+
+	account, ok = s.Get("acme/Jack")
+	assert.True(t, ok)
+	assert.Equal(t, 20, account.Balance())
+
+	_, ok = s.Get("John")
+	assert.False(t, ok)
+	assert.Equal(t, 5, s.Len())
+}
+
+func TestAccountSet_NewAccountSet_CopiesAccounts(t *testing.T) {
+	accounts := map[string]event.Account{"Jack": *event.NewAccount("Jack", 75)}
+	s := event.NewAccountSet(accounts)
+
+	accounts["Jen"] = *event.NewAccount("Jen", 10)
+	s.Map()["John"] = *event.NewAccount("John", 10)
+
+	assert.Equal(t, []string{"Jack"}, s.Keys())
+	assert.Equal(t, 1, len(s.Map()))
+}
+
+func TestAccountSet_All(t *testing.T) {
+	s := testAccountSet()
+
+	assert.Equal(t, []string{"Jack", "Jen", "Jenny", "Olivia", "acme/Jack"}, keys(s.All()))
+
+	// Iteration stops when asked to.
+	for key := range s.All() {
+		assert.Equal(t, "Jack", key)
+		break
+	}
+	for key := range s.Sorted(event.CompareBalance) {
+		assert.Equal(t, "Jen", key)
+		break
+	}
+}
+
+func TestAccountSet_Sorted(t *testing.T) {
+	s := testAccountSet()
+
+	assert.Equal(t, []string{"Jen", "Jenny", "acme/Jack", "Olivia", "Jack"}, keys(s.Sorted(event.CompareBalance)))
+
+	byBalanceDesc := func(a, b event.Account) int { return event.CompareBalance(b, a) }
+	assert.Equal(t, []string{"Jack", "Olivia", "acme/Jack", "Jenny", "Jen"}, keys(s.Sorted(byBalanceDesc)))
+
+	// Ties are broken by key.
+	byNothing := func(a, b event.Account) int { return 0 }
+	assert.Equal(t, s.Keys(), keys(s.Sorted(byNothing)))
+}
+
+func TestAccountSet_Filter(t *testing.T) {
+	subtests := []struct {
+		name    string
+		filters []event.AccountFilter
+		want    []string
+	}{
+		{
+			name: "NoFilters",
+			want: []string{"Jack", "Jen", "Jenny", "Olivia", "acme/Jack"},
+		},
+		{
+			name:    "StatusIn",
+			filters: []event.AccountFilter{event.StatusIn(event.AccountStatusOutstanding, event.AccountStatusRecalled)},
+			want:    []string{"Jack", "Olivia", "acme/Jack"},
+		},
+		{
+			name:    "BalanceBetween",
+			filters: []event.AccountFilter{event.BalanceBetween(0, 50)},
+			want:    []string{"Jenny", "Olivia", "acme/Jack"},
+		},
+		{
+			name:    "All",
+			filters: []event.AccountFilter{event.StatusIn(event.AccountStatusOutstanding), event.BalanceBetween(0, 50)},
+			want:    []string{"acme/Jack"},
+		},
+		{
+			name:    "None",
+			filters: []event.AccountFilter{event.BalanceBetween(100, 200)},
+			want:    []string{},
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := testAccountSet().Filter(tt.filters...)
+			assert.Equal(t, tt.want, got.Keys())
+			assert.Equal(t, len(tt.want), got.Len())
+		})
+	}
+}
+
+func TestAccountSet_Prefix(t *testing.T) {
+	subtests := []struct {
+		name   string
+		prefix string
+		want   []string
+	}{
+		{name: "Empty", prefix: "", want: []string{"Jack", "Jen", "Jenny", "Olivia", "acme/Jack"}},
+		{name: "Several", prefix: "Jen", want: []string{"Jen", "Jenny"}},
+		{name: "Exact", prefix: "Jenny", want: []string{"Jenny"}},
+		{name: "Tenant", prefix: "acme/", want: []string{"acme/Jack"}},
+		{name: "CaseSensitive", prefix: "jen", want: []string{}},
+		{name: "AfterAll", prefix: "z", want: []string{}},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := testAccountSet().Prefix(tt.prefix)
+			assert.Equal(t, tt.want, got.Keys())
+		})
+	}
+}
+
+func TestAccountSet_MarshalJSON(t *testing.T) {
+	data, err := json.Marshal(testAccountSet().Prefix("J"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"Jack":{"ID":"Jack","Status":"Outstanding","Balance":75},
+		"Jen":{"ID":"Jen","Status":"Overpaid","Balance":-10},
+		"Jenny":{"ID":"Jenny","Status":"Settled","Balance":0}
+	}`, string(data))
+
+	data, err = json.Marshal(event.NewAccountSet(nil))
+	require.NoError(t, err)
+	assert.Equal(t, `{}`, string(data))
+}
+
+func keys(seq iter.Seq2[string, event.Account]) []string {
+	keys := []string{}
+	for key := range seq {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
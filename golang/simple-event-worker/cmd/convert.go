@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/codec"
+)
+
+// runConvert converts a JSON array of events to the binary encoding of the codec package.
+func runConvert(args []string) int {
+	flags := flag.NewFlagSet("convert", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: convert -input <events.json> -output <events.bin>")
+		fmt.Fprintln(flags.Output(), "Converts a JSON array of events to the binary encoding read with -input-format binary.")
+		flags.PrintDefaults()
+	}
+	inputPath := flags.String("input", "events.json", "path of the JSON array of events to convert")
+	outputPath := flags.String("output", "", "path of the binary file to write")
+	keyPath := encryptionKeyFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *outputPath == "" {
+		flags.Usage()
+		return 2
+	}
+
+	key, err := loadKey(*keyPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	if err := convert(*inputPath, *outputPath, key); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+// convert writes the events of the JSON file at `inputPath` to `outputPath`, encrypted with `key` if it is not nil,
+// since they hold the account IDs of the input.
+func convert(inputPath, outputPath string, key []byte) error {
+	input, err := os.Open(inputPath)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	events, err := event.NewService().ParseEvents(input)
+	if err != nil {
+		return fmt.Errorf("%s: %w", inputPath, err)
+	}
+
+	output, err := createFile(outputPath, key)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(output)
+	if err := codec.WriteEvents(w, events); err != nil {
+		output.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		output.Close()
+		return err
+	}
+
+	return output.Close()
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/encryption"
+)
+
+// encryptionKeyEnv names the environment variable holding the base64 key files are encrypted with, when no key file is
+// given. Like the webhook secret, the key itself is never a flag.
+const encryptionKeyEnv = "ENCRYPTION_KEY"
+
+// encryptionKeyFlag defines the flag of the key file of a command reading or writing account data.
+func encryptionKeyFlag(flags *flag.FlagSet) *string {
+	return flags.String("encryption-key-file", "", "file holding the base64 AES-256 key to encrypt the files written and decrypt the files read with; defaults to $"+encryptionKeyEnv+", and files are plain without either")
+}
+
+// loadKey returns the key in the file at `path`, or else in $ENCRYPTION_KEY, or nil if there is neither,
+// in which case files are written and read as plain text.
+func loadKey(path string) ([]byte, error) {
+	text := os.Getenv(encryptionKeyEnv)
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		text = string(data)
+	}
+	if text == "" {
+		return nil, nil
+	}
+
+	key, err := encryption.ParseKey(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -encryption-key-file or $%s: %w", encryptionKeyEnv, err)
+	}
+
+	return key, nil
+}
+
+// outputFile is a file written through an encryption.Writer when there is a key.
+type outputFile struct {
+	w    io.Writer
+	file *os.File
+	// sync syncs every Write to disk before it returns.
+	sync bool
+}
+
+func newOutputFile(file *os.File, key []byte, sync bool) (*outputFile, error) {
+	f := &outputFile{w: file, file: file, sync: sync}
+	if key != nil {
+		w, err := encryption.NewWriter(file, key)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		f.w = w
+	}
+
+	return f, nil
+}
+
+func (f *outputFile) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if err != nil || !f.sync {
+		return n, err
+	}
+
+	return n, f.file.Sync()
+}
+
+func (f *outputFile) Close() error {
+	return f.file.Close()
+}
+
+// createFile creates, or truncates, the file at `path`, encrypted with `key` if it is not nil.
+func createFile(path string, key []byte) (io.WriteCloser, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return newOutputFile(file, key, false)
+}
+
+// appendFile opens, or creates, the file at `path` for appending, encrypted with `key` if it is not nil. Like the
+// NDJSON files of the outbox and deadletter packages, every Write is synced to disk before it returns.
+// A file must be encrypted with the same key, or not at all, across runs, since each run appends to it.
+func appendFile(path string, key []byte) (io.WriteCloser, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	return newOutputFile(file, key, true)
+}
+
+// inputFile is a file read through an encryption.Reader when there is a key.
+type inputFile struct {
+	io.Reader
+	file *os.File
+}
+
+func (f *inputFile) Close() error {
+	return f.file.Close()
+}
+
+// openFile opens the file at `path` for reading, decrypted with `key` if it is not nil.
+func openFile(path string, key []byte) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return file, nil
+	}
+
+	r, err := encryption.NewReader(file, key)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &inputFile{Reader: r, file: file}, nil
+}
+
+// decrypting returns `parse` reading its input decrypted with `key`, or `parse` itself if `key` is nil, e.g. to read
+// the binary files written by convert.
+func decrypting(key []byte, parse func(io.Reader) ([]event.Event, error)) func(io.Reader) ([]event.Event, error) {
+	if key == nil {
+		return parse
+	}
+
+	return func(r io.Reader) ([]event.Event, error) {
+		r, err := encryption.NewReader(r, key)
+		if err != nil {
+			return nil, err
+		}
+
+		return parse(r)
+	}
+}
+
+// readFile reads the whole file at `path`, decrypted with `key` if it is not nil.
+func readFile(path string, key []byte) ([]byte, error) {
+	file, err := openFile(path, key)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return data, nil
+}
+
+// runDecrypt writes the plain text of files encrypted by the worker to stdout, e.g. a snapshot, a dead-letter queue
+// or a pseudonym mapping, for the people allowed to read them.
+func runDecrypt(args []string) int {
+	flags := flag.NewFlagSet("decrypt", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: decrypt [flags] <file>...")
+		fmt.Fprintln(flags.Output(), "Writes the plain text of files written with an encryption key to stdout, one after the other.")
+		flags.PrintDefaults()
+	}
+	keyPath := encryptionKeyFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	key, err := loadKey(*keyPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if key == nil {
+		fmt.Fprintf(os.Stderr, "decrypt needs -encryption-key-file or $%s\n", encryptionKeyEnv)
+		return 2
+	}
+
+	for _, path := range flags.Args() {
+		if err := decryptFile(os.Stdout, path, key); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// decryptFile copies the plain text of a file to `w`. Frames are copied as they are read, so the text of a file that
+// fails partway is output up to the frame that failed.
+func decryptFile(w io.Writer, path string, key []byte) error {
+	file, err := openFile(path, key)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(w, file); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	return nil
+}
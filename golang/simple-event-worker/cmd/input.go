@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/codec"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/csvevents"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/input"
+)
+
+// inputFormat holds the flags telling how to read the events of an input, so every command reading the input of the
+// worker, e.g. verify, reads it as the worker did.
+type inputFormat struct {
+	format     *string
+	csvColumns *string
+	csvComma   *string
+}
+
+// inputFormatFlags defines the flags of the format of the input of a command.
+func inputFormatFlags(flags *flag.FlagSet) *inputFormat {
+	return &inputFormat{
+		format:     flags.String("input-format", "json", "format of the input: json (an array of events), csv (one event per row) or binary (see the convert subcommand)"),
+		csvColumns: flags.String("csv-columns", "", "with -input-format csv, the names of the columns of the header as field=name pairs, e.g. type=Kind,account_id=Customer"),
+		csvComma:   flags.String("csv-comma", ",", "with -input-format csv, the separator of cells"),
+	}
+}
+
+// readEvents reads the events of the parts in the format of the flags with `service`, so its validation and
+// DeadLetterSink apply to every format. Binary parts are written by convert, so they are decrypted with `key`, like the
+// other files. JSON and CSV parts come from upstream, and are read as they are.
+func (f *inputFormat) readEvents(parts []input.Part, service *event.EventService, key []byte) ([]event.Event, error) {
+	switch *f.format {
+	case "json":
+		return input.Read(parts, service.ParseEvents)
+	case "csv":
+		return readCSV(parts, service, *f.csvColumns, *f.csvComma)
+	case "binary":
+		return input.Read(parts, decrypting(key, func(r io.Reader) ([]event.Event, error) {
+			return service.DecodeEvents(codec.NewDecoder(r, codec.WithValidation(false)))
+		}))
+	default:
+		return nil, fmt.Errorf(`invalid -input-format: "%s"`, *f.format)
+	}
+}
+
+// readCSV reads the events of CSV parts with `service`. Like JSON events, they are validated while processing rather than
+// read, so an invalid row only fails the events of its tenant, and a row that cannot be read is quarantined with -dlq.
+func readCSV(parts []input.Part, service *event.EventService, columns, comma string) ([]event.Event, error) {
+	csvColumns, err := csvevents.ParseColumns(columns)
+	if err != nil {
+		return nil, err
+	}
+	separator := []rune(comma)
+	if len(separator) != 1 {
+		return nil, fmt.Errorf(`invalid -csv-comma: "%s"`, comma)
+	}
+
+	return input.Read(parts, func(r io.Reader) ([]event.Event, error) {
+		return service.DecodeEvents(csvevents.NewDecoder(r, csvevents.WithColumns(csvColumns), csvevents.WithComma(separator[0]), csvevents.WithValidation(false)))
+	})
+}
@@ -1,56 +1,475 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/deadletter"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/input"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/metrics"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/outbox"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/reporting"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/tenant"
 )
 
+// subcommands are run with `<subcommand> [flags] [args]`, and return the exit code of the worker.
+// Without a subcommand, the worker processes the input events.
+var subcommands = map[string]func(args []string) int{
+	"convert":    runConvert,
+	"decrypt":    runDecrypt,
+	"diff":       runDiff,
+	"repl":       runREPL,
+	"replay-dlq": runReplayDLQ,
+	"simulate":   runSimulate,
+	"verify":     runVerify,
+}
+
+// webhookSecretEnv names the environment variable holding the secret webhook requests are signed with.
+// It is not a flag, so the secret does not show up in the list of processes.
+const webhookSecretEnv = "WEBHOOK_SECRET"
+
+// exitQuarantined is the exit code of a run that quarantined events to its dead-letter queue, but otherwise succeeded.
+const exitQuarantined = 3
+
 func main() {
+	// Subcommands ----------------------------------------
+	if len(os.Args) > 1 {
+		if subcommand, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(subcommand(os.Args[2:]))
+		}
+	}
+
+	// Flags ----------------------------------------------
+	inputPath := flag.String("input", "events.json", "paths or glob patterns of the parts of the events to process, comma-separated, possibly compressed with gzip, bzip2 or zlib; parts are read in order")
+	inputFormat := inputFormatFlags(flag.CommandLine)
+	fastJSON := flag.Bool("fast-json", false, "decode JSON input with the decoder specialized for the event schema instead of encoding/json")
+	outputPath := flag.String("output", "", "if set, write a JSON snapshot of the final state of the accounts to this file; with several tenants, {tenant} in the path is replaced by the tenant ID")
+	withReport := flag.Bool("report", false, "print a summary report of the processed accounts and events")
+	reportFormat := flag.String("report-format", reporting.FormatText, "format of the summary report: text or json")
+	reportTop := flag.Int("report-top", 5, "number of accounts with the highest balance to list in the summary report")
+	metricsAddr := flag.String("metrics-addr", "", "if set, serve metrics at /metrics on this address and keep running until interrupted")
+	logFormat := flag.String("log-format", "text", "format of the logs written to stderr: text or json")
+	logLevel := flag.String("log-level", "info", "minimum level of the logs: debug, info, warn or error")
+	outboxPath := flag.String("outbox", "", "if set, append the domain events of account status changes to this NDJSON file")
+	webhookURL := flag.String("webhook-url", "", "if set, POST the domain events of accounts becoming Settled or Recalled to this URL, signed with the secret in $"+webhookSecretEnv+" if set")
+	webhookLogPath := flag.String("webhook-log", "", "with -webhook-url, append the delivery of every domain event posted to this NDJSON file")
+	dlqPath := flag.String("dlq", "", "if set, append rejected events to this NDJSON dead-letter queue and carry on instead of failing; exit with 3 if any were")
+	chainPath := flag.String("chain", "", "if set, write the SHA-256 hash chain of the processed events to this NDJSON file and record its head in the snapshots; with several tenants, {tenant} in the path is replaced by the tenant ID")
+	schemaPath := flag.String("schema", "", "if set, upcast event type aliases and versions with the JSON array of upcasters in this file instead of the default schema")
+	asOf := flag.String("as-of", "", "time to apply events as of, in RFC 3339 or as a date like 2026-03-01; events effective later are pending. Defaults to now")
+	keyPath := encryptionKeyFlag(flag.CommandLine)
+	pseudonymize := flag.Bool("pseudonymize", false, "print, report and log accounts by pseudonyms of their IDs, keyed by the secret salt in $"+pseudonymSaltEnv)
+	pseudonymMapPath := flag.String("pseudonym-map", "", "with -pseudonymize, write the account IDs of the pseudonyms to this JSON file, encrypted like the other files")
+	flag.Parse()
+
 	// Dependencies --------------------------------------
-	logger := log.Default()
-	logger.SetPrefix("[main] ")
+	baseLogger, err := newLogger(*logFormat, *logLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	logger := baseLogger.With(slog.String("component", "main"))
 
-	eventService := event.NewService()
+	clock, err := newClock(*asOf)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 
-	// Execute --------------------------------------------
+	key, err := loadKey(*keyPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 
-	cwd, err := os.Getwd()
+	pseudonymizer, err := loadPseudonymizer(*pseudonymize)
 	if err != nil {
-		logger.Println(err)
-		return
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if pseudonymizer == nil && *pseudonymMapPath != "" {
+		fmt.Fprintln(os.Stderr, "-pseudonym-map needs -pseudonymize")
+		os.Exit(2)
+	}
+
+	registry := metrics.NewRegistry()
+	recorder := metrics.NewRecorder(registry)
+	serviceOpts := []event.ServiceOption{
+		event.WithInstrumentation(recorder),
+		event.WithLogger(baseLogger.With(slog.String("component", "event_service"))),
+		event.WithFastDecoder(*fastJSON),
+		event.WithClock(clock),
+	}
+	// Logs name accounts by the pseudonyms they are printed by.
+	if pseudonymizer != nil {
+		serviceOpts = append(serviceOpts, event.WithLogPseudonyms(accountPseudonym(pseudonymizer)))
+	}
+
+	if *schemaPath != "" {
+		schema, err := loadSchema(*schemaPath)
+		if err != nil {
+			logger.Error("cannot load schema", slog.Any("error", err))
+			os.Exit(1)
+		}
+		serviceOpts = append(serviceOpts, event.WithSchema(schema))
+	}
+
+	// The webhook comes last, so it only posts the domain events of batches the outbox file accepted.
+	outboxes := outbox.Fanout{}
+	var webhook *outbox.Webhook
+	if *outboxPath != "" {
+		outboxFile, err := appendFile(*outboxPath, key)
+		if err != nil {
+			logger.Error("cannot open outbox", slog.Any("error", err))
+			os.Exit(1)
+		}
+		defer outboxFile.Close()
+		outboxes = append(outboxes, outbox.NewNDJSONWriter(outboxFile))
+	}
+	if *webhookURL != "" {
+		webhookOpts := []outbox.WebhookOption{}
+		if secret := os.Getenv(webhookSecretEnv); secret != "" {
+			webhookOpts = append(webhookOpts, outbox.WithWebhookSecret([]byte(secret)))
+		}
+		if *webhookLogPath != "" {
+			webhookLog, err := appendFile(*webhookLogPath, key)
+			if err != nil {
+				logger.Error("cannot open webhook delivery log", slog.Any("error", err))
+				os.Exit(1)
+			}
+			defer webhookLog.Close()
+			webhookOpts = append(webhookOpts, outbox.WithWebhookDeliveryLog(webhookLog))
+		}
+		webhook = outbox.NewWebhook(*webhookURL, webhookOpts...)
+		outboxes = append(outboxes, webhook)
+	}
+	if len(outboxes) > 0 {
+		serviceOpts = append(serviceOpts, event.WithOutbox(outboxes))
+	}
+
+	var dlq *deadletter.NDJSONWriter
+	if *dlqPath != "" {
+		dlqFile, err := appendFile(*dlqPath, key)
+		if err != nil {
+			logger.Error("cannot open dead-letter queue", slog.Any("error", err))
+			os.Exit(1)
+		}
+		defer dlqFile.Close()
+		dlq = deadletter.NewNDJSONWriter(dlqFile)
+		serviceOpts = append(serviceOpts, event.WithDeadLetterSink(dlq))
+	}
+
+	// Events are validated while processing rather than parsing, so an invalid event only fails the events of its tenant.
+	eventService := event.NewService(append(serviceOpts, event.WithParseValidation(false))...)
+	processorOpts := []tenant.Option{tenant.WithDefaultPolicy(serviceOpts...)}
+
+	var metricsServer *http.Server
+	if *metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", registry.Handler())
+		metricsServer = &http.Server{Addr: *metricsAddr, Handler: mux}
+
+		go func() {
+			if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Error("metrics server stopped", slog.Any("error", err))
+				os.Exit(1)
+			}
+		}()
+		logger.Info("serving metrics", slog.String("addr", *metricsAddr), slog.String("path", "/metrics"))
 	}
 
-	// Simulate reading the events from anywhere that we could read from. We only need an io.Reader instance.
-	// In this case, we're reading from a file.
-	file, err := os.Open(fmt.Sprintf("%s/events.json", cwd))
+	// Execute --------------------------------------------
+
+	// Simulate reading the events from anywhere that we could read from. We only need an io.Reader instance per part.
+	// In this case, we're reading from files.
+	parts, err := input.Resolve(strings.Split(*inputPath, ",")...)
 	if err != nil {
-		logger.Println(err)
+		logger.Error("cannot open events", slog.Any("error", err))
 		os.Exit(1)
 	}
-	defer file.Close()
 
-	events, err := eventService.ParseEvents(file)
+	events, err := inputFormat.readEvents(parts, eventService, key)
 	if err != nil {
-		logger.Println(err)
+		logger.Error("cannot parse events", slog.Any("error", err))
 		os.Exit(1)
 	}
 
-	// Normally, all the processing is already done in the eventService.ProcessEvents method,
+	// Normally, all the processing is already done in the processor.ProcessEvents method,
 	// and we don't need the events/accounts anymore.
 	// Maybe it saves the results to a database or sends them to another service, or saves them to a file or whatever.
 	// We're just printing the results here for demonstration.
-	accounts, err := eventService.ProcessEvents(events)
-	if err != nil {
-		logger.Println(err)
+	// The events of each tenant are processed separately, so a tenant whose events fail does not stop the others.
+	// Every tenant is chained and parks its pending events separately, since it is processed by its own service.
+	chains := map[string]*event.HashChain{}
+	queues := map[string]*event.PendingQueue{}
+	for tenantID := range tenant.Split(events) {
+		queues[tenantID] = event.NewPendingQueue()
+		policy := []event.ServiceOption{event.WithPendingQueue(queues[tenantID])}
+		if *chainPath != "" {
+			chains[tenantID] = event.NewHashChain()
+			policy = append(policy, event.WithHashChain(chains[tenantID]))
+		}
+		processorOpts = append(processorOpts, tenant.WithPolicy(tenantID, policy...))
+	}
+
+	result := tenant.NewProcessor(processorOpts...).ProcessEvents(events)
+	for _, tenantID := range result.Tenants() {
+		if err, ok := result.Errors[tenantID]; ok {
+			logger.Error("cannot process events", slog.String("tenant_id", tenantID), slog.String("error", pseudonymizeError(err, tenantID, events, pseudonymizer)))
+		}
+	}
+
+	// The service of each tenant sets the accounts gauge to its own accounts, so it is set again for all tenants.
+	recorder.AccountsProcessed(result.All())
+
+	// Only the accounts of tenants whose events succeeded are output. With several tenants, they are keyed by tenant.AccountKey.
+	succeeded := []string{}
+	succeededAccounts := map[string]event.Account{}
+	for _, tenantID := range result.Tenants() {
+		if _, ok := result.Errors[tenantID]; ok {
+			continue
+		}
+		succeeded = append(succeeded, tenantID)
+		for id, account := range result.Accounts[tenantID] {
+			succeededAccounts[tenant.AccountKey(tenantID, id)] = account
+		}
+	}
+	// Pseudonymized accounts are printed and reported by pseudonym, while the snapshots keep their IDs.
+	if pseudonymizer != nil {
+		succeededAccounts = pseudonymizer.Accounts(succeededAccounts)
+	}
+	accounts := event.NewAccountSet(succeededAccounts)
+
+	printAccounts(accounts)
+	for _, tenantID := range succeeded {
+		printPending(tenantID, queues[tenantID], pseudonymizer)
+	}
+
+	if *outputPath != "" {
+		if len(result.Tenants()) > 1 && !strings.Contains(*outputPath, tenantPlaceholder) {
+			logger.Error("cannot write snapshots", slog.Any("error", fmt.Errorf("-output must contain %s with several tenants", tenantPlaceholder)))
+			os.Exit(1)
+		}
+		for _, tenantID := range succeeded {
+			snapshotOpts := []event.SnapshotOption{event.WithSnapshotPendingQueue(queues[tenantID])}
+			if chain, ok := chains[tenantID]; ok {
+				snapshotOpts = append(snapshotOpts, event.WithSnapshotHashChain(chain))
+			}
+			if err := writeSnapshot(tenantPath(*outputPath, tenantID), key, result.Accounts[tenantID], snapshotOpts...); err != nil {
+				logger.Error("cannot write snapshot", slog.String("tenant_id", tenantID), slog.Any("error", err))
+				os.Exit(1)
+			}
+		}
+	}
+
+	if *chainPath != "" {
+		if len(result.Tenants()) > 1 && !strings.Contains(*chainPath, tenantPlaceholder) {
+			logger.Error("cannot write hash chains", slog.Any("error", fmt.Errorf("-chain must contain %s with several tenants", tenantPlaceholder)))
+			os.Exit(1)
+		}
+		for _, tenantID := range succeeded {
+			if err := writeHashChain(tenantPath(*chainPath, tenantID), key, chains[tenantID]); err != nil {
+				logger.Error("cannot write hash chain", slog.String("tenant_id", tenantID), slog.Any("error", err))
+				os.Exit(1)
+			}
+		}
+	}
+
+	// The accounts are saved, so the webhook posts the domain events of the tenants that succeeded. Failing tenants
+	// published none.
+	if webhook != nil {
+		if err := webhook.Commit(); err != nil {
+			logger.Error("cannot post domain events", slog.Any("error", err))
+			os.Exit(1)
+		}
+		if err := webhook.Close(); err != nil {
+			logger.Error("cannot write webhook delivery log", slog.Any("error", err))
+			os.Exit(1)
+		}
+	}
+
+	if *withReport {
+		// Only the events applied are reported, so neither the quarantined nor the pending ones count.
+		reportedEvents := []event.Event{}
+		for _, tenantID := range succeeded {
+			reportedEvents = append(reportedEvents, result.Applied[tenantID]...)
+		}
+
+		report := reporting.New(accounts.Map(), reportedEvents, *reportTop)
+		if err := report.Write(os.Stdout, *reportFormat); err != nil {
+			logger.Error("cannot write report", slog.Any("error", err))
+			os.Exit(1)
+		}
+	}
+
+	if *pseudonymMapPath != "" {
+		if err := writePseudonymMapping(*pseudonymMapPath, key, pseudonymizer); err != nil {
+			logger.Error("cannot write pseudonym mapping", slog.Any("error", err))
+			os.Exit(1)
+		}
+	}
+
+	if err := result.Err(); err != nil {
 		os.Exit(1)
 	}
 
-	for id, account := range accounts {
-		logger.Printf("%s: {Status: %s, Balance: %d}\n", id, account.Status(), account.Balance())
+	exitCode := 0
+	if dlq != nil && dlq.Quarantined() > 0 {
+		logger.Warn("events quarantined to the dead-letter queue", slog.Int("dead_letters", dlq.Quarantined()), slog.String("path", *dlqPath))
+		exitCode = exitQuarantined
+	}
+
+	// In long-lived mode, keep the metrics available until we are told to stop.
+	if metricsServer != nil {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		<-ctx.Done()
+
+		if err := metricsServer.Shutdown(context.Background()); err != nil {
+			logger.Error("cannot stop metrics server", slog.Any("error", err))
+			os.Exit(1)
+		}
+	}
+
+	os.Exit(exitCode)
+}
+
+// newLogger builds the logger of the worker. Logs go to stderr, so stdout only carries the accounts and reports.
+func newLogger(format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid -log-level: %w", err)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(os.Stderr, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(os.Stderr, opts)), nil
+	default:
+		return nil, fmt.Errorf(`invalid -log-format: "%s"`, format)
+	}
+}
+
+// tenantPlaceholder is replaced by the tenant ID in the paths of per-tenant outputs.
+const tenantPlaceholder = "{tenant}"
+
+// tenantPath returns the path of an output of a tenant. Events without a TenantID are of the "default" tenant.
+func tenantPath(pattern, tenantID string) string {
+	if tenantID == tenant.DefaultTenantID {
+		tenantID = "default"
+	}
+
+	return strings.ReplaceAll(pattern, tenantPlaceholder, tenantID)
+}
+
+// printAccounts prints the final state of accounts to stdout, in the order of their keys.
+func printAccounts(accounts *event.AccountSet) {
+	for key, account := range accounts.All() {
+		fmt.Printf("%s: {Status: %s, Balance: %d}\n", key, account.Status(), account.Balance())
+	}
+}
+
+// printPending prints the events of a tenant pending until they take effect, in the order they will.
+// With a pseudonymizer, their accounts are printed by pseudonym.
+func printPending(tenantID string, queue *event.PendingQueue, pseudonymizer *reporting.Pseudonymizer) {
+	for _, e := range queue.Events() {
+		key := tenant.AccountKey(tenantID, e.AccountID)
+		if pseudonymizer != nil {
+			key = pseudonymizer.Pseudonym(key)
+		}
+		fmt.Printf("%s: pending %s effective at %s\n", key, e.Type, e.EffectiveAt.Format(time.RFC3339))
+	}
+}
+
+// newClock returns the clock to apply events as of: the time `asOf`, in RFC 3339 or as a date, which is midnight UTC,
+// or the wall clock if it is empty.
+func newClock(asOf string) (event.Clock, error) {
+	if asOf == "" {
+		return event.SystemClock, nil
+	}
+
+	t, err := time.Parse(time.DateOnly, asOf)
+	if err != nil {
+		if t, err = time.Parse(time.RFC3339, asOf); err != nil {
+			return nil, fmt.Errorf(`invalid -as-of: "%s"`, asOf)
+		}
+	}
+
+	return event.ClockFunc(func() time.Time { return t }), nil
+}
+
+// loadSchema reads a schema from a JSON array of upcasters, e.g.
+// [{"Type":"ChargePosted","Version":2,"To":"AccountChargeReceived","Drop":["Reference"]}].
+func loadSchema(path string) (*event.Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var upcasters []event.Upcaster
+	if err := json.Unmarshal(data, &upcasters); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	schema, err := event.NewSchema(upcasters...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return schema, nil
+}
+
+func writeSnapshot(path string, key []byte, accounts map[string]event.Account, opts ...event.SnapshotOption) error {
+	file, err := createFile(path, key)
+	if err != nil {
+		return err
+	}
+
+	if err := event.WriteSnapshot(file, accounts, opts...); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+func writeHashChain(path string, key []byte, chain *event.HashChain) error {
+	file, err := createFile(path, key)
+	if err != nil {
+		return err
+	}
+
+	if err := event.WriteHashChain(file, chain); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+func writePseudonymMapping(path string, key []byte, pseudonymizer *reporting.Pseudonymizer) error {
+	file, err := createFile(path, key)
+	if err != nil {
+		return err
+	}
+
+	if err := pseudonymizer.WriteMapping(file); err != nil {
+		file.Close()
+		return err
 	}
 
-	os.Exit(0)
+	return file.Close()
 }
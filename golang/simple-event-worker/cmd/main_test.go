@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// workerEnv makes the test binary run the worker instead of the tests, so runWorker can run it in a child process.
+const workerEnv = "SIMPLE_EVENT_WORKER_TEST_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(workerEnv) == "1" {
+		main()
+		os.Exit(0)
+	}
+
+	os.Exit(m.Run())
+}
+
+// runWorker runs the worker with `args` in a child process, since it exits, and returns its stdout and exit code.
+func runWorker(t *testing.T, args ...string) (string, int) {
+	t.Helper()
+
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), workerEnv+"=1")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return stdout.String(), exitErr.ExitCode()
+	}
+	require.NoError(t, err, stderr.String())
+
+	return stdout.String(), 0
+}
+
+// writeFile writes `content` to a file named `name` in a temporary directory and returns its path.
+func writeFile(t *testing.T, name string, content []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, content, 0o600))
+
+	return path
+}
+
+func TestMain_Report(t *testing.T) {
+	subtests := []struct {
+		name     string
+		events   string
+		args     []string
+		exitCode int
+		want     []string
+	}{
+		{
+			name: "Applied",
+			events: `[
+				{"Type":"AccountCreated","AccountID":"Jack","Payload":{"Balance":50}},
+				{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":25}},
+				{"Type":"AccountPaymentReceived","AccountID":"Jack","Payload":{"Amount":5}}
+			]`,
+			want: []string{"  Charges: 25\n", "  Payments: 5\n", "  AccountChargeReceived: 1\n", "  AccountPaymentReceived: 1\n"},
+		},
+		{
+			name: "Quarantined",
+			events: `[
+				{"Type":"AccountCreated","AccountID":"Jack","Payload":{"Balance":50}},
+				{"Type":"AccountRecalled","AccountID":"Jack","Payload":{}},
+				{"Type":"AccountPaymentReceived","AccountID":"Jen","Payload":{"Amount":700}},
+				{"Type":"AccountPaymentReceived","AccountID":"Jack","Payload":{"Amount":5}}
+			]`,
+			args:     []string{"-dlq", filepath.Join(t.TempDir(), "dlq.ndjson")},
+			exitCode: exitQuarantined,
+			want:     []string{"  Payments: 0\n", "  AccountCreated: 1\n  AccountRecalled: 1\n"},
+		},
+		{
+			name: "Pending",
+			events: `[
+				{"Type":"AccountCreated","AccountID":"Jack","Payload":{"Balance":50}},
+				{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":25}},
+				{"Type":"AccountChargeReceived","AccountID":"Jack","EffectiveAt":"2030-01-01T00:00:00Z","Payload":{"Amount":1000}}
+			]`,
+			args: []string{"-as-of", "2026-03-01"},
+			want: []string{"  Charges: 25\n", "  AccountChargeReceived: 1\n"},
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := writeFile(t, "events.json", []byte(tt.events))
+
+			stdout, exitCode := runWorker(t, append([]string{"-input", input, "-report"}, tt.args...)...)
+			assert.Equal(t, tt.exitCode, exitCode)
+			for _, want := range tt.want {
+				assert.Contains(t, stdout, want)
+			}
+		})
+	}
+}
+
+// binaryEvents encodes events with the codec, and the records in `raw` in their place, e.g. records the codec rejects.
+func binaryEvents(t *testing.T, events []event.Event, raw map[int]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	enc := codec.NewEncoder(&buf)
+	for i, e := range events {
+		if record, ok := raw[i]; ok {
+			buf.WriteString(record)
+			continue
+		}
+		require.NoError(t, enc.Encode(e))
+	}
+
+	return buf.Bytes()
+}
+
+func TestMain_InputFormats(t *testing.T) {
+	created, charged, paid := event.NewAccountCreatedEvent("Jack", 50), event.NewChargeEvent("Jack", 25), event.NewPaymentEvent("Jen", 5)
+	acmeCreated := event.NewAccountCreatedEvent("Jack", 10).InTenant("acme")
+
+	subtests := []struct {
+		name   string
+		format string
+		// events holds an AccountCreated, an event that cannot be decoded, a payment of an unknown account and a charge.
+		events []byte
+		// tenants holds an AccountCreated and a charge, and an AccountCreated and an invalid event of tenant acme.
+		tenants []byte
+	}{
+		{
+			name:   "JSON",
+			format: "json",
+			events: []byte(`[
+				{"Type":"AccountCreated","AccountID":"Jack","Payload":{"Balance":50}},
+				{"Type":"AccountCreate","AccountID":"Jack","Payload":{"Balance":50}},
+				{"Type":"AccountPaymentReceived","AccountID":"Jen","Payload":{"Amount":5}},
+				{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":25}}
+			]`),
+			tenants: []byte(`[
+				{"Type":"AccountCreated","AccountID":"Jack","Payload":{"Balance":50}},
+				{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":25}},
+				{"Type":"AccountCreated","TenantID":"acme","AccountID":"Jack","Payload":{"Balance":10}},
+				{"Type":"AccountRecalled","TenantID":"acme","AccountID":"","Payload":{}}
+			]`),
+		},
+		{
+			name:   "CSV",
+			format: "csv",
+			events: []byte(strings.Join([]string{
+				"type,tenant_id,account_id,amount,balance",
+				"AccountCreated,,Jack,,50",
+				"AccountCreate,,Jack,,50",
+				"AccountPaymentReceived,,Jen,5,",
+				"AccountChargeReceived,,Jack,25,",
+			}, "\n")),
+			tenants: []byte(strings.Join([]string{
+				"type,tenant_id,account_id,amount,balance",
+				"AccountCreated,,Jack,,50",
+				"AccountChargeReceived,,Jack,25,",
+				"AccountCreated,acme,Jack,,10",
+				"AccountRecalled,acme,,,",
+			}, "\n")),
+		},
+		{
+			name:   "Binary",
+			format: "binary",
+			// A record of an unknown type tag, and a recall of an empty AccountID of acme, which the codec cannot encode.
+			events:  binaryEvents(t, []event.Event{created, {}, paid, charged}, map[int]string{1: "\x04\x09\x01J\x00"}),
+			tenants: binaryEvents(t, []event.Event{created, charged, acmeCreated, {}}, map[int]string{3: "\x07\x84\x00\x04acme"}),
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := writeFile(t, "events", tt.events)
+
+			// With -dlq, the events rejected while decoding and while processing are quarantined, and the others applied.
+			dlq := filepath.Join(t.TempDir(), "dlq.ndjson")
+			stdout, exitCode := runWorker(t, "-input", input, "-input-format", tt.format, "-dlq", dlq)
+			assert.Equal(t, exitQuarantined, exitCode)
+			assert.Contains(t, stdout, "Jack: {Status: Outstanding, Balance: 75}\n")
+			letters, err := os.ReadFile(dlq)
+			require.NoError(t, err)
+			assert.Equal(t, 2, bytes.Count(letters, []byte("\n")))
+
+			// Without it, an event that cannot be decoded fails the run.
+			stdout, exitCode = runWorker(t, "-input", input, "-input-format", tt.format)
+			assert.Equal(t, 1, exitCode)
+			assert.Empty(t, stdout)
+
+			// While an invalid event only fails its tenant.
+			stdout, exitCode = runWorker(t, "-input", writeFile(t, "tenants", tt.tenants), "-input-format", tt.format)
+			assert.Equal(t, 1, exitCode)
+			assert.Equal(t, "Jack: {Status: Outstanding, Balance: 75}\n", stdout)
+		})
+	}
+}
+
+func TestVerify_InputFormats(t *testing.T) {
+	events := []event.Event{event.NewAccountCreatedEvent("Jack", 50), event.NewChargeEvent("Jack", 25), event.NewRecalledEvent("Jack")}
+
+	var ndjson bytes.Buffer
+	gz := gzip.NewWriter(&ndjson)
+	for _, e := range events {
+		line, err := e.MarshalJSON()
+		require.NoError(t, err)
+		_, err = gz.Write(append(line, '\n'))
+		require.NoError(t, err)
+	}
+	require.NoError(t, gz.Close())
+
+	subtests := []struct {
+		name   string
+		format string
+		file   string
+		events []byte
+	}{
+		{name: "NDJSON", format: "json", file: "events.ndjson.gz", events: ndjson.Bytes()},
+		{name: "CSV", format: "csv", file: "events.csv", events: []byte("type,account_id,amount,balance\nAccountCreated,Jack,,50\nAccountChargeReceived,Jack,25,\nAccountRecalled,Jack,,\n")},
+		{name: "Binary", format: "binary", file: "events.bin", events: binaryEvents(t, events, nil)},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := writeFile(t, tt.file, tt.events)
+			chain := filepath.Join(t.TempDir(), "chain.ndjson")
+			_, exitCode := runWorker(t, "-input", input, "-input-format", tt.format, "-chain", chain)
+			require.Equal(t, 0, exitCode)
+
+			stdout, exitCode := runWorker(t, "verify", "-chain", chain, "-input-format", tt.format, input)
+			assert.Equal(t, 0, exitCode)
+			assert.Contains(t, stdout, "default: 3 events verified")
+
+			// The chain of the same events in JSON is the same, since it encodes them canonically.
+			data, err := json.Marshal(events)
+			require.NoError(t, err)
+			_, exitCode = runWorker(t, "verify", "-chain", chain, writeFile(t, "events.json", data))
+			assert.Equal(t, 0, exitCode)
+
+			// A truncated input diverges, or cannot be read.
+			stdout, exitCode = runWorker(t, "verify", "-chain", chain, "-input-format", tt.format, writeFile(t, tt.file, tt.events[:len(tt.events)/2]))
+			assert.NotEqual(t, 0, exitCode, stdout)
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/reporting"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/tenant"
+)
+
+// pseudonymSaltEnv names the environment variable holding the secret salt account IDs are pseudonymized with.
+const pseudonymSaltEnv = "PSEUDONYM_SALT"
+
+// loadPseudonymizer returns a pseudonymizer keyed by the salt in $PSEUDONYM_SALT if `pseudonymize` is set, or nil.
+func loadPseudonymizer(pseudonymize bool) (*reporting.Pseudonymizer, error) {
+	if !pseudonymize {
+		return nil, nil
+	}
+
+	salt := os.Getenv(pseudonymSaltEnv)
+	if salt == "" {
+		return nil, fmt.Errorf("-pseudonymize needs a salt in $%s", pseudonymSaltEnv)
+	}
+
+	return reporting.NewPseudonymizer([]byte(salt)), nil
+}
+
+// accountPseudonym returns the pseudonym of an account of a tenant: the pseudonym of its tenant.AccountKey, which
+// accounts are printed by.
+func accountPseudonym(pseudonymizer *reporting.Pseudonymizer) func(tenantID, accountID string) string {
+	return func(tenantID, accountID string) string {
+		return pseudonymizer.Pseudonym(tenant.AccountKey(tenantID, accountID))
+	}
+}
+
+// pseudonymizeError returns the message of an error about the events of a tenant, with the account IDs it quotes,
+// e.g. `account with ID "Jack" does not exist`, replaced by their pseudonyms. Without a pseudonymizer, it is the
+// message as it is.
+func pseudonymizeError(err error, tenantID string, events []event.Event, pseudonymizer *reporting.Pseudonymizer) string {
+	if pseudonymizer == nil {
+		return err.Error()
+	}
+
+	pseudonym := accountPseudonym(pseudonymizer)
+	oldnew := []string{}
+	for _, e := range events {
+		if e.TenantID == tenantID && e.AccountID != "" {
+			oldnew = append(oldnew, `"`+e.AccountID+`"`, `"`+pseudonym(tenantID, e.AccountID)+`"`)
+		}
+	}
+
+	return strings.NewReplacer(oldnew...).Replace(err.Error())
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/repl"
+)
+
+// runREPL steps through events interactively, reading commands from stdin. Nothing is written: the accounts it
+// builds only live as long as the session. It exits with 0 once stdin ends or on `quit`, 1 on failure, and 2 on bad usage.
+func runREPL(args []string) int {
+	flags := flag.NewFlagSet("repl", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: repl [flags] [events.json]")
+		fmt.Fprintln(flags.Output(), "Steps through events one at a time. Type help for the commands.")
+		flags.PrintDefaults()
+	}
+	tenantID := flags.String("tenant", "", "if set, only accept events of this tenant, as the worker does for each tenant")
+	schemaPath := flags.String("schema", "", "if set, upcast event type aliases and versions with the JSON array of upcasters in this file instead of the default schema")
+	asOf := flags.String("as-of", "", "time to apply events as of, in RFC 3339 or as a date, as with the worker. Defaults to now")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+
+	clock, err := newClock(*asOf)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	serviceOpts := []event.ServiceOption{event.WithClock(clock), event.WithTenant(*tenantID)}
+	if *schemaPath != "" {
+		schema, err := loadSchema(*schemaPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		serviceOpts = append(serviceOpts, event.WithSchema(schema))
+	}
+
+	// Like -input, `load` takes comma-separated paths or glob patterns of parts, possibly compressed.
+	parse := event.NewService(serviceOpts...).ParseEvents
+	session := repl.New(
+		repl.WithServiceOptions(serviceOpts...),
+		repl.WithLoader(func(path string) ([]event.Event, error) {
+			return readInputEvents(parse, strings.Split(path, ",")...)
+		}),
+	)
+
+	if flags.NArg() == 1 {
+		events, err := readInputEvents(parse, strings.Split(flags.Arg(0), ",")...)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		session.Load(events)
+		fmt.Printf("loaded %d events\n", len(events))
+	}
+
+	if err := session.Run(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
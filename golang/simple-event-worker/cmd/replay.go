@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/deadletter"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/tenant"
+)
+
+// runReplayDLQ feeds the events of a dead-letter queue written with -dlq to the worker again, e.g. once the events
+// they were missing arrived, on top of the accounts of the run that quarantined them.
+// It exits with 0 if every event is applied, 1 on failure, 2 on bad usage, and 3 if events are quarantined again.
+func runReplayDLQ(args []string) int {
+	flags := flag.NewFlagSet("replay-dlq", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: replay-dlq [flags] <dlq.ndjson>")
+		fmt.Fprintln(flags.Output(), "Processes the events of a dead-letter queue written with -dlq again, in the order they were quarantined.")
+		flags.PrintDefaults()
+	}
+	snapshotPath := flags.String("snapshot", "", "if set, apply the events on top of the snapshot written with -output, {tenant} included; tenants without a snapshot start from no accounts")
+	outputPath := flags.String("output", "", "if set, write a JSON snapshot of the accounts after the replay to this file; with several tenants, {tenant} in the path is replaced by the tenant ID")
+	dlqPath := flags.String("dlq", "", "if set, append the events rejected again to this NDJSON dead-letter queue instead of failing; it must not be the replayed one")
+	schemaPath := flags.String("schema", "", "if set, upcast event type aliases and versions with the JSON array of upcasters in this file instead of the default schema")
+	asOf := flags.String("as-of", "", "time to apply events as of, in RFC 3339 or as a date; the pending events of the snapshot due by then are applied first. Defaults to now")
+	keyPath := encryptionKeyFlag(flags)
+	pseudonymize := flags.Bool("pseudonymize", false, "print accounts by pseudonyms of their IDs, keyed by the secret salt in $"+pseudonymSaltEnv+"; snapshots keep their IDs")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	if *dlqPath != "" && filepath.Clean(*dlqPath) == filepath.Clean(flags.Arg(0)) {
+		fmt.Fprintln(os.Stderr, "-dlq must not be the replayed dead-letter queue")
+		return 2
+	}
+
+	clock, err := newClock(*asOf)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	key, err := loadKey(*keyPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	pseudonymizer, err := loadPseudonymizer(*pseudonymize)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	letters, err := readDeadLetters(flags.Arg(0), key)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	serviceOpts := []event.ServiceOption{event.WithClock(clock)}
+	if *schemaPath != "" {
+		schema, err := loadSchema(*schemaPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		serviceOpts = append(serviceOpts, event.WithSchema(schema))
+	}
+
+	var dlq *deadletter.NDJSONWriter
+	if *dlqPath != "" {
+		dlqFile, err := appendFile(*dlqPath, key)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer dlqFile.Close()
+		dlq = deadletter.NewNDJSONWriter(dlqFile)
+		serviceOpts = append(serviceOpts, event.WithDeadLetterSink(dlq))
+	}
+
+	// As in the worker, events are validated while processing, so an invalid event only fails the events of its tenant.
+	events, err := event.NewService(append(serviceOpts, event.WithParseValidation(false))...).ParseEvents(bytes.NewReader(deadletter.Events(letters)))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	initial := map[string]map[string]event.Account{}
+	queues := map[string]*event.PendingQueue{}
+	processorOpts := []tenant.Option{tenant.WithDefaultPolicy(serviceOpts...)}
+	for tenantID := range tenant.Split(events) {
+		queues[tenantID] = event.NewPendingQueue()
+		if *snapshotPath != "" {
+			accounts, queue, err := loadSnapshotWithPending(tenantPath(*snapshotPath, tenantID), key)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+			if err == nil {
+				initial[tenantID], queues[tenantID] = accounts, queue
+			}
+		}
+		processorOpts = append(processorOpts, tenant.WithPolicy(tenantID, event.WithPendingQueue(queues[tenantID])))
+	}
+
+	result := tenant.NewProcessor(processorOpts...).ApplyEvents(initial, events)
+	if len(result.Errors) > 0 {
+		for _, tenantID := range slices.Sorted(maps.Keys(result.Errors)) {
+			err := &tenant.ErrTenantFailed{TenantID: tenantID, Err: result.Errors[tenantID]}
+			fmt.Fprintln(os.Stderr, pseudonymizeError(err, tenantID, events, pseudonymizer))
+		}
+		return 1
+	}
+
+	if *outputPath == "" {
+		accounts := result.All()
+		if pseudonymizer != nil {
+			accounts = pseudonymizer.Accounts(accounts)
+		}
+		printAccounts(event.NewAccountSet(accounts))
+		for _, tenantID := range result.Tenants() {
+			printPending(tenantID, queues[tenantID], pseudonymizer)
+		}
+	} else {
+		if len(result.Tenants()) > 1 && !strings.Contains(*outputPath, tenantPlaceholder) {
+			fmt.Fprintf(os.Stderr, "-output must contain %s with several tenants\n", tenantPlaceholder)
+			return 1
+		}
+		for _, tenantID := range result.Tenants() {
+			if err := writeSnapshot(tenantPath(*outputPath, tenantID), key, result.Accounts[tenantID], event.WithSnapshotPendingQueue(queues[tenantID])); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+		}
+	}
+
+	if dlq != nil && dlq.Quarantined() > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d events quarantined again to %s\n", dlq.Quarantined(), len(letters), *dlqPath)
+		return exitQuarantined
+	}
+
+	return 0
+}
+
+func readDeadLetters(path string, key []byte) ([]event.DeadLetter, error) {
+	file, err := openFile(path, key)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	letters, err := deadletter.ReadNDJSON(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return letters, nil
+}
+
+// loadSnapshotWithPending reads the accounts of a snapshot along with the events pending in it.
+func loadSnapshotWithPending(path string, key []byte) (map[string]event.Account, *event.PendingQueue, error) {
+	data, err := readFile(path, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	accounts, err := event.ReadSnapshot(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	queue, err := event.ReadSnapshotPendingQueue(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return accounts, queue, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/diff"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/input"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/tenant"
+)
+
+// runSimulate answers what-if questions, e.g. "what if we recall these accounts and these payments arrive", by applying
+// hypothetical events on top of the accounts of the real history. Nothing is written: it only prints the accounts the
+// hypothetical events would change. It exits with 0 if every hypothetical event applies, 1 on failure, and 2 on bad usage.
+func runSimulate(args []string) int {
+	flags := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: simulate [flags] <what-if.json>...")
+		fmt.Fprintln(flags.Output(), "Applies hypothetical events on top of the accounts of the history, and prints the accounts they would change.")
+		flags.PrintDefaults()
+	}
+	inputPath := flags.String("input", "events.json", "paths or glob patterns of the parts of the history, comma-separated, as with the worker")
+	format := flags.String("format", diff.FormatText, "format of the differences: text or json")
+	schemaPath := flags.String("schema", "", "if set, upcast event type aliases and versions with the JSON array of upcasters in this file instead of the default schema")
+	asOf := flags.String("as-of", "", "time to apply the history and the hypothetical events as of, in RFC 3339 or as a date, as with the worker. Defaults to now")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	clock, err := newClock(*asOf)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	serviceOpts := []event.ServiceOption{event.WithClock(clock)}
+	if *schemaPath != "" {
+		schema, err := loadSchema(*schemaPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		serviceOpts = append(serviceOpts, event.WithSchema(schema))
+	}
+
+	// As in the worker, events are validated while processing, so an invalid event only fails the events of its tenant.
+	parse := event.NewService(append(serviceOpts, event.WithParseValidation(false))...).ParseEvents
+	history, err := readInputEvents(parse, strings.Split(*inputPath, ",")...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	whatIf, err := readInputEvents(parse, flags.Args()...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	// The hypothetical events are applied by the same services as the history, without an outbox, a dead-letter queue
+	// or a hash chain, so a rejected event, e.g. a payment of a recalled account, fails its tenant like it would in the worker.
+	// The events the history parked stay pending for them.
+	processorOpts := []tenant.Option{tenant.WithDefaultPolicy(serviceOpts...)}
+	for tenantID := range tenant.Split(slices.Concat(history, whatIf)) {
+		processorOpts = append(processorOpts, tenant.WithPolicy(tenantID, event.WithPendingQueue(event.NewPendingQueue())))
+	}
+	processor := tenant.NewProcessor(processorOpts...)
+	before := processor.ProcessEvents(history)
+	if err := before.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "cannot process the history: %s\n", err)
+		return 1
+	}
+	after := processor.ApplyEvents(before.Accounts, whatIf)
+	if err := after.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "cannot apply the hypothetical events: %s\n", err)
+		return 1
+	}
+
+	if err := diff.Compare(before.All(), after.All()).Write(os.Stdout, *format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+// readInputEvents reads the events of the parts named by `patterns` as a single input, like the worker does with -input.
+func readInputEvents(parse func(io.Reader) ([]event.Event, error), patterns ...string) ([]event.Event, error) {
+	parts, err := input.Resolve(patterns...)
+	if err != nil {
+		return nil, err
+	}
+
+	return input.Read(parts, parse)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/input"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/tenant"
+)
+
+// runVerify checks that an input of events, possibly in several parts as with -input and in any -input-format, is the one
+// a run of the worker with -chain processed, by computing its hash chain again. Like diff(1), it exits with 0 if the
+// events are the same, 1 if they diverge, and 2 on failure.
+func runVerify(args []string) int {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: verify [flags] <events>...")
+		fmt.Fprintln(flags.Output(), "Computes the hash chain of the events again and compares it with the one recorded by a run with -chain.")
+		flags.PrintDefaults()
+	}
+	chainPath := flags.String("chain", "", "hash chain written with -chain, {tenant} included; reports the first event that diverges")
+	snapshotPath := flags.String("snapshot", "", "snapshot written with -output and -chain, {tenant} included; only tells whether the events diverge")
+	schemaPath := flags.String("schema", "", "JSON array of upcasters the events were processed with, if not the default schema")
+	inputFormat := inputFormatFlags(flags)
+	keyPath := encryptionKeyFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 || (*chainPath == "" && *snapshotPath == "") {
+		flags.Usage()
+		return 2
+	}
+
+	key, err := loadKey(*keyPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	// The events are parsed like the worker does with -dlq, which leaves events rejected while parsing out of the chain.
+	serviceOpts := []event.ServiceOption{event.WithParseValidation(false), event.WithDeadLetterSink(discardDeadLetters{})}
+	if *schemaPath != "" {
+		schema, err := loadSchema(*schemaPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		serviceOpts = append(serviceOpts, event.WithSchema(schema))
+	}
+
+	parts, err := input.Resolve(flags.Args()...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	events, err := inputFormat.readEvents(parts, event.NewService(serviceOpts...), key)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	code := 0
+	batches := tenant.Split(events)
+	for _, tenantID := range slices.Sorted(maps.Keys(batches)) {
+		chain := event.NewHashChain()
+		if err := chain.Append(batches[tenantID]...); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+
+		diverged, err := verifyTenant(tenantID, chain, *chainPath, *snapshotPath, key)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		if diverged {
+			code = 1
+		}
+	}
+
+	return code
+}
+
+// verifyTenant compares the chain of the events of a tenant with the ones recorded for it, and reports the result.
+// The index of the first event that diverges is an index within the events of the tenant.
+func verifyTenant(tenantID string, chain *event.HashChain, chainPattern, snapshotPattern string, key []byte) (bool, error) {
+	// Like the paths of its outputs, a tenant is named "default" if the events have no TenantID.
+	name := tenantPath(tenantPlaceholder, tenantID)
+
+	if chainPattern != "" {
+		recorded, err := readHashChain(tenantPath(chainPattern, tenantID), key)
+		if err != nil {
+			return false, err
+		}
+		if index, ok := chain.Diverges(recorded); ok {
+			fmt.Printf("%s: events diverge from the hash chain at event %d\n", name, index)
+			return true, nil
+		}
+	}
+
+	if snapshotPattern != "" {
+		head, err := readSnapshotChainHead(tenantPath(snapshotPattern, tenantID), key)
+		if err != nil {
+			return false, err
+		}
+		if head.Events != chain.Len() || head.Head != chain.Head() {
+			fmt.Printf("%s: events diverge from the head of the hash chain in the snapshot\n", name)
+			return true, nil
+		}
+	}
+
+	fmt.Printf("%s: %d events verified, head %s\n", name, chain.Len(), chain.Head())
+	return false, nil
+}
+
+func readHashChain(path string, key []byte) (*event.HashChain, error) {
+	file, err := openFile(path, key)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	chain, err := event.ReadHashChain(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return chain, nil
+}
+
+func readSnapshotChainHead(path string, key []byte) (*event.ChainHead, error) {
+	file, err := openFile(path, key)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	head, err := event.ReadSnapshotChainHead(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if head == nil {
+		return nil, fmt.Errorf("%s: no hash chain recorded, run the worker with -chain", path)
+	}
+
+	return head, nil
+}
+
+// discardDeadLetters drops the events rejected while parsing.
+type discardDeadLetters struct{}
+
+func (discardDeadLetters) Quarantine([]event.DeadLetter) error {
+	return nil
+}
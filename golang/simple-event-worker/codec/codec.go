@@ -0,0 +1,428 @@
+// Package codec implements a compact binary encoding of events, for inputs too big to parse as JSON quickly.
+//
+// A stream starts with a header, the magic bytes "SEWB" followed by a version byte,
+// and is followed by one length-prefixed record per event:
+//
+//	record  = uvarint(len(body)) body
+//	body    = type accountID [tenantID] [timestamp] [effectiveAt] [amount] [invoice]
+//	type    = 1 byte: 1 AccountCreated, 2 AccountChargeReceived, 3 AccountPaymentReceived, 4 AccountRecalled,
+//	          5 AccountWrittenOff, 6 AccountRecoveryReceived, with the high bit (0x80) set if the event has a tenantID, 0x40 if it has a timestamp,
+//	          0x20 if it has an invoice, and 0x10 if it has an effectiveAt
+//	accountID = uvarint(len(id)) id
+//	tenantID  = uvarint(len(id)) id
+//	timestamp = uvarint(len(t)) t, the Timestamp of the event in RFC 3339, so its offset is kept
+//	effectiveAt = uvarint(len(t)) t, the EffectiveAt of the event in RFC 3339
+//	amount  = zigzag varint, the Balance or Amount of the payload. AccountRecalled and AccountWrittenOff have none.
+//	invoice = uvarint(len(ref)) ref, the Invoice of a charge, payment or recovery
+//
+// Integers are varints as in encoding/binary (and protobuf). An empty stream holds no events.
+package codec
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+)
+
+const (
+	magic   = "SEWB"
+	version = 1
+
+	// MaxRecordSize bounds the size of a record, so a corrupted length cannot make the decoder allocate without limit.
+	MaxRecordSize = 1 << 20
+)
+
+const (
+	tagAccountCreated byte = iota + 1
+	tagAccountChargeReceived
+	tagAccountPaymentReceived
+	tagAccountRecalled
+	tagAccountWrittenOff
+	tagAccountRecoveryReceived
+
+	// flagTenant is set on the type of an event with a TenantID. Events without one encode as before tenants existed.
+	flagTenant byte = 0x80
+	// flagTimestamp, flagInvoice and flagEffectiveAt are set on the type of an event with a Timestamp, an Invoice and an
+	// EffectiveAt, likewise.
+	flagTimestamp   byte = 0x40
+	flagInvoice     byte = 0x20
+	flagEffectiveAt byte = 0x10
+)
+
+var (
+	ErrInvalidHeader  = errors.New("codec: invalid header")
+	ErrRecordTooLarge = errors.New("codec: record too large")
+)
+
+type ErrInvalidRecord struct {
+	// Index is the index of the record in the stream.
+	Index  int
+	Reason string
+}
+
+func (e *ErrInvalidRecord) Error() string {
+	return fmt.Sprintf("codec: invalid record %d: %s", e.Index, e.Reason)
+}
+
+// Encoder writes events to a stream. The header is written along with the first event.
+type Encoder struct {
+	w             io.Writer
+	body          []byte
+	record        []byte
+	headerWritten bool
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes a single event, with a single Write. The event must be valid.
+func (enc *Encoder) Encode(e event.Event) error {
+	if err := e.Validate(); err != nil {
+		return err
+	}
+
+	// The scratch buffers are reused across events.
+	body, err := appendBody(enc.body[:0], e)
+	if err != nil {
+		return err
+	}
+	enc.body = body
+
+	record := enc.record[:0]
+	if !enc.headerWritten {
+		record = append(record, magic...)
+		record = append(record, version)
+	}
+	record = binary.AppendUvarint(record, uint64(len(body)))
+	record = append(record, body...)
+	enc.record = record
+
+	if _, err := enc.w.Write(record); err != nil {
+		return err
+	}
+	enc.headerWritten = true
+
+	return nil
+}
+
+// appendBody appends the body of a record for `e`, which has been validated, so its payload matches its type.
+func appendBody(buf []byte, e event.Event) ([]byte, error) {
+	var tag byte
+	var amount int
+	var invoice string
+	switch e.Type {
+	case event.EventTypeAccountCreated:
+		tag = tagAccountCreated
+		amount = e.Payload.(*event.EventPayloadAccountCreated).Balance
+	case event.EventTypeAccountChargeReceived:
+		tag = tagAccountChargeReceived
+		payload := e.Payload.(*event.EventPayloadAccountTransactionReceived)
+		amount, invoice = payload.Amount, payload.Invoice
+	case event.EventTypeAccountPaymentReceived:
+		tag = tagAccountPaymentReceived
+		payload := e.Payload.(*event.EventPayloadAccountTransactionReceived)
+		amount, invoice = payload.Amount, payload.Invoice
+	case event.EventTypeAccountRecalled:
+		tag = tagAccountRecalled
+	case event.EventTypeAccountWrittenOff:
+		tag = tagAccountWrittenOff
+	case event.EventTypeAccountRecoveryReceived:
+		tag = tagAccountRecoveryReceived
+		payload := e.Payload.(*event.EventPayloadAccountTransactionReceived)
+		amount, invoice = payload.Amount, payload.Invoice
+	default:
+		return nil, &event.ErrUnsupportedEventType{Type: e.Type}
+	}
+
+	var timestamp, effectiveAt []byte
+	if !e.Timestamp.IsZero() {
+		var err error
+		if timestamp, err = e.Timestamp.MarshalText(); err != nil {
+			return nil, err
+		}
+	}
+	if !e.EffectiveAt.IsZero() {
+		var err error
+		if effectiveAt, err = e.EffectiveAt.MarshalText(); err != nil {
+			return nil, err
+		}
+	}
+
+	flags := byte(0)
+	if e.TenantID != "" {
+		flags |= flagTenant
+	}
+	if timestamp != nil {
+		flags |= flagTimestamp
+	}
+	if invoice != "" {
+		flags |= flagInvoice
+	}
+	if effectiveAt != nil {
+		flags |= flagEffectiveAt
+	}
+	buf = append(buf, tag|flags)
+	buf = appendString(buf, e.AccountID)
+	if e.TenantID != "" {
+		buf = appendString(buf, e.TenantID)
+	}
+	if timestamp != nil {
+		buf = appendString(buf, string(timestamp))
+	}
+	if effectiveAt != nil {
+		buf = appendString(buf, string(effectiveAt))
+	}
+	if tag != tagAccountRecalled && tag != tagAccountWrittenOff {
+		buf = binary.AppendVarint(buf, int64(amount))
+	}
+	if invoice != "" {
+		buf = appendString(buf, invoice)
+	}
+
+	return buf, nil
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// Decoder reads events from a stream written by an Encoder.
+type Decoder struct {
+	r          *bufio.Reader
+	buf        []byte
+	index      int
+	headerRead bool
+	validate   bool
+	// body is the body of the record of the last call to Decode, or nil if it read no whole record.
+	body []byte
+}
+
+type DecoderOption func(*Decoder)
+
+// WithValidation toggles the validation of the decoded events. It is enabled by default. Without it, an invalid event is
+// returned as it is, for the service to reject while processing it, like event.WithParseValidation(false) does for JSON.
+func WithValidation(enabled bool) DecoderOption {
+	return func(dec *Decoder) {
+		dec.validate = enabled
+	}
+}
+
+func NewDecoder(r io.Reader, opts ...DecoderOption) *Decoder {
+	dec := &Decoder{r: bufio.NewReader(r), validate: true}
+	for _, opt := range opts {
+		opt(dec)
+	}
+
+	return dec
+}
+
+// Decode reads the next event. It returns io.EOF at the end of the stream,
+// and io.ErrUnexpectedEOF if the stream ends in the middle of a record.
+// Decoded events are validated, as with event.Service.ParseEvents, unless disabled with WithValidation.
+func (dec *Decoder) Decode() (event.Event, error) {
+	dec.body = nil
+
+	if !dec.headerRead {
+		if err := dec.readHeader(); err != nil {
+			return event.Event{}, err
+		}
+		dec.headerRead = true
+	}
+
+	size, err := binary.ReadUvarint(dec.r)
+	if errors.Is(err, io.EOF) {
+		// ReadUvarint only returns io.EOF if the stream ended before the record.
+		return event.Event{}, io.EOF
+	}
+	if err != nil {
+		return event.Event{}, err
+	}
+	if size > MaxRecordSize {
+		return event.Event{}, ErrRecordTooLarge
+	}
+
+	if cap(dec.buf) < int(size) {
+		dec.buf = make([]byte, size)
+	}
+	body := dec.buf[:size]
+	if _, err := io.ReadFull(dec.r, body); err != nil {
+		return event.Event{}, unexpected(err)
+	}
+
+	// The record is read whole, so the next one can be decoded even if this one is rejected.
+	dec.body = body
+	e, err := dec.parseBody(body)
+	dec.index++
+	if err != nil {
+		return event.Event{}, err
+	}
+	if dec.validate {
+		if err := e.Validate(); err != nil {
+			return event.Event{}, err
+		}
+	}
+
+	return e, nil
+}
+
+// Raw returns the body of the record of the last call to Decode as a JSON string of its bytes in base64, so a rejected
+// record can be quarantined, e.g. by event.EventService.DecodeEvents. It is nil if the call read no whole record.
+func (dec *Decoder) Raw() []byte {
+	if dec.body == nil {
+		return nil
+	}
+
+	raw, err := json.Marshal(dec.body)
+	if err != nil {
+		return nil
+	}
+
+	return raw
+}
+
+func (dec *Decoder) readHeader() error {
+	header := make([]byte, len(magic)+1)
+	n, err := io.ReadFull(dec.r, header)
+	if n == 0 && errors.Is(err, io.EOF) {
+		return io.EOF
+	}
+	if err != nil || string(header[:len(magic)]) != magic {
+		return ErrInvalidHeader
+	}
+	if header[len(magic)] != version {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidHeader, header[len(magic)])
+	}
+
+	return nil
+}
+
+func (dec *Decoder) parseBody(body []byte) (event.Event, error) {
+	if len(body) == 0 {
+		return event.Event{}, &ErrInvalidRecord{Index: dec.index, Reason: "empty record"}
+	}
+	tag, body := body[0], body[1:]
+	hasTenant, hasTimestamp, hasInvoice, hasEffectiveAt := tag&flagTenant != 0, tag&flagTimestamp != 0, tag&flagInvoice != 0, tag&flagEffectiveAt != 0
+	tag &^= flagTenant | flagTimestamp | flagInvoice | flagEffectiveAt
+
+	accountID, body, ok := readString(body)
+	if !ok {
+		return event.Event{}, &ErrInvalidRecord{Index: dec.index, Reason: "invalid account ID"}
+	}
+	var tenantID string
+	if hasTenant {
+		if tenantID, body, ok = readString(body); !ok || tenantID == "" {
+			return event.Event{}, &ErrInvalidRecord{Index: dec.index, Reason: "invalid tenant ID"}
+		}
+	}
+
+	var timestamp time.Time
+	if hasTimestamp {
+		var text string
+		if text, body, ok = readString(body); !ok || timestamp.UnmarshalText([]byte(text)) != nil || timestamp.IsZero() {
+			return event.Event{}, &ErrInvalidRecord{Index: dec.index, Reason: "invalid timestamp"}
+		}
+	}
+	var effectiveAt time.Time
+	if hasEffectiveAt {
+		var text string
+		if text, body, ok = readString(body); !ok || effectiveAt.UnmarshalText([]byte(text)) != nil || effectiveAt.IsZero() {
+			return event.Event{}, &ErrInvalidRecord{Index: dec.index, Reason: "invalid effective time"}
+		}
+	}
+
+	if tag == tagAccountRecalled || tag == tagAccountWrittenOff {
+		if len(body) != 0 || hasInvoice {
+			return event.Event{}, &ErrInvalidRecord{Index: dec.index, Reason: "trailing bytes"}
+		}
+		e := event.NewRecalledEvent(accountID)
+		if tag == tagAccountWrittenOff {
+			e = event.NewWrittenOffEvent(accountID)
+		}
+		return e.InTenant(tenantID).At(timestamp).EffectiveFrom(effectiveAt), nil
+	}
+
+	amount, n := binary.Varint(body)
+	if n <= 0 || (!hasInvoice && n != len(body)) {
+		return event.Event{}, &ErrInvalidRecord{Index: dec.index, Reason: "invalid amount"}
+	}
+	body = body[n:]
+	var invoice string
+	if hasInvoice {
+		if invoice, body, ok = readString(body); !ok || invoice == "" || len(body) != 0 {
+			return event.Event{}, &ErrInvalidRecord{Index: dec.index, Reason: "invalid invoice"}
+		}
+	}
+
+	var e event.Event
+	switch tag {
+	case tagAccountCreated:
+		if hasInvoice {
+			return event.Event{}, &ErrInvalidRecord{Index: dec.index, Reason: "invoice of an AccountCreated event"}
+		}
+		e = event.NewAccountCreatedEvent(accountID, int(amount))
+	case tagAccountChargeReceived:
+		e = event.NewChargeEvent(accountID, int(amount)).ForInvoice(invoice)
+	case tagAccountPaymentReceived:
+		e = event.NewPaymentEvent(accountID, int(amount)).ForInvoice(invoice)
+	case tagAccountRecoveryReceived:
+		e = event.NewRecoveryEvent(accountID, int(amount)).ForInvoice(invoice)
+	default:
+		return event.Event{}, &ErrInvalidRecord{Index: dec.index, Reason: fmt.Sprintf("unknown event type tag %d", tag)}
+	}
+
+	return e.InTenant(tenantID).At(timestamp).EffectiveFrom(effectiveAt), nil
+}
+
+// readString reads a length-prefixed string from the start of body, and returns the rest of body.
+func readString(body []byte) (string, []byte, bool) {
+	size, n := binary.Uvarint(body)
+	if n <= 0 || size > uint64(len(body)-n) {
+		return "", nil, false
+	}
+
+	return string(body[n : n+int(size)]), body[n+int(size):], true
+}
+
+func unexpected(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// ReadEvents decodes every event of a stream.
+func ReadEvents(r io.Reader, opts ...DecoderOption) ([]event.Event, error) {
+	events := []event.Event{}
+
+	dec := NewDecoder(r, opts...)
+	for {
+		e, err := dec.Decode()
+		if errors.Is(err, io.EOF) {
+			return events, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+}
+
+// WriteEvents encodes events to a stream.
+func WriteEvents(w io.Writer, events []event.Event) error {
+	enc := NewEncoder(w)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,294 @@
+package codec_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/codec"
+	"github.com/stretchr/testify/assert"
+)
+
+func testEvents() []event.Event {
+	return []event.Event{
+		event.NewAccountCreatedEvent("Jack", 50),
+		event.NewAccountCreatedEvent("Jen", 0),
+		event.NewChargeEvent("Jack", 25),
+		event.NewPaymentEvent("Jen", 1<<40),
+		event.NewRecalledEvent("Jack"),
+	}
+}
+
+func TestCodec_RoundTrip(t *testing.T) {
+	subtests := []struct {
+		name   string
+		events []event.Event
+	}{
+		{name: "MultipleEvents", events: testEvents()},
+		{name: "NoEvents", events: []event.Event{}},
+		{name: "Tenants", events: []event.Event{
+			event.NewAccountCreatedEvent("Jack", 50).InTenant("acme"),
+			event.NewChargeEvent("Jack", 25),
+			event.NewRecalledEvent("Jack").InTenant("globex"),
+		}},
+		{name: "InvoicesAndTimestamps", events: []event.Event{
+			event.NewAccountCreatedEvent("Jack", 50).At(time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)),
+			event.NewChargeEvent("Jack", 25).ForInvoice("INV-1").At(time.Date(2026, time.March, 2, 9, 30, 0, 1, time.FixedZone("", 8*60*60))),
+			event.NewPaymentEvent("Jack", 25).ForInvoice("INV-1").InTenant("acme"),
+			event.NewRecalledEvent("Jack").At(time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC)),
+		}},
+		{name: "WriteOff", events: []event.Event{
+			event.NewWrittenOffEvent("Jack").At(time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)),
+			event.NewRecoveryEvent("Jack", 25).InTenant("acme"),
+			event.NewRecoveryEvent("Jack", 5).ForInvoice("INV-1"),
+		}},
+		{name: "EffectiveAt", events: []event.Event{
+			event.NewAccountCreatedEvent("Jack", 50).EffectiveFrom(time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)),
+			event.NewChargeEvent("Jack", 25).ForInvoice("INV-1").At(time.Date(2026, time.March, 2, 0, 0, 0, 0, time.UTC)).EffectiveFrom(time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC)),
+			event.NewRecalledEvent("Jack").InTenant("acme").EffectiveFrom(time.Date(2026, time.May, 1, 0, 0, 0, 0, time.FixedZone("", 8*60*60))),
+		}},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			assert.NoError(t, codec.WriteEvents(&buf, tt.events))
+
+			got, err := codec.ReadEvents(&buf)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.events, got)
+		})
+	}
+}
+
+func TestCodec_RoundTrip_EventsFile(t *testing.T) {
+	file, err := os.Open("../events.json")
+	assert.NoError(t, err)
+	defer file.Close()
+
+	s := event.NewService()
+	events, err := s.ParseEvents(file)
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	assert.NoError(t, codec.WriteEvents(&buf, events))
+
+	jsonData, err := json.Marshal(events)
+	assert.NoError(t, err)
+	assert.Less(t, buf.Len(), len(jsonData)/2)
+
+	got, err := codec.ReadEvents(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, events, got)
+}
+
+func TestCodec_Encode_CustomErrors(t *testing.T) {
+	subtests := []struct {
+		name  string
+		event event.Event
+		want  error
+	}{
+		{
+			name:  "ErrUnsupportedEventType",
+			event: event.Event{Type: "AccountCreate", AccountID: "Jack"},
+			want:  &event.ErrUnsupportedEventType{Type: "AccountCreate"},
+		},
+		{
+			name:  "ErrInvalidPayload",
+			event: event.Event{Type: event.EventTypeAccountCreated, AccountID: "Jack"},
+			want:  &event.ErrInvalidPayload{Type: event.EventTypeAccountCreated, AccountID: "Jack", Payload: "<nil>"},
+		},
+		{
+			name:  "ErrInvalidAmount",
+			event: event.NewChargeEvent("Jack", -1),
+			want:  &event.ErrInvalidAmount{AccountID: "Jack", Field: event.EventPayloadFieldAmount, Amount: -1},
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := codec.NewEncoder(&buf).Encode(tt.event)
+			assert.Equal(t, tt.want, err)
+			assert.Zero(t, buf.Len())
+		})
+	}
+}
+
+func TestCodec_Decode_CustomErrors(t *testing.T) {
+	var valid bytes.Buffer
+	assert.NoError(t, codec.WriteEvents(&valid, testEvents()[:1]))
+
+	subtests := []struct {
+		name  string
+		input []byte
+		want  error
+	}{
+		{
+			name:  "ErrInvalidHeader",
+			input: []byte("[{\"Type\":"),
+			want:  codec.ErrInvalidHeader,
+		},
+		{
+			name:  "ErrInvalidHeader UnsupportedVersion",
+			input: []byte("SEWB\x02"),
+			want:  codec.ErrInvalidHeader,
+		},
+		{
+			name:  "TruncatedRecord",
+			input: valid.Bytes()[:valid.Len()-1],
+			want:  io.ErrUnexpectedEOF,
+		},
+		{
+			name:  "ErrRecordTooLarge",
+			input: []byte("SEWB\x01\xff\xff\xff\xff\x0f"),
+			want:  codec.ErrRecordTooLarge,
+		},
+		{
+			name:  "UnknownTag",
+			input: []byte("SEWB\x01\x04\x09\x01J\x00"),
+			want:  &codec.ErrInvalidRecord{Index: 0, Reason: "unknown event type tag 9"},
+		},
+		{
+			name:  "InvalidAccountID",
+			input: []byte("SEWB\x01\x03\x01\x05J"),
+			want:  &codec.ErrInvalidRecord{Index: 0, Reason: "invalid account ID"},
+		},
+		{
+			name:  "InvalidTenantID",
+			input: []byte("SEWB\x01\x03\x84\x01J"),
+			want:  &codec.ErrInvalidRecord{Index: 0, Reason: "invalid tenant ID"},
+		},
+		{
+			name:  "EmptyTenantID",
+			input: []byte("SEWB\x01\x04\x84\x01J\x00"),
+			want:  &codec.ErrInvalidRecord{Index: 0, Reason: "invalid tenant ID"},
+		},
+		{
+			name:  "InvalidTimestamp",
+			input: []byte("SEWB\x01\x06\x44\x01J\x03bad"),
+			want:  &codec.ErrInvalidRecord{Index: 0, Reason: "invalid timestamp"},
+		},
+		{
+			name:  "InvalidEffectiveAt",
+			input: []byte("SEWB\x01\x06\x14\x01J\x03bad"),
+			want:  &codec.ErrInvalidRecord{Index: 0, Reason: "invalid effective time"},
+		},
+		{
+			name:  "EmptyInvoice",
+			input: []byte("SEWB\x01\x05\x22\x01J\x32\x00"),
+			want:  &codec.ErrInvalidRecord{Index: 0, Reason: "invalid invoice"},
+		},
+		{
+			name:  "InvoiceOfAccountCreated",
+			input: []byte("SEWB\x01\x06\x21\x01J\x64\x01x"),
+			want:  &codec.ErrInvalidRecord{Index: 0, Reason: "invoice of an AccountCreated event"},
+		},
+		{
+			name:  "ErrEmptyAccountID",
+			input: []byte("SEWB\x01\x02\x04\x00"),
+			want:  &event.ErrEmptyAccountID{Type: event.EventTypeAccountRecalled},
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := codec.ReadEvents(bytes.NewReader(tt.input))
+			if !errors.Is(err, tt.want) {
+				assert.Equal(t, tt.want, err)
+			}
+		})
+	}
+}
+
+func TestCodec_Decode_WithValidation(t *testing.T) {
+	// A recall of an empty AccountID, then a record of an unknown type.
+	input := []byte("SEWB\x01\x02\x04\x00\x04\x09\x01J\x00")
+
+	// Invalid events are left to the service, which only fails the events of their tenant.
+	dec := codec.NewDecoder(bytes.NewReader(input), codec.WithValidation(false))
+	e, err := dec.Decode()
+	assert.NoError(t, err)
+	assert.Equal(t, event.NewRecalledEvent(""), e)
+
+	// A record that cannot make an event is still rejected, but is read whole, so it can be quarantined as base64.
+	_, err = dec.Decode()
+	assert.Equal(t, &codec.ErrInvalidRecord{Index: 1, Reason: "unknown event type tag 9"}, err)
+	assert.Equal(t, `"CQFKAA=="`, string(dec.Raw()))
+
+	_, err = dec.Decode()
+	assert.Equal(t, io.EOF, err)
+	assert.Nil(t, dec.Raw())
+}
+
+// benchmarkEvents returns `n` events spread over accounts, as in a big input file.
+func benchmarkEvents(n int) []event.Event {
+	events := make([]event.Event, 0, n)
+	accounts := n / 10
+	for i := range accounts {
+		events = append(events, event.NewAccountCreatedEvent("550e8400-e29b-41d4-a716-446655440000-"+strconv.Itoa(i), 1000))
+	}
+	for i := 0; len(events) < n; i++ {
+		id := "550e8400-e29b-41d4-a716-446655440000-" + strconv.Itoa(i%accounts)
+		if i%2 == 0 {
+			events = append(events, event.NewChargeEvent(id, 100+i%50))
+		} else {
+			events = append(events, event.NewPaymentEvent(id, 100+i%70))
+		}
+	}
+
+	return events
+}
+
+const benchmarkSize = 10000
+
+func BenchmarkProcess_JSON(b *testing.B) {
+	data, err := json.Marshal(benchmarkEvents(benchmarkSize))
+	if err != nil {
+		b.Fatal(err)
+	}
+	s := event.NewService()
+
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for range b.N {
+		events, err := s.ParseEvents(bytes.NewReader(data))
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := s.ProcessEvents(events); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkProcess_Binary(b *testing.B) {
+	var buf bytes.Buffer
+	if err := codec.WriteEvents(&buf, benchmarkEvents(benchmarkSize)); err != nil {
+		b.Fatal(err)
+	}
+	data := buf.Bytes()
+	s := event.NewService()
+
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for range b.N {
+		events, err := codec.ReadEvents(bytes.NewReader(data))
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := s.ProcessEvents(events); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
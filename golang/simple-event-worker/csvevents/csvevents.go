@@ -0,0 +1,389 @@
+// Package csvevents reads events from CSV files, e.g. spreadsheets of charges and payments exported by finance teams.
+//
+// The first row is a header naming the columns. By default, the columns are:
+//
+//	type,tenant_id,account_id,amount,balance,invoice,timestamp,effective_at
+//
+// where `type` is an event type, e.g. AccountChargeReceived, `amount` is the Amount of a charge, payment or
+// recovery, `balance` is the Balance of an AccountCreated event, `invoice` is the Invoice a charge or payment references,
+// `timestamp` is the Timestamp of the event, in RFC 3339 or as a date like 2026-03-01, and `effective_at` is the
+// EffectiveAt of a scheduled event, in the same formats. Columns are found by name, in
+// any order, and other columns are ignored, so only `type` and `account_id` are required. A cell that does not apply
+// to the event type is ignored too.
+package csvevents
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+)
+
+// Columns maps the fields of events to the names of their columns in the header.
+// Header names are matched case-insensitively, ignoring surrounding spaces.
+type Columns struct {
+	Type        string
+	TenantID    string
+	AccountID   string
+	Amount      string
+	Balance     string
+	Invoice     string
+	Timestamp   string
+	EffectiveAt string
+}
+
+func DefaultColumns() Columns {
+	return Columns{
+		Type:        "type",
+		TenantID:    "tenant_id",
+		AccountID:   "account_id",
+		Amount:      "amount",
+		Balance:     "balance",
+		Invoice:     "invoice",
+		Timestamp:   "timestamp",
+		EffectiveAt: "effective_at",
+	}
+}
+
+// ParseColumns overrides the default columns with a comma-separated list of field=name pairs,
+// e.g. "type=Kind,account_id=Customer". The fields are the ones of the default header.
+func ParseColumns(s string) (Columns, error) {
+	c := DefaultColumns()
+	if strings.TrimSpace(s) == "" {
+		return c, nil
+	}
+
+	fields := map[string]*string{
+		"type":         &c.Type,
+		"tenant_id":    &c.TenantID,
+		"account_id":   &c.AccountID,
+		"amount":       &c.Amount,
+		"balance":      &c.Balance,
+		"invoice":      &c.Invoice,
+		"timestamp":    &c.Timestamp,
+		"effective_at": &c.EffectiveAt,
+	}
+	for _, pair := range strings.Split(s, ",") {
+		field, name, ok := strings.Cut(pair, "=")
+		column, known := fields[strings.TrimSpace(field)]
+		if !ok || !known || strings.TrimSpace(name) == "" {
+			return Columns{}, fmt.Errorf(`csv: invalid column mapping "%s": want field=name with a field of the default header`, pair)
+		}
+		*column = strings.TrimSpace(name)
+	}
+
+	return c, nil
+}
+
+type ErrMissingColumn struct {
+	// Row is the line of the first row whose event type requires the column, or 0 if the header lacks a column every
+	// row requires.
+	Row    int
+	Column string
+}
+
+func (e *ErrMissingColumn) Error() string {
+	if e.Row > 0 {
+		return fmt.Sprintf(`csv: row %d: missing column "%s" in header`, e.Row, e.Column)
+	}
+	return fmt.Sprintf(`csv: missing column "%s" in header`, e.Column)
+}
+
+// ErrInvalidCell reports the cell an event was rejected for. Row is the line its row starts on, counting the header
+// as line 1, which is its row in a spreadsheet unless a cell above it spans several lines.
+type ErrInvalidCell struct {
+	Row    int
+	Column string
+	Err    error
+}
+
+func (e *ErrInvalidCell) Error() string {
+	return fmt.Sprintf(`csv: row %d, column "%s": %s`, e.Row, e.Column, e.Err)
+}
+
+func (e *ErrInvalidCell) Unwrap() error {
+	return e.Err
+}
+
+// Option configures a Decoder.
+type Option func(*Decoder)
+
+// WithColumns sets the names of the columns of the header. By default, they are DefaultColumns.
+func WithColumns(columns Columns) Option {
+	return func(d *Decoder) {
+		d.columns = columns
+	}
+}
+
+// WithValidation toggles the validation of the events read, e.g. of their amounts and AccountIDs. It is enabled by
+// default, like the parse validation of an EventService. Without it, an event that is well-formed but invalid is
+// returned as it is, for the service to reject while processing it, like event.WithParseValidation(false) does for JSON.
+// Cells that cannot make an event, e.g. an unknown type or an amount that is not an integer, are still rejected.
+func WithValidation(enabled bool) Option {
+	return func(d *Decoder) {
+		d.validate = enabled
+	}
+}
+
+// WithComma sets the separator of cells, e.g. ';' for spreadsheets exported in locales with decimal commas.
+func WithComma(comma rune) Option {
+	return func(d *Decoder) {
+		d.r.Comma = comma
+	}
+}
+
+// Decoder reads events from CSV rows, one event per row.
+type Decoder struct {
+	r       *csv.Reader
+	columns Columns
+	// indexes holds the index of each column by name, or -1 if the header does not have it.
+	indexes  map[string]int
+	validate bool
+	// header and record are the header and the row of the last call to Decode, or a nil record if it read no row.
+	header []string
+	record []string
+}
+
+func NewDecoder(r io.Reader, opts ...Option) *Decoder {
+	d := &Decoder{r: csv.NewReader(r), columns: DefaultColumns(), validate: true}
+	d.r.TrimLeadingSpace = true
+	d.r.ReuseRecord = true
+	// Spreadsheets leave out the trailing empty cells of some rows.
+	d.r.FieldsPerRecord = -1
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
+}
+
+// readHeader finds the columns in the header.
+func (d *Decoder) readHeader() error {
+	header, err := d.r.Read()
+	if errors.Is(err, io.EOF) {
+		return &ErrMissingColumn{Column: d.columns.Type}
+	}
+	if err != nil {
+		return err
+	}
+
+	d.header = make([]string, len(header))
+	for i, cell := range header {
+		d.header[i] = strings.TrimSpace(strings.TrimPrefix(cell, "\ufeff"))
+	}
+
+	d.indexes = map[string]int{}
+	for _, name := range []string{d.columns.Type, d.columns.TenantID, d.columns.AccountID, d.columns.Amount, d.columns.Balance, d.columns.Invoice, d.columns.Timestamp, d.columns.EffectiveAt} {
+		d.indexes[name] = -1
+		for i, cell := range header {
+			// Spreadsheets often save UTF-8 with a byte order mark, which ends up in the first cell.
+			cell = strings.TrimPrefix(cell, "\ufeff")
+			if strings.EqualFold(strings.TrimSpace(cell), name) {
+				d.indexes[name] = i
+				break
+			}
+		}
+	}
+
+	for _, required := range []string{d.columns.Type, d.columns.AccountID} {
+		if d.indexes[required] == -1 {
+			return &ErrMissingColumn{Column: required}
+		}
+	}
+
+	return nil
+}
+
+// Decode reads the event of the next row. Rows whose cells are all empty are skipped.
+// It returns io.EOF once there are no more rows. The events are validated, like with ParseEvents, unless disabled with
+// WithValidation.
+func (d *Decoder) Decode() (event.Event, error) {
+	if d.indexes == nil {
+		if err := d.readHeader(); err != nil {
+			return event.Event{}, err
+		}
+	}
+
+	d.record = nil
+	for {
+		record, err := d.r.Read()
+		if err != nil {
+			return event.Event{}, err
+		}
+		if !isEmpty(record) {
+			d.record = record
+			return d.decodeRecord(record)
+		}
+	}
+}
+
+// Raw returns the row of the last call to Decode as a JSON object of its cells by the names of their columns, so a
+// rejected row can be quarantined, e.g. by event.EventService.DecodeEvents. It is nil if the call read no row, e.g.
+// because the CSV is malformed.
+func (d *Decoder) Raw() []byte {
+	if d.record == nil {
+		return nil
+	}
+
+	cells := make(map[string]string, len(d.record))
+	for i, cell := range d.record {
+		name := strconv.Itoa(i)
+		if i < len(d.header) && d.header[i] != "" {
+			name = d.header[i]
+		}
+		cells[name] = strings.TrimSpace(cell)
+	}
+	raw, err := json.Marshal(cells)
+	if err != nil {
+		return nil
+	}
+
+	return raw
+}
+
+func (d *Decoder) decodeRecord(record []string) (event.Event, error) {
+	accountID := d.cell(record, d.columns.AccountID)
+
+	var e event.Event
+	switch typ := d.cell(record, d.columns.Type); {
+	case strings.EqualFold(typ, event.EventTypeAccountCreated):
+		balance, err := d.amount(record, d.columns.Balance, event.EventPayloadFieldBalance)
+		if err != nil {
+			return event.Event{}, err
+		}
+		e = event.NewAccountCreatedEvent(accountID, balance)
+	case strings.EqualFold(typ, event.EventTypeAccountChargeReceived):
+		amount, err := d.amount(record, d.columns.Amount, event.EventPayloadFieldAmount)
+		if err != nil {
+			return event.Event{}, err
+		}
+		e = event.NewChargeEvent(accountID, amount).ForInvoice(d.cell(record, d.columns.Invoice))
+	case strings.EqualFold(typ, event.EventTypeAccountPaymentReceived):
+		amount, err := d.amount(record, d.columns.Amount, event.EventPayloadFieldAmount)
+		if err != nil {
+			return event.Event{}, err
+		}
+		e = event.NewPaymentEvent(accountID, amount).ForInvoice(d.cell(record, d.columns.Invoice))
+	case strings.EqualFold(typ, event.EventTypeAccountRecalled):
+		e = event.NewRecalledEvent(accountID)
+	case strings.EqualFold(typ, event.EventTypeAccountWrittenOff):
+		e = event.NewWrittenOffEvent(accountID)
+	case strings.EqualFold(typ, event.EventTypeAccountRecoveryReceived):
+		amount, err := d.amount(record, d.columns.Amount, event.EventPayloadFieldAmount)
+		if err != nil {
+			return event.Event{}, err
+		}
+		e = event.NewRecoveryEvent(accountID, amount).ForInvoice(d.cell(record, d.columns.Invoice))
+	default:
+		return event.Event{}, d.invalid(d.columns.Type, &event.ErrUnsupportedEventType{Type: typ})
+	}
+	e = e.InTenant(d.cell(record, d.columns.TenantID))
+
+	if cell := d.cell(record, d.columns.Timestamp); cell != "" {
+		timestamp, err := parseTimestamp(cell)
+		if err != nil {
+			return event.Event{}, d.invalid(d.columns.Timestamp, &event.ErrInvalidTimestamp{Err: err})
+		}
+		e = e.At(timestamp)
+	}
+	if cell := d.cell(record, d.columns.EffectiveAt); cell != "" {
+		effectiveAt, err := parseTimestamp(cell)
+		if err != nil {
+			return event.Event{}, d.invalid(d.columns.EffectiveAt, &event.ErrInvalidEffectiveAt{Err: err})
+		}
+		e = e.EffectiveFrom(effectiveAt)
+	}
+
+	if !d.validate {
+		return e, nil
+	}
+	if err := e.Validate(); err != nil {
+		column := d.columns.AccountID
+		var amountErr *event.ErrInvalidAmount
+		if errors.As(err, &amountErr) {
+			column = d.columns.Amount
+			if amountErr.Field == event.EventPayloadFieldBalance {
+				column = d.columns.Balance
+			}
+		}
+		return event.Event{}, d.invalid(column, err)
+	}
+
+	return e, nil
+}
+
+// amount parses the integer in the column of a payload field, which the event type requires.
+func (d *Decoder) amount(record []string, column, field string) (int, error) {
+	if d.indexes[column] == -1 {
+		row, _ := d.r.FieldPos(0)
+		return 0, &ErrMissingColumn{Row: row, Column: column}
+	}
+
+	cell := d.cell(record, column)
+	if cell == "" {
+		return 0, d.invalid(column, &event.ErrMissingFieldInEventPayloadField{Field: field})
+	}
+	amount, err := strconv.Atoi(cell)
+	if err != nil {
+		return 0, d.invalid(column, &event.ErrInvalidPayloadFieldValue{Field: field, Err: err})
+	}
+
+	return amount, nil
+}
+
+// parseTimestamp parses a timestamp or an effective time in RFC 3339, or a date, which is midnight UTC.
+func parseTimestamp(cell string) (time.Time, error) {
+	if timestamp, err := time.Parse(time.DateOnly, cell); err == nil {
+		return timestamp, nil
+	}
+
+	return time.Parse(time.RFC3339, cell)
+}
+
+// cell returns the value of a column of the row, without surrounding spaces, or "" if the header does not have it.
+func (d *Decoder) cell(record []string, column string) string {
+	i := d.indexes[column]
+	if i == -1 || i >= len(record) {
+		return ""
+	}
+
+	return strings.TrimSpace(record[i])
+}
+
+func (d *Decoder) invalid(column string, err error) error {
+	row, _ := d.r.FieldPos(0)
+	return &ErrInvalidCell{Row: row, Column: column, Err: err}
+}
+
+func isEmpty(record []string) bool {
+	for _, cell := range record {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ReadEvents decodes the events of every row of a CSV file.
+func ReadEvents(r io.Reader, opts ...Option) ([]event.Event, error) {
+	events := []event.Event{}
+
+	dec := NewDecoder(r, opts...)
+	for {
+		e, err := dec.Decode()
+		if errors.Is(err, io.EOF) {
+			return events, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+}
@@ -0,0 +1,240 @@
+package csvevents_test
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/csvevents"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadEvents(t *testing.T) {
+	input := "\ufefftype,account_id,amount,balance,note\n" +
+		"AccountCreated,Jack,,50,opening\n" +
+		"accountchargereceived, Jack ,25,,\n" +
+		"\n" +
+		",,,,\n" +
+		"AccountPaymentReceived,Jack,10\n" +
+		"AccountRecalled,Jack,,,\"fraud,\nconfirmed\"\n"
+
+	events, err := csvevents.ReadEvents(strings.NewReader(input))
+	require.NoError(t, err)
+	assert.Equal(t, []event.Event{
+		event.NewAccountCreatedEvent("Jack", 50),
+		event.NewChargeEvent("Jack", 25),
+		event.NewPaymentEvent("Jack", 10),
+		event.NewRecalledEvent("Jack"),
+	}, events)
+
+	// The events go through the same pipeline as JSON.
+	accounts, err := event.NewService().ProcessEvents(events)
+	require.NoError(t, err)
+	jack := accounts["Jack"]
+	assert.Equal(t, 65, jack.Balance())
+}
+
+func TestReadEvents_InvoicesAndTimestamps(t *testing.T) {
+	input := "type,account_id,amount,balance,invoice,timestamp\n" +
+		"AccountCreated,Jack,,50,INV-0,2026-03-01\n" +
+		"AccountChargeReceived,Jack,25,,INV-1,2026-03-02T09:30:00Z\n" +
+		"AccountPaymentReceived,Jack,10,,INV-1,\n"
+
+	events, err := csvevents.ReadEvents(strings.NewReader(input))
+	require.NoError(t, err)
+	assert.Equal(t, []event.Event{
+		// An AccountCreated event has no invoice.
+		event.NewAccountCreatedEvent("Jack", 50).At(time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)),
+		event.NewChargeEvent("Jack", 25).ForInvoice("INV-1").At(time.Date(2026, time.March, 2, 9, 30, 0, 0, time.UTC)),
+		event.NewPaymentEvent("Jack", 10).ForInvoice("INV-1"),
+	}, events)
+}
+
+func TestReadEvents_EffectiveAt(t *testing.T) {
+	input := "type,account_id,amount,effective_at\n" +
+		"AccountChargeReceived,Jack,25,2026-04-01\n" +
+		"AccountChargeReceived,Jack,25,\n"
+
+	events, err := csvevents.ReadEvents(strings.NewReader(input))
+	require.NoError(t, err)
+	assert.Equal(t, []event.Event{
+		event.NewChargeEvent("Jack", 25).EffectiveFrom(time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC)),
+		event.NewChargeEvent("Jack", 25),
+	}, events)
+}
+
+func TestReadEvents_WriteOff(t *testing.T) {
+	input := "type,account_id,amount\n" +
+		"AccountWrittenOff,Jack,25\n" +
+		"accountrecoveryreceived,Jack,10\n"
+
+	events, err := csvevents.ReadEvents(strings.NewReader(input))
+	require.NoError(t, err)
+	// An AccountWrittenOff event has no amount: the account decides what is written off.
+	assert.Equal(t, []event.Event{
+		event.NewWrittenOffEvent("Jack"),
+		event.NewRecoveryEvent("Jack", 10),
+	}, events)
+}
+
+func TestReadEvents_Columns(t *testing.T) {
+	columns, err := csvevents.ParseColumns("type=Kind, account_id=Customer,amount=Value,balance=Value,tenant_id=Partner")
+	require.NoError(t, err)
+	input := "Partner;Value;Customer;Kind\n" +
+		"acme;50;Jack;AccountCreated\n" +
+		";25;Jack;AccountCreated\n"
+
+	events, err := csvevents.ReadEvents(strings.NewReader(input), csvevents.WithColumns(columns), csvevents.WithComma(';'))
+	require.NoError(t, err)
+	assert.Equal(t, []event.Event{
+		event.NewAccountCreatedEvent("Jack", 50).InTenant("acme"),
+		event.NewAccountCreatedEvent("Jack", 25),
+	}, events)
+}
+
+func TestParseColumns_CustomErrors(t *testing.T) {
+	for _, s := range []string{"type", "kind=Kind", "type=", "type=Kind,,"} {
+		_, err := csvevents.ParseColumns(s)
+		assert.Error(t, err, s)
+	}
+
+	columns, err := csvevents.ParseColumns("")
+	require.NoError(t, err)
+	assert.Equal(t, csvevents.DefaultColumns(), columns)
+}
+
+func TestReadEvents_CustomErrors(t *testing.T) {
+	header := "type,account_id,amount,balance\n"
+
+	subtests := []struct {
+		name  string
+		input string
+		want  error
+	}{
+		{
+			name:  "ErrMissingColumn Empty",
+			input: "",
+			want:  &csvevents.ErrMissingColumn{Column: "type"},
+		},
+		{
+			name:  "ErrMissingColumn AccountID",
+			input: "type,amount\nAccountRecalled,\n",
+			want:  &csvevents.ErrMissingColumn{Column: "account_id"},
+		},
+		{
+			name:  "ErrMissingColumn Amount",
+			input: "type,account_id\nAccountRecalled,Jack\nAccountChargeReceived,Jack\n",
+			want:  &csvevents.ErrMissingColumn{Row: 3, Column: "amount"},
+		},
+		{
+			name:  "ErrUnsupportedEventType",
+			input: header + "AccountCreated,Jack,,50\n\nAccountCreate,Jack,,50\n",
+			want:  &csvevents.ErrInvalidCell{Row: 4, Column: "type", Err: &event.ErrUnsupportedEventType{Type: "AccountCreate"}},
+		},
+		{
+			name:  "ErrMissingFieldInEventPayloadField",
+			input: header + "AccountCreated,Jack,50,\n",
+			want:  &csvevents.ErrInvalidCell{Row: 2, Column: "balance", Err: &event.ErrMissingFieldInEventPayloadField{Field: event.EventPayloadFieldBalance}},
+		},
+		{
+			name:  "ErrInvalidPayloadFieldValue",
+			input: header + "AccountChargeReceived,Jack,2.5,\n",
+			want: &csvevents.ErrInvalidCell{Row: 2, Column: "amount", Err: &event.ErrInvalidPayloadFieldValue{
+				Field: event.EventPayloadFieldAmount,
+				Err:   &strconv.NumError{Func: "Atoi", Num: "2.5", Err: strconv.ErrSyntax},
+			}},
+		},
+		{
+			name:  "ErrInvalidAmount",
+			input: header + "AccountPaymentReceived,Jack,-25,\n",
+			want:  &csvevents.ErrInvalidCell{Row: 2, Column: "amount", Err: &event.ErrInvalidAmount{AccountID: "Jack", Field: event.EventPayloadFieldAmount, Amount: -25}},
+		},
+		{
+			name:  "ErrInvalidAmount Balance",
+			input: header + "AccountCreated,Jack,,-50\n",
+			want:  &csvevents.ErrInvalidCell{Row: 2, Column: "balance", Err: &event.ErrInvalidAmount{AccountID: "Jack", Field: event.EventPayloadFieldBalance, Amount: -50}},
+		},
+		{
+			name:  "ErrInvalidTimestamp",
+			input: "type,account_id,timestamp\nAccountRecalled,Jack,01/03/2026\n",
+			want: &csvevents.ErrInvalidCell{Row: 2, Column: "timestamp", Err: &event.ErrInvalidTimestamp{
+				Err: &time.ParseError{Layout: time.RFC3339, Value: "01/03/2026", LayoutElem: "2006", ValueElem: "01/03/2026", Message: ""},
+			}},
+		},
+		{
+			name:  "ErrInvalidEffectiveAt",
+			input: "type,account_id,effective_at\nAccountRecalled,Jack,next month\n",
+			want: &csvevents.ErrInvalidCell{Row: 2, Column: "effective_at", Err: &event.ErrInvalidEffectiveAt{
+				Err: &time.ParseError{Layout: time.RFC3339, Value: "next month", LayoutElem: "2006", ValueElem: "next month", Message: ""},
+			}},
+		},
+		{
+			name:  "ErrEmptyAccountID",
+			input: header + "AccountRecalled, ,,\n",
+			want:  &csvevents.ErrInvalidCell{Row: 2, Column: "account_id", Err: &event.ErrEmptyAccountID{Type: event.EventTypeAccountRecalled}},
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := csvevents.ReadEvents(strings.NewReader(tt.input))
+			assert.Equal(t, tt.want, err)
+		})
+	}
+}
+
+func TestReadEvents_WithValidation(t *testing.T) {
+	input := "type,tenant_id,account_id,amount\nAccountChargeReceived,acme,Jack,-25\nAccountRecalled,globex,,\n"
+
+	// Invalid events are left to the service, which only fails the events of their tenant.
+	events, err := csvevents.ReadEvents(strings.NewReader(input), csvevents.WithValidation(false))
+	require.NoError(t, err)
+	assert.Equal(t, []event.Event{
+		event.NewChargeEvent("Jack", -25).InTenant("acme"),
+		event.NewRecalledEvent("").InTenant("globex"),
+	}, events)
+
+	// Cells that cannot make an event are still rejected.
+	_, err = csvevents.ReadEvents(strings.NewReader("type,account_id,amount\nAccountChargeReceived,Jack,many\n"), csvevents.WithValidation(false))
+	var cellErr *csvevents.ErrInvalidCell
+	assert.ErrorAs(t, err, &cellErr)
+}
+
+func TestReadEvents_MalformedCSV(t *testing.T) {
+	_, err := csvevents.ReadEvents(strings.NewReader("type,account_id\nAccountRecalled,\"Jack\n"))
+	var parseErr *csv.ParseError
+	assert.ErrorAs(t, err, &parseErr)
+}
+
+func TestDecoder_Decode(t *testing.T) {
+	dec := csvevents.NewDecoder(strings.NewReader("type,account_id\nAccountRecalled,Jack\n"))
+
+	e, err := dec.Decode()
+	require.NoError(t, err)
+	assert.Equal(t, event.NewRecalledEvent("Jack"), e)
+
+	_, err = dec.Decode()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestDecoder_Raw(t *testing.T) {
+	dec := csvevents.NewDecoder(strings.NewReader("\ufefftype, account_id,amount\nAccountCharge, Jack,25,late\n\"Jen\n"))
+	assert.Nil(t, dec.Raw())
+
+	// A rejected row can be skipped, so it is quarantined as an object of its cells by column.
+	_, err := dec.Decode()
+	var cellErr *csvevents.ErrInvalidCell
+	assert.ErrorAs(t, err, &cellErr)
+	assert.JSONEq(t, `{"type":"AccountCharge","account_id":"Jack","amount":"25","3":"late"}`, string(dec.Raw()))
+
+	// A malformed row cannot.
+	_, err = dec.Decode()
+	var parseErr *csv.ParseError
+	assert.ErrorAs(t, err, &parseErr)
+	assert.Nil(t, dec.Raw())
+}
@@ -0,0 +1,86 @@
+package simpleeventworker
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+)
+
+const (
+	DeadLetterStageParse   = "parse"
+	DeadLetterStageProcess = "process"
+)
+
+// DeadLetter is an event rejected by a service with a DeadLetterSink, quarantined instead of failing its batch.
+type DeadLetter struct {
+	// Stage is where the event was rejected: DeadLetterStageParse or DeadLetterStageProcess.
+	Stage string `json:"Stage"`
+	// Index is the index of the event in its input when parsing, or in its batch when processing.
+	Index int `json:"Index"`
+	// Event is the JSON of the event as it was received when parsing, or as encoded by Event.MarshalJSON when processing.
+	// It is null if the event cannot be encoded, e.g. an event of an unsupported type built by hand. For an input decoded
+	// by an EventDecoder, it is the JSON its Raw returns.
+	Event json.RawMessage `json:"Event"`
+	// Reason is the message of the error the event was rejected with.
+	Reason string `json:"Reason"`
+}
+
+// DeadLetterSink receives the events a service quarantines.
+type DeadLetterSink interface {
+	// Quarantine is called once per batch parsed or applied successfully with at least one rejected event,
+	// with its dead letters in the order the events were rejected. If Quarantine fails, the batch fails as well.
+	Quarantine(letters []DeadLetter) error
+}
+
+// WithDeadLetterSink makes the service lenient: an event rejected while parsing or processing, e.g. with
+// ErrUnsupportedEventType or ErrAccountDoesNotExist, is skipped and quarantined to `sink` instead of failing its batch.
+// A skipped event leaves the accounts as they were, and the following events are processed as if it never happened.
+// Malformed JSON still fails the batch, since the events after it cannot be told apart.
+//
+// By default, the service has no sink, and the first rejected event fails its batch. Like domain events,
+// dead letters are only quarantined for batches parsed or applied successfully, so a sink must tolerate duplicates.
+func WithDeadLetterSink(sink DeadLetterSink) ServiceOption {
+	return func(s *EventService) {
+		s.deadLetters = sink
+	}
+}
+
+// newDeadLetter quarantines an event rejected while processing. Its JSON is encoded again, since only its value is left.
+func newDeadLetter(index int, event Event, err error) DeadLetter {
+	data, marshalErr := json.Marshal(event)
+	if marshalErr != nil {
+		data = nil
+	}
+
+	return DeadLetter{
+		Stage:  DeadLetterStageProcess,
+		Index:  index,
+		Event:  data,
+		Reason: err.Error(),
+	}
+}
+
+// newParseDeadLetter quarantines an event rejected while parsing with a copy of its JSON, which the decoder reuses.
+func newParseDeadLetter(index int, raw []byte, err error) DeadLetter {
+	return DeadLetter{
+		Stage:  DeadLetterStageParse,
+		Index:  index,
+		Event:  bytes.Clone(raw),
+		Reason: err.Error(),
+	}
+}
+
+// quarantine hands the dead letters of a batch to the sink, if any.
+func (s *EventService) quarantine(letters []DeadLetter) error {
+	if len(letters) == 0 {
+		return nil
+	}
+
+	if err := s.deadLetters.Quarantine(letters); err != nil {
+		s.logger.Error("cannot quarantine events", slog.Int("dead_letters", len(letters)), slog.Any("error", err))
+		return err
+	}
+	s.logger.Warn("events quarantined", slog.Int("dead_letters", len(letters)))
+
+	return nil
+}
@@ -0,0 +1,123 @@
+// Package deadletter implements event.DeadLetterSink destinations for the events a lenient service quarantines.
+package deadletter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+)
+
+// NDJSONWriter writes dead letters to an io.Writer as newline-delimited JSON, one dead letter per line.
+// It is safe for concurrent use.
+type NDJSONWriter struct {
+	mu          sync.Mutex
+	w           io.Writer
+	quarantined int
+}
+
+func NewNDJSONWriter(w io.Writer) *NDJSONWriter {
+	return &NDJSONWriter{w: w}
+}
+
+// Quarantine encodes the whole batch before writing it with a single Write,
+// so a batch that cannot be encoded leaves nothing behind.
+func (q *NDJSONWriter) Quarantine(letters []event.DeadLetter) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, l := range letters {
+		if err := encoder.Encode(l); err != nil {
+			return err
+		}
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, err := q.w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	q.quarantined += len(letters)
+
+	return nil
+}
+
+// Quarantined returns the number of dead letters written so far.
+func (q *NDJSONWriter) Quarantined() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.quarantined
+}
+
+// NDJSONFile is an NDJSONWriter appending to a file. Each batch is synced to disk before Quarantine returns.
+type NDJSONFile struct {
+	*NDJSONWriter
+	file *os.File
+}
+
+// OpenNDJSONFile opens, or creates, the file at `path` for appending.
+func OpenNDJSONFile(path string) (*NDJSONFile, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NDJSONFile{
+		NDJSONWriter: NewNDJSONWriter(file),
+		file:         file,
+	}, nil
+}
+
+func (q *NDJSONFile) Quarantine(letters []event.DeadLetter) error {
+	if err := q.NDJSONWriter.Quarantine(letters); err != nil {
+		return err
+	}
+
+	return q.file.Sync()
+}
+
+func (q *NDJSONFile) Close() error {
+	return q.file.Close()
+}
+
+// ReadNDJSON reads the dead letters written by an NDJSONWriter, in the order they were quarantined.
+func ReadNDJSON(r io.Reader) ([]event.DeadLetter, error) {
+	letters := []event.DeadLetter{}
+
+	decoder := json.NewDecoder(r)
+	for {
+		var l event.DeadLetter
+		err := decoder.Decode(&l)
+		if errors.Is(err, io.EOF) {
+			return letters, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("dead letter %d: %w", len(letters), err)
+		}
+		letters = append(letters, l)
+	}
+}
+
+// Events returns the events of dead letters as a JSON array, to be parsed again with ParseEvents.
+// The event of a dead letter that could not be encoded is null, which ParseEvents rejects again.
+func Events(letters []event.DeadLetter) []byte {
+	buf := []byte{'['}
+	for i, l := range letters {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		if len(l.Event) == 0 {
+			buf = append(buf, "null"...)
+			continue
+		}
+		buf = append(buf, l.Event...)
+	}
+
+	return append(buf, ']')
+}
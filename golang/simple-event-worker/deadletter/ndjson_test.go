@@ -0,0 +1,116 @@
+package deadletter_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/deadletter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testInput = `[
+	{"Type":"AccountCreate","AccountID":"Jack","Payload":{"Balance":50}},
+	{"Type":"AccountCreated","AccountID":"Jack","Payload":{"Balance":50}},
+	{"Type":"AccountRecalled","AccountID":"Jen"}
+]`
+
+const wantNDJSON = `{"Stage":"parse","Index":0,"Event":{"Type":"AccountCreate","AccountID":"Jack","Payload":{"Balance":50}},"Reason":"unsupported event type: \"AccountCreate\""}
+{"Stage":"process","Index":1,"Event":{"Type":"AccountRecalled","AccountID":"Jen","Payload":{}},"Reason":"account with ID does not exist: \"Jen\""}
+`
+
+// process parses and processes testInput with a service quarantining to `sink`.
+func process(t *testing.T, sink event.DeadLetterSink) {
+	t.Helper()
+
+	s := event.NewService(event.WithDeadLetterSink(sink))
+	events, err := s.ParseEvents(strings.NewReader(testInput))
+	require.NoError(t, err)
+	_, err = s.ProcessEvents(events)
+	require.NoError(t, err)
+}
+
+func TestNDJSONWriter_Quarantine(t *testing.T) {
+	var buf bytes.Buffer
+	w := deadletter.NewNDJSONWriter(&buf)
+
+	process(t, w)
+	assert.Equal(t, wantNDJSON, buf.String())
+	assert.Equal(t, 2, w.Quarantined())
+}
+
+func TestNDJSONWriter_Quarantine_FailedBatch(t *testing.T) {
+	var buf bytes.Buffer
+	w := deadletter.NewNDJSONWriter(&buf)
+	s := event.NewService(event.WithDeadLetterSink(w))
+
+	_, err := s.ParseEvents(strings.NewReader(`[{"Type":"AccountCreate","AccountID":"Jack"},{]`))
+	assert.Error(t, err)
+	assert.Empty(t, buf.String())
+	assert.Zero(t, w.Quarantined())
+}
+
+func TestNDJSONFile_Quarantine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dlq.ndjson")
+
+	file, err := deadletter.OpenNDJSONFile(path)
+	require.NoError(t, err)
+	process(t, file)
+	assert.NoError(t, file.Close())
+
+	// Reopening the file appends to it.
+	file, err = deadletter.OpenNDJSONFile(path)
+	require.NoError(t, err)
+	process(t, file)
+	assert.NoError(t, file.Close())
+
+	got, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, wantNDJSON+wantNDJSON, string(got))
+}
+
+func TestReadNDJSON(t *testing.T) {
+	subtests := []struct {
+		name       string
+		input      string
+		wantEvents string
+		wantErr    bool
+	}{
+		{
+			name:       "Success",
+			input:      wantNDJSON,
+			wantEvents: `[{"Type":"AccountCreate","AccountID":"Jack","Payload":{"Balance":50}},{"Type":"AccountRecalled","AccountID":"Jen","Payload":{}}]`,
+		},
+		{
+			name:       "NullEvent",
+			input:      `{"Stage":"process","Index":0,"Event":null,"Reason":"unsupported event type: \"\""}`,
+			wantEvents: `[null]`,
+		},
+		{
+			name:       "Empty",
+			input:      ``,
+			wantEvents: `[]`,
+		},
+		{
+			name:    "Malformed",
+			input:   wantNDJSON + `{"Stage":`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			letters, err := deadletter.ReadNDJSON(strings.NewReader(tt.input))
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "dead letter 2")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantEvents, string(deadletter.Events(letters)))
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package simpleeventworker_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeDeadLetterSink struct {
+	quarantined [][]event.DeadLetter
+	err         error
+}
+
+func (f *fakeDeadLetterSink) Quarantine(letters []event.DeadLetter) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.quarantined = append(f.quarantined, letters)
+
+	return nil
+}
+
+func TestDeadLetter_ParseEvents(t *testing.T) {
+	input := `[
+		{"Type":"AccountCreated","AccountID":"Jack","Payload":{"Balance":50}},
+		{"Type":"AccountCreate","AccountID":"Jack","Payload":{"Balance":50}},
+		{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":"25"}},
+		7,
+		{"Type":"AccountChargeReceived","AccountID":"","Payload":{"Amount":25}},
+		{"Type":"AccountRecalled","AccountID":"Jack"}
+	]`
+
+	for _, fast := range []bool{false, true} {
+		sink := &fakeDeadLetterSink{}
+		s := event.NewService(event.WithDeadLetterSink(sink), event.WithFastDecoder(fast))
+
+		events, err := s.ParseEvents(strings.NewReader(input))
+		require.NoError(t, err, "fast: %v", fast)
+		assert.Equal(t, []event.Event{
+			event.NewAccountCreatedEvent("Jack", 50),
+			event.NewRecalledEvent("Jack"),
+		}, events, "fast: %v", fast)
+
+		assert.Equal(t, [][]event.DeadLetter{{
+			{
+				Stage:  event.DeadLetterStageParse,
+				Index:  1,
+				Event:  json.RawMessage(`{"Type":"AccountCreate","AccountID":"Jack","Payload":{"Balance":50}}`),
+				Reason: (&event.ErrUnsupportedEventType{Type: "AccountCreate"}).Error(),
+			},
+			{
+				Stage:  event.DeadLetterStageParse,
+				Index:  2,
+				Event:  json.RawMessage(`{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":"25"}}`),
+				Reason: sink.quarantined[0][1].Reason,
+			},
+			{
+				Stage:  event.DeadLetterStageParse,
+				Index:  3,
+				Event:  json.RawMessage(`7`),
+				Reason: sink.quarantined[0][2].Reason,
+			},
+			{
+				Stage:  event.DeadLetterStageParse,
+				Index:  4,
+				Event:  json.RawMessage(`{"Type":"AccountChargeReceived","AccountID":"","Payload":{"Amount":25}}`),
+				Reason: (&event.ErrEmptyAccountID{Type: event.EventTypeAccountChargeReceived}).Error(),
+			},
+		}}, sink.quarantined, "fast: %v", fast)
+
+		assert.Contains(t, sink.quarantined[0][1].Reason, `invalid value for event payload field "Amount"`, "fast: %v", fast)
+		assert.Contains(t, sink.quarantined[0][2].Reason, "cannot unmarshal number", "fast: %v", fast)
+	}
+}
+
+func TestDeadLetter_ParseEvents_MalformedJSON(t *testing.T) {
+	for _, fast := range []bool{false, true} {
+		sink := &fakeDeadLetterSink{}
+		s := event.NewService(event.WithDeadLetterSink(sink), event.WithFastDecoder(fast))
+
+		// The unsupported event is not quarantined, since the batch fails.
+		_, err := s.ParseEvents(strings.NewReader(`[{"Type":"AccountCreate","AccountID":"Jack"},{"Type":]`))
+		assert.Error(t, err, "fast: %v", fast)
+		assert.Empty(t, sink.quarantined, "fast: %v", fast)
+	}
+}
+
+// fakeDecoder decodes the given events, failing with the error at the same index, if any, which can be skipped unless
+// its raw input is nil.
+type fakeDecoder struct {
+	events []event.Event
+	errs   map[int]error
+	raws   map[int][]byte
+	index  int
+}
+
+func (f *fakeDecoder) Decode() (event.Event, error) {
+	if f.index == len(f.events) {
+		return event.Event{}, io.EOF
+	}
+	f.index++
+	if err, ok := f.errs[f.index-1]; ok {
+		return event.Event{}, err
+	}
+
+	return f.events[f.index-1], nil
+}
+
+func (f *fakeDecoder) Raw() []byte {
+	return f.raws[f.index-1]
+}
+
+func TestDeadLetter_DecodeEvents(t *testing.T) {
+	newDecoder := func(raw []byte) *fakeDecoder {
+		return &fakeDecoder{
+			events: []event.Event{event.NewAccountCreatedEvent("Jack", 50), {}, event.NewChargeEvent("", 25), event.NewRecalledEvent("Jack")},
+			errs:   map[int]error{1: errors.New("csv: row 3: bad row")},
+			raws:   map[int][]byte{1: raw, 2: []byte(`{"type":"AccountChargeReceived"}`)},
+		}
+	}
+
+	// Events of a decoder are validated and quarantined like the ones of a JSON input.
+	sink := &fakeDeadLetterSink{}
+	events, err := event.NewService(event.WithDeadLetterSink(sink)).DecodeEvents(newDecoder([]byte(`{"type":"AccountCreate"}`)))
+	require.NoError(t, err)
+	assert.Equal(t, []event.Event{event.NewAccountCreatedEvent("Jack", 50), event.NewRecalledEvent("Jack")}, events)
+	assert.Equal(t, [][]event.DeadLetter{{
+		{Stage: event.DeadLetterStageParse, Index: 1, Event: json.RawMessage(`{"type":"AccountCreate"}`), Reason: "csv: row 3: bad row"},
+		{Stage: event.DeadLetterStageParse, Index: 2, Event: json.RawMessage(`{"type":"AccountChargeReceived"}`), Reason: (&event.ErrEmptyAccountID{Type: event.EventTypeAccountChargeReceived}).Error()},
+	}}, sink.quarantined)
+
+	// Without parse validation, invalid events are left to processing.
+	events, err = event.NewService(event.WithDeadLetterSink(&fakeDeadLetterSink{}), event.WithParseValidation(false)).DecodeEvents(newDecoder([]byte(`{}`)))
+	require.NoError(t, err)
+	assert.Len(t, events, 3)
+
+	// An input the decoder cannot carry on after fails, as malformed JSON does.
+	_, err = event.NewService(event.WithDeadLetterSink(&fakeDeadLetterSink{})).DecodeEvents(newDecoder(nil))
+	assert.EqualError(t, err, "csv: row 3: bad row")
+}
+
+func TestDeadLetter_ProcessEvents(t *testing.T) {
+	sink := &fakeDeadLetterSink{}
+	outbox := &fakeOutbox{}
+	s := event.NewService(event.WithDeadLetterSink(sink), event.WithOutbox(outbox))
+
+	accounts, err := s.ProcessEvents([]event.Event{
+		event.NewChargeEvent("Jack", 25),
+		event.NewAccountCreatedEvent("Jack", 50),
+		event.NewAccountCreatedEvent("Jack", 10),
+		event.NewPaymentEvent("Jack", 50),
+		event.NewRecalledEvent("Jack"),
+		event.NewChargeEvent("Jack", 25),
+		{Type: "AccountCreate", AccountID: "Jen"},
+	})
+	require.NoError(t, err)
+	jack := event.NewAccount("Jack", 0)
+	jack.Recall()
+	assert.Equal(t, map[string]event.Account{"Jack": *jack}, accounts)
+
+	assert.Equal(t, [][]event.DeadLetter{{
+		{
+			Stage:  event.DeadLetterStageProcess,
+			Index:  0,
+			Event:  json.RawMessage(`{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":25}}`),
+			Reason: (&event.ErrAccountDoesNotExist{AccountID: "Jack"}).Error(),
+		},
+		{
+			Stage:  event.DeadLetterStageProcess,
+			Index:  2,
+			Event:  json.RawMessage(`{"Type":"AccountCreated","AccountID":"Jack","Payload":{"Balance":10}}`),
+			Reason: (&event.ErrAccountAlreadyExists{AccountID: "Jack"}).Error(),
+		},
+		{
+			Stage:  event.DeadLetterStageProcess,
+			Index:  5,
+			Event:  json.RawMessage(`{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":25}}`),
+			Reason: (&event.ErrCannotTransactWithRecalledAccount{AccountID: "Jack"}).Error(),
+		},
+		{
+			Stage:  event.DeadLetterStageProcess,
+			Index:  6,
+			Event:  nil,
+			Reason: (&event.ErrUnsupportedEventType{Type: "AccountCreate"}).Error(),
+		},
+	}}, sink.quarantined)
+
+	// Skipped events do not break the indexes of domain events.
+	require.Len(t, outbox.published, 1)
+	assert.Equal(t, []int{3, 4}, []int{outbox.published[0][0].EventIndex, outbox.published[0][1].EventIndex})
+}
+
+func TestDeadLetter_ProcessEvents_NothingRejected(t *testing.T) {
+	sink := &fakeDeadLetterSink{}
+	s := event.NewService(event.WithDeadLetterSink(sink))
+
+	_, err := s.ProcessEvents([]event.Event{event.NewAccountCreatedEvent("Jack", 50)})
+	require.NoError(t, err)
+	assert.Empty(t, sink.quarantined)
+}
+
+func TestDeadLetter_QuarantineError(t *testing.T) {
+	sinkErr := errors.New("disk full")
+	sink := &fakeDeadLetterSink{err: sinkErr}
+	outbox := &fakeOutbox{}
+	s := event.NewService(event.WithDeadLetterSink(sink), event.WithOutbox(outbox))
+
+	_, err := s.ParseEvents(strings.NewReader(`[{"Type":"AccountCreate","AccountID":"Jack"}]`))
+	assert.ErrorIs(t, err, sinkErr)
+
+	// Like a failing outbox, a failing sink fails the batch, before any domain event is published.
+	_, err = s.ProcessEvents([]event.Event{
+		event.NewAccountCreatedEvent("Jack", 50),
+		event.NewPaymentEvent("Jack", 50),
+		event.NewChargeEvent("Jen", 25),
+	})
+	assert.ErrorIs(t, err, sinkErr)
+	assert.Empty(t, outbox.published)
+}
+
+func TestDeadLetter_ProcessSource(t *testing.T) {
+	sink := &fakeDeadLetterSink{}
+	s := event.NewService(event.WithDeadLetterSink(sink))
+	source := &fakeSource{batches: [][]event.Event{
+		{event.NewAccountCreatedEvent("Jack", 50), event.NewChargeEvent("Jen", 25)},
+		{event.NewChargeEvent("Jack", 25)},
+	}}
+
+	accounts, err := s.ProcessSource(context.Background(), nil, source)
+	require.NoError(t, err)
+	jack := event.NewAccount("Jack", 50)
+	_ = jack.RecordTransaction(25)
+	assert.Equal(t, map[string]event.Account{"Jack": *jack}, accounts)
+	assert.Equal(t, []int{0, 1}, source.committed)
+	require.Len(t, sink.quarantined, 1)
+	assert.Equal(t, 1, sink.quarantined[0][0].Index)
+}
@@ -0,0 +1,134 @@
+// Package diff compares two states of accounts, e.g. before and after rerunning the worker on fixed upstream data.
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+type Result struct {
+	Added   []Change `json:"Added"`
+	Removed []Change `json:"Removed"`
+	Changed []Change `json:"Changed"`
+}
+
+// Change describes an account in either state. Before is nil for added accounts, and After is nil for removed accounts.
+type Change struct {
+	AccountID string        `json:"AccountID"`
+	Before    *AccountState `json:"Before"`
+	After     *AccountState `json:"After"`
+}
+
+type AccountState struct {
+	Status  string `json:"Status"`
+	Balance int    `json:"Balance"`
+}
+
+// Compare lists the accounts added, removed and changed, by status or balance, from `before` to `after`.
+// Each list is sorted by AccountID.
+func Compare(before, after map[string]event.Account) *Result {
+	r := &Result{
+		Added:   []Change{},
+		Removed: []Change{},
+		Changed: []Change{},
+	}
+
+	for id, b := range before {
+		a, ok := after[id]
+		if !ok {
+			r.Removed = append(r.Removed, Change{AccountID: id, Before: newAccountState(b)})
+			continue
+		}
+		if a.Status() != b.Status() || a.Balance() != b.Balance() {
+			r.Changed = append(r.Changed, Change{AccountID: id, Before: newAccountState(b), After: newAccountState(a)})
+		}
+	}
+
+	for id, a := range after {
+		if _, ok := before[id]; !ok {
+			r.Added = append(r.Added, Change{AccountID: id, After: newAccountState(a)})
+		}
+	}
+
+	for _, changes := range [][]Change{r.Added, r.Removed, r.Changed} {
+		sort.Slice(changes, func(i, j int) bool {
+			return changes[i].AccountID < changes[j].AccountID
+		})
+	}
+
+	return r
+}
+
+func newAccountState(account event.Account) *AccountState {
+	return &AccountState{Status: account.Status(), Balance: account.Balance()}
+}
+
+// Empty reports whether both states are the same.
+func (r *Result) Empty() bool {
+	return len(r.Added) == 0 && len(r.Removed) == 0 && len(r.Changed) == 0
+}
+
+// Write writes the result to w in the given format, either FormatText or FormatJSON.
+func (r *Result) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatText:
+		return r.WriteText(w)
+	case FormatJSON:
+		return r.WriteJSON(w)
+	default:
+		return &ErrUnsupportedFormat{Format: format}
+	}
+}
+
+func (r *Result) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteText writes one line per account, prefixed like a unified diff: `+` added, `-` removed, `~` changed.
+func (r *Result) WriteText(w io.Writer) error {
+	if r.Empty() {
+		_, err := fmt.Fprintln(w, "No differences.")
+		return err
+	}
+
+	for _, c := range r.Added {
+		if _, err := fmt.Fprintf(w, "+ %s: %s\n", c.AccountID, c.After); err != nil {
+			return err
+		}
+	}
+	for _, c := range r.Removed {
+		if _, err := fmt.Fprintf(w, "- %s: %s\n", c.AccountID, c.Before); err != nil {
+			return err
+		}
+	}
+	for _, c := range r.Changed {
+		if _, err := fmt.Fprintf(w, "~ %s: %s -> %s\n", c.AccountID, c.Before, c.After); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *AccountState) String() string {
+	return fmt.Sprintf("{Status: %s, Balance: %d}", s.Status, s.Balance)
+}
+
+type ErrUnsupportedFormat struct {
+	Format string
+}
+
+func (e *ErrUnsupportedFormat) Error() string {
+	return fmt.Sprintf(`unsupported diff format: "%s"`, e.Format)
+}
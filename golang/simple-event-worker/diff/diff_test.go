@@ -0,0 +1,112 @@
+package diff_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/diff"
+	"github.com/stretchr/testify/assert"
+)
+
+func accounts(values map[string]int, recalled ...string) map[string]event.Account {
+	m := map[string]event.Account{}
+	for id, balance := range values {
+		m[id] = *event.NewAccount(id, balance)
+	}
+	for _, id := range recalled {
+		account := m[id]
+		account.Recall()
+		m[id] = account
+	}
+
+	return m
+}
+
+func TestCompare(t *testing.T) {
+	subtests := []struct {
+		name   string
+		before map[string]event.Account
+		after  map[string]event.Account
+		want   *diff.Result
+	}{
+		{
+			name:   "NoDifferences",
+			before: accounts(map[string]int{"Jack": 50, "Jen": 0}),
+			after:  accounts(map[string]int{"Jack": 50, "Jen": 0}),
+			want:   &diff.Result{Added: []diff.Change{}, Removed: []diff.Change{}, Changed: []diff.Change{}},
+		},
+		{
+			name:   "AddedRemovedChanged",
+			before: accounts(map[string]int{"Jack": 50, "Jen": 0, "Olivia": 50, "Robert": 10}),
+			after:  accounts(map[string]int{"Jack": 50, "Jen": -10, "Olivia": 50, "Tom": 25}, "Olivia"),
+			want: &diff.Result{
+				Added: []diff.Change{
+					{AccountID: "Tom", After: &diff.AccountState{Status: event.AccountStatusOutstanding, Balance: 25}},
+				},
+				Removed: []diff.Change{
+					{AccountID: "Robert", Before: &diff.AccountState{Status: event.AccountStatusOutstanding, Balance: 10}},
+				},
+				Changed: []diff.Change{
+					{
+						AccountID: "Jen",
+						Before:    &diff.AccountState{Status: event.AccountStatusSettled, Balance: 0},
+						After:     &diff.AccountState{Status: event.AccountStatusOverpaid, Balance: -10},
+					},
+					{
+						AccountID: "Olivia",
+						Before:    &diff.AccountState{Status: event.AccountStatusOutstanding, Balance: 50},
+						After:     &diff.AccountState{Status: event.AccountStatusRecalled, Balance: 50},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diff.Compare(tt.before, tt.after)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.want.Empty(), got.Empty())
+		})
+	}
+}
+
+func TestResult_Write(t *testing.T) {
+	result := diff.Compare(
+		accounts(map[string]int{"Jack": 50, "Jen": 0, "Robert": 10}),
+		accounts(map[string]int{"Jack": 50, "Jen": -10, "Tom": 25}),
+	)
+
+	t.Run("Text", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, result.Write(&buf, diff.FormatText))
+		assert.Equal(t, `+ Tom: {Status: Outstanding, Balance: 25}
+- Robert: {Status: Outstanding, Balance: 10}
+~ Jen: {Status: Settled, Balance: 0} -> {Status: Overpaid, Balance: -10}
+`, buf.String())
+	})
+
+	t.Run("TextNoDifferences", func(t *testing.T) {
+		var buf bytes.Buffer
+		empty := diff.Compare(accounts(map[string]int{"Jack": 50}), accounts(map[string]int{"Jack": 50}))
+		assert.NoError(t, empty.Write(&buf, diff.FormatText))
+		assert.Equal(t, "No differences.\n", buf.String())
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, result.Write(&buf, diff.FormatJSON))
+
+		got := &diff.Result{}
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), got))
+		assert.Equal(t, result, got)
+	})
+
+	t.Run("ErrUnsupportedFormat", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := result.Write(&buf, "xml")
+		assert.EqualError(t, err, (&diff.ErrUnsupportedFormat{Format: "xml"}).Error())
+	})
+}
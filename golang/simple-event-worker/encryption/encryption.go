@@ -0,0 +1,210 @@
+// Package encryption encrypts the files the worker writes at rest with AES-256-GCM, since the account IDs and
+// balances in them may identify customers.
+//
+// An encrypted stream is a sequence of frames, one per Write, so a file opened for appending stays readable:
+//
+//	frame  = magic version uvarint(len(sealed)) nonce sealed
+//	magic  = "SEWE"
+//	nonce  = 12 random bytes
+//	sealed = the bytes written, encrypted and authenticated with AES-256-GCM, with magic and version as additional data
+//
+// Each frame is authenticated on its own: a reader detects a frame that was changed or cut short, but not a whole
+// frame that was dropped or moved. The hash chain of the events (see -chain) covers the history of the accounts.
+// Nonces are random, so a key should be rotated well before 2^32 frames were written with it.
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	magic   = "SEWE"
+	version = 1
+
+	// KeySize is the size of a key, in bytes.
+	KeySize = 32
+
+	// MaxFrameSize bounds the size of the bytes of a frame. Larger writes are split across several frames, so a
+	// corrupted length cannot make the reader allocate without limit.
+	MaxFrameSize = 1 << 20
+)
+
+// header starts every frame, and is authenticated along with its bytes.
+var header = append([]byte(magic), version)
+
+var ErrInvalidKey = errors.New("encryption: a key must be 32 bytes, encoded in base64")
+
+type ErrInvalidFrame struct {
+	// Index is the index of the frame in the stream.
+	Index  int
+	Reason string
+}
+
+func (e *ErrInvalidFrame) Error() string {
+	return fmt.Sprintf("encryption: invalid frame %d: %s", e.Index, e.Reason)
+}
+
+// ParseKey decodes a key from its base64 encoding, e.g. the output of `openssl rand -base64 32`.
+// Surrounding whitespace is ignored, so a key file may end with a newline.
+func ParseKey(text string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(text))
+	if err != nil || len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+
+	return key, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// Writer encrypts every Write to an io.Writer as a frame, or several if it is larger than MaxFrameSize.
+type Writer struct {
+	w     io.Writer
+	aead  cipher.AEAD
+	frame []byte
+}
+
+func NewWriter(w io.Writer, key []byte) (*Writer, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Writer{w: w, aead: aead}, nil
+}
+
+// Write writes the frames of `p` with a single Write, so writers appending to a file keep their batches whole.
+func (w *Writer) Write(p []byte) (int, error) {
+	// The frame buffer is reused across writes.
+	frame := w.frame[:0]
+	for chunk := range chunks(p) {
+		frame = append(frame, header...)
+		frame = binary.AppendUvarint(frame, uint64(len(chunk)+w.aead.Overhead()))
+
+		nonce := make([]byte, w.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return 0, err
+		}
+		frame = append(frame, nonce...)
+		frame = w.aead.Seal(frame, nonce, chunk, header)
+	}
+	w.frame = frame
+
+	if _, err := w.w.Write(frame); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// chunks splits `p` into chunks of at most MaxFrameSize bytes. An empty `p` is no chunk.
+func chunks(p []byte) func(yield func([]byte) bool) {
+	return func(yield func([]byte) bool) {
+		for len(p) > 0 {
+			n := min(len(p), MaxFrameSize)
+			if !yield(p[:n]) {
+				return
+			}
+			p = p[n:]
+		}
+	}
+}
+
+// Reader decrypts the frames of a stream written by a Writer, in order.
+type Reader struct {
+	r     *bufio.Reader
+	aead  cipher.AEAD
+	index int
+	// plain holds what is left of the bytes of the last frame read.
+	plain []byte
+}
+
+func NewReader(r io.Reader, key []byte) (*Reader, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reader{r: bufio.NewReader(r), aead: aead}, nil
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if err := r.readFrame(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+
+	return n, nil
+}
+
+// readFrame decrypts the next frame. The stream may only end between frames, where it returns io.EOF.
+func (r *Reader) readFrame() error {
+	got := make([]byte, len(header))
+	if _, err := io.ReadFull(r.r, got); err != nil {
+		if errors.Is(err, io.EOF) {
+			return io.EOF
+		}
+		return r.invalid(err)
+	}
+	if !bytes.Equal(got, header) {
+		return r.invalid(errors.New("invalid header, the file may not be encrypted"))
+	}
+
+	size, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return r.invalid(err)
+	}
+	if size < uint64(r.aead.Overhead()) || size > uint64(MaxFrameSize+r.aead.Overhead()) {
+		return r.invalid(fmt.Errorf("invalid length %d", size))
+	}
+
+	nonce := make([]byte, r.aead.NonceSize())
+	if _, err := io.ReadFull(r.r, nonce); err != nil {
+		return r.invalid(err)
+	}
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(r.r, sealed); err != nil {
+		return r.invalid(err)
+	}
+
+	plain, err := r.aead.Open(sealed[:0], nonce, sealed, header)
+	if err != nil {
+		return r.invalid(errors.New("cannot authenticate the frame, the key may be wrong or the file changed"))
+	}
+	r.plain = plain
+	r.index++
+
+	return nil
+}
+
+func (r *Reader) invalid(err error) error {
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return &ErrInvalidFrame{Index: r.index, Reason: err.Error()}
+}
@@ -0,0 +1,163 @@
+package encryption_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/nogurenn/assorted-programs/simple-event-worker/encryption"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newKey(t *testing.T) []byte {
+	t.Helper()
+
+	key := make([]byte, encryption.KeySize)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+
+	return key
+}
+
+// encrypt writes every write of `writes` to a Writer with `key`, and returns the stream.
+func encrypt(t *testing.T, key []byte, writes ...[]byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := encryption.NewWriter(&buf, key)
+	require.NoError(t, err)
+	for _, p := range writes {
+		n, err := w.Write(p)
+		require.NoError(t, err)
+		require.Equal(t, len(p), n)
+	}
+
+	return buf.Bytes()
+}
+
+func decrypt(key, stream []byte) ([]byte, error) {
+	r, err := encryption.NewReader(bytes.NewReader(stream), key)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(r)
+}
+
+func TestParseKey(t *testing.T) {
+	key := newKey(t)
+
+	got, err := encryption.ParseKey(base64.StdEncoding.EncodeToString(key) + "\n")
+	require.NoError(t, err)
+	assert.Equal(t, key, got)
+
+	for _, text := range []string{"", "not base64!", base64.StdEncoding.EncodeToString(key[:16])} {
+		_, err := encryption.ParseKey(text)
+		assert.ErrorIs(t, err, encryption.ErrInvalidKey, "key: %q", text)
+	}
+}
+
+func TestWriter_RoundTrip(t *testing.T) {
+	large := bytes.Repeat([]byte("0123456789abcdef"), encryption.MaxFrameSize/8)
+
+	subtests := []struct {
+		name   string
+		writes [][]byte
+	}{
+		{name: "SingleWrite", writes: [][]byte{[]byte(`{"Jack":{"Status":"Settled","Balance":0}}`)}},
+		// Like NDJSON files opened for appending, every batch is a frame of its own.
+		{name: "MultipleWrites", writes: [][]byte{[]byte("{\"AccountID\":\"Jack\"}\n"), {}, []byte("{\"AccountID\":\"Jen\"}\n")}},
+		{name: "LargerThanAFrame", writes: [][]byte{large}},
+		{name: "NoWrites"},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := newKey(t)
+			stream := encrypt(t, key, tt.writes...)
+			assert.NotContains(t, string(stream), "Jack")
+
+			got, err := decrypt(key, stream)
+			require.NoError(t, err)
+			assert.Equal(t, string(bytes.Join(tt.writes, nil)), string(got))
+		})
+	}
+}
+
+func TestWriter_Nonces(t *testing.T) {
+	// The same bytes encrypt differently every time, so equal lines cannot be told apart.
+	key := newKey(t)
+	assert.NotEqual(t, encrypt(t, key, []byte("Jack")), encrypt(t, key, []byte("Jack")))
+}
+
+func TestReader_Errors(t *testing.T) {
+	key := newKey(t)
+	stream := encrypt(t, key, []byte("Jack\n"), []byte("Jen\n"))
+	first := len(encrypt(t, key, []byte("Jack\n")))
+
+	tampered := bytes.Clone(stream)
+	tampered[len(tampered)-1] ^= 1
+
+	subtests := []struct {
+		name   string
+		key    []byte
+		stream []byte
+		want   *encryption.ErrInvalidFrame
+	}{
+		{
+			name:   "WrongKey",
+			key:    newKey(t),
+			stream: stream,
+			want:   &encryption.ErrInvalidFrame{Index: 0, Reason: "cannot authenticate the frame, the key may be wrong or the file changed"},
+		},
+		{
+			name:   "Tampered",
+			key:    key,
+			stream: tampered,
+			want:   &encryption.ErrInvalidFrame{Index: 1, Reason: "cannot authenticate the frame, the key may be wrong or the file changed"},
+		},
+		{
+			name:   "Truncated",
+			key:    key,
+			stream: stream[:len(stream)-1],
+			want:   &encryption.ErrInvalidFrame{Index: 1, Reason: "unexpected EOF"},
+		},
+		{
+			name:   "TruncatedHeader",
+			key:    key,
+			stream: stream[:first+2],
+			want:   &encryption.ErrInvalidFrame{Index: 1, Reason: "unexpected EOF"},
+		},
+		{
+			name:   "NotEncrypted",
+			key:    key,
+			stream: []byte(`{"Jack":{"Status":"Settled","Balance":0}}`),
+			want:   &encryption.ErrInvalidFrame{Index: 0, Reason: "invalid header, the file may not be encrypted"},
+		},
+		{
+			name:   "LengthTooLarge",
+			key:    key,
+			stream: []byte("SEWE\x01\xff\xff\xff\xff\x0f"),
+			want:   &encryption.ErrInvalidFrame{Index: 0, Reason: "invalid length 4294967295"},
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decrypt(tt.key, tt.stream)
+			assert.Equal(t, tt.want, err)
+		})
+	}
+}
+
+func TestNewWriter_InvalidKey(t *testing.T) {
+	_, err := encryption.NewWriter(io.Discard, []byte("too short"))
+	assert.ErrorIs(t, err, encryption.ErrInvalidKey)
+
+	_, err = encryption.NewReader(strings.NewReader(""), nil)
+	assert.ErrorIs(t, err, encryption.ErrInvalidKey)
+}
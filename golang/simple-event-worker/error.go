@@ -2,6 +2,7 @@ package simpleeventworker
 
 import (
 	"fmt"
+	"time"
 )
 
 var (
@@ -47,3 +48,200 @@ type ErrCannotTransactWithRecalledAccount struct {
 func (e *ErrCannotTransactWithRecalledAccount) Error() string {
 	return fmt.Sprintf(`cannot record charge or payment for recalled account with ID: "%s"`, e.AccountID)
 }
+
+type ErrUnknownPayloadField struct {
+	Field string
+}
+
+func (e *ErrUnknownPayloadField) Error() string {
+	return fmt.Sprintf(`unknown field in event payload: "%s"`, e.Field)
+}
+
+type ErrInvalidPayloadFieldValue struct {
+	Field string
+	Err   error
+}
+
+func (e *ErrInvalidPayloadFieldValue) Error() string {
+	return fmt.Sprintf(`invalid value for event payload field "%s": %s`, e.Field, e.Err)
+}
+
+func (e *ErrInvalidPayloadFieldValue) Unwrap() error {
+	return e.Err
+}
+
+type ErrInvalidTimestamp struct {
+	Err error
+}
+
+func (e *ErrInvalidTimestamp) Error() string {
+	return fmt.Sprintf(`invalid event timestamp: %s`, e.Err)
+}
+
+func (e *ErrInvalidTimestamp) Unwrap() error {
+	return e.Err
+}
+
+type ErrInvalidEffectiveAt struct {
+	Err error
+}
+
+func (e *ErrInvalidEffectiveAt) Error() string {
+	return fmt.Sprintf(`invalid event effective time: %s`, e.Err)
+}
+
+func (e *ErrInvalidEffectiveAt) Unwrap() error {
+	return e.Err
+}
+
+type ErrInvalidAmount struct {
+	AccountID string
+	Field     string
+	Amount    int
+}
+
+func (e *ErrInvalidAmount) Error() string {
+	return fmt.Sprintf(`invalid negative "%s" for account with ID "%s": %d`, e.Field, e.AccountID, e.Amount)
+}
+
+type ErrEmptyAccountID struct {
+	Type string
+}
+
+func (e *ErrEmptyAccountID) Error() string {
+	return fmt.Sprintf(`event of type "%s" has an empty AccountID`, e.Type)
+}
+
+type ErrUnsupportedEventVersion struct {
+	Type    string
+	Version int
+}
+
+func (e *ErrUnsupportedEventVersion) Error() string {
+	return fmt.Sprintf(`unsupported version %d of event type "%s"`, e.Version, e.Type)
+}
+
+type ErrInvalidUpcaster struct {
+	Type    string
+	Version int
+	Reason  string
+}
+
+func (e *ErrInvalidUpcaster) Error() string {
+	return fmt.Sprintf(`invalid upcaster of version %d of event type "%s": %s`, e.Version, e.Type, e.Reason)
+}
+
+type ErrInvalidAccount struct {
+	AccountID string
+	Reason    string
+}
+
+func (e *ErrInvalidAccount) Error() string {
+	return fmt.Sprintf(`invalid account with ID "%s": %s`, e.AccountID, e.Reason)
+}
+
+type ErrInvalidSnapshotAccount struct {
+	AccountID string
+	Reason    string
+}
+
+func (e *ErrInvalidSnapshotAccount) Error() string {
+	return fmt.Sprintf(`invalid account in snapshot with ID "%s": %s`, e.AccountID, e.Reason)
+}
+
+type ErrInvalidPayload struct {
+	Type      string
+	AccountID string
+	// Payload is the Go type of the payload found, e.g. "<nil>".
+	Payload string
+}
+
+func newErrInvalidPayload(event Event) *ErrInvalidPayload {
+	return &ErrInvalidPayload{Type: event.Type, AccountID: event.AccountID, Payload: fmt.Sprintf("%T", event.Payload)}
+}
+
+func (e *ErrInvalidPayload) Error() string {
+	return fmt.Sprintf(`invalid payload of type "%s" for event "%s" of account with ID "%s"`, e.Payload, e.Type, e.AccountID)
+}
+
+type ErrMalformedJSON struct {
+	// Offset is the number of bytes of input before the error.
+	Offset int64
+	Reason string
+}
+
+func (e *ErrMalformedJSON) Error() string {
+	return fmt.Sprintf(`malformed JSON at offset %d: %s`, e.Offset, e.Reason)
+}
+
+type ErrTenantMismatch struct {
+	// TenantID is the tenant of the service, empty if it is not pinned to one.
+	TenantID      string
+	EventTenantID string
+	AccountID     string
+}
+
+func (e *ErrTenantMismatch) Error() string {
+	return fmt.Sprintf(`event of tenant "%s" for account with ID "%s" given to the service of tenant "%s"`, e.EventTenantID, e.AccountID, e.TenantID)
+}
+
+type ErrNoPendingQueue struct {
+	AccountID   string
+	EffectiveAt time.Time
+}
+
+func (e *ErrNoPendingQueue) Error() string {
+	return fmt.Sprintf(`event for account with ID "%s" takes effect at %s, but the service has no pending queue`, e.AccountID, e.EffectiveAt.Format(time.RFC3339))
+}
+
+type ErrUnchainableEvent struct {
+	// Index is the index of the event among the ones appended.
+	Index int
+	Err   error
+}
+
+func (e *ErrUnchainableEvent) Error() string {
+	return fmt.Sprintf(`cannot chain event %d: %s`, e.Index, e.Err)
+}
+
+func (e *ErrUnchainableEvent) Unwrap() error {
+	return e.Err
+}
+
+type ErrInvalidChainLink struct {
+	Index  int
+	Reason string
+}
+
+func (e *ErrInvalidChainLink) Error() string {
+	return fmt.Sprintf(`invalid link %d of hash chain: %s`, e.Index, e.Reason)
+}
+
+type ErrCannotWriteOffAccount struct {
+	AccountID string
+	Status    string
+}
+
+func (e *ErrCannotWriteOffAccount) Error() string {
+	return fmt.Sprintf(`cannot write off account with status "%s", only recalled ones, with ID: "%s"`, e.Status, e.AccountID)
+}
+
+type ErrCannotRecoverAccount struct {
+	AccountID string
+	Status    string
+}
+
+func (e *ErrCannotRecoverAccount) Error() string {
+	return fmt.Sprintf(`cannot record recovery for account with status "%s", only written-off ones, with ID: "%s"`, e.Status, e.AccountID)
+}
+
+type ErrRecoveryExceedsWriteOff struct {
+	AccountID string
+	Amount    int
+	// Unrecovered is the part of the written-off amount of the account not recovered yet.
+	Unrecovered int
+}
+
+func (e *ErrRecoveryExceedsWriteOff) Error() string {
+	return fmt.Sprintf(`recovery of %d exceeds the unrecovered written-off amount %d of account with ID: "%s"`, e.Amount, e.Unrecovered, e.AccountID)
+}
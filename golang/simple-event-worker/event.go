@@ -1,27 +1,94 @@
 package simpleeventworker
 
 import (
+	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"io"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
 )
 
 type Service interface {
 	// ParseEvents parses a list of events from an io.Reader and returns a list of events.
 	// The requirements assume events are already in the correct order.
 	// The requirements also assume the input JSON is an array.
+	// Each event is validated as it is parsed, so bad upstream data is rejected before processing starts.
 	ParseEvents(r io.Reader) ([]Event, error)
 	// ProcessEvents processes a list of events and returns a map of accounts reduced to their final state.
 	// This function should return a map of accounts with their ID as the key.
 	// The requirements assume accounts are created prior to any charges, payments, etc.
 	//
 	// Idempotent: If processing an event results in an error, the function should stop processing events and return the error.
+	// With a DeadLetterSink, the event is quarantined and skipped instead.
 	ProcessEvents(events []Event) (map[string]Account, error)
+	// ApplyEvents is ProcessEvents starting from an existing state of accounts instead of no accounts.
+	// The given accounts are never modified. The new state is returned as a separate map.
+	//
+	// Events effective after the clock of the service are parked in its pending queue instead, and applied by the first
+	// batch once they are due, before its own events. Indexes in logs, dead letters and domain events count them.
+	// A service without a pending queue rejects them with ErrNoPendingQueue.
+	ApplyEvents(accounts map[string]Account, events []Event) (map[string]Account, error)
+	// ApplyBatch is ApplyEvents also returning the events it applied, e.g. to report on them.
+	ApplyBatch(accounts map[string]Account, events []Event) (*Batch, error)
+	// ProcessSource polls batches of events from a Source until it is exhausted, applying each one on top of the previous,
+	// starting from the given accounts. A batch is committed to its source only after it is applied successfully.
+	ProcessSource(ctx context.Context, accounts map[string]Account, source Source) (map[string]Account, error)
+}
+
+type EventService struct {
+	strictSchema    bool
+	instrumentation Instrumentation
+	logger          *slog.Logger
+	logPseudonym    func(tenantID, accountID string) string
+	outbox          Outbox
+	fastDecoder     bool
+	parseValidation bool
+	tenantID        string
+	deadLetters     DeadLetterSink
+	schema          *Schema
+	hashChain       *HashChain
+	clock           Clock
+	pending         *PendingQueue
 }
 
-type EventService struct{}
+// ServiceOption configures optional behavior of an EventService.
+type ServiceOption func(*EventService)
 
-func NewService() *EventService {
-	return &EventService{}
+// WithStrictSchema toggles strict payload decoding in ParseEvents.
+// In strict mode, a payload field outside of the event type's schema is rejected with ErrUnknownPayloadField.
+// The service is lenient by default, ignoring unknown payload fields.
+func WithStrictSchema(strict bool) ServiceOption {
+	return func(s *EventService) {
+		s.strictSchema = strict
+	}
+}
+
+// WithParseValidation toggles the validation of each event in ParseEvents. It is enabled by default.
+// Processing validates each event anyway, so disabling it only defers the rejection of an invalid event to processing,
+// e.g. so that an invalid event only fails the batch of its tenant.
+func WithParseValidation(enabled bool) ServiceOption {
+	return func(s *EventService) {
+		s.parseValidation = enabled
+	}
+}
+
+func NewService(opts ...ServiceOption) *EventService {
+	s := &EventService{
+		parseValidation: true,
+		schema:          defaultSchema,
+		instrumentation: noopInstrumentation{},
+		logger:          newDiscardLogger(),
+		outbox:          noopOutbox{},
+		clock:           SystemClock,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
 }
 
 const (
@@ -29,151 +96,649 @@ const (
 	EventTypeAccountChargeReceived  = "AccountChargeReceived"
 	EventTypeAccountPaymentReceived = "AccountPaymentReceived"
 	EventTypeAccountRecalled        = "AccountRecalled"
+	// EventTypeAccountWrittenOff writes off the debt of a recalled account, and EventTypeAccountRecoveryReceived
+	// records a payment against it afterwards. See writeoff.go.
+	EventTypeAccountWrittenOff       = "AccountWrittenOff"
+	EventTypeAccountRecoveryReceived = "AccountRecoveryReceived"
 
 	EventPayloadFieldAmount  = "Amount"
 	EventPayloadFieldBalance = "Balance"
+	EventPayloadFieldInvoice = "Invoice"
 )
 
 type Event struct {
-	Type      string       `json:"Type"`
-	AccountID string       `json:"AccountID"`
-	Payload   EventPayload `json:"Payload"`
+	Type string `json:"Type"`
+	// TenantID is the lending partner the account belongs to. AccountIDs are only unique within a tenant.
+	// It is empty for single-tenant inputs.
+	TenantID  string `json:"TenantID,omitempty"`
+	AccountID string `json:"AccountID"`
+	// Timestamp is when the event happened upstream, e.g. when a charge was invoiced. It dates open items for aging,
+	// and is the zero time if the producer does not send it.
+	Timestamp time.Time `json:"Timestamp,omitzero"`
+	// EffectiveAt is when a scheduled event, e.g. next month's installment, takes effect. A service parks an event
+	// effective after its clock in its pending queue until then. It is the zero time for events effective on arrival.
+	EffectiveAt time.Time    `json:"EffectiveAt,omitzero"`
+	Payload     EventPayload `json:"Payload"`
 }
 
 type EventPayload interface {
 	json.Unmarshaler
 }
 
+// NewAccountCreatedEvent builds an `AccountCreated` event with its payload, for producers using this package.
+func NewAccountCreatedEvent(accountID string, balance int) Event {
+	return Event{Type: EventTypeAccountCreated, AccountID: accountID, Payload: &EventPayloadAccountCreated{Balance: balance}}
+}
+
+// NewChargeEvent builds an `AccountChargeReceived` event. The amount is positive, as on the wire.
+func NewChargeEvent(accountID string, amount int) Event {
+	return Event{Type: EventTypeAccountChargeReceived, AccountID: accountID, Payload: &EventPayloadAccountTransactionReceived{Amount: amount}}
+}
+
+// NewPaymentEvent builds an `AccountPaymentReceived` event. The amount is positive, as on the wire.
+func NewPaymentEvent(accountID string, amount int) Event {
+	return Event{Type: EventTypeAccountPaymentReceived, AccountID: accountID, Payload: &EventPayloadAccountTransactionReceived{Amount: amount}}
+}
+
+// NewRecalledEvent builds an `AccountRecalled` event, which has no payload.
+func NewRecalledEvent(accountID string) Event {
+	return Event{Type: EventTypeAccountRecalled, AccountID: accountID}
+}
+
+// NewWrittenOffEvent builds an `AccountWrittenOff` event, which has no payload.
+func NewWrittenOffEvent(accountID string) Event {
+	return Event{Type: EventTypeAccountWrittenOff, AccountID: accountID}
+}
+
+// NewRecoveryEvent builds an `AccountRecoveryReceived` event. The amount is positive, as for payments.
+func NewRecoveryEvent(accountID string, amount int) Event {
+	return Event{Type: EventTypeAccountRecoveryReceived, AccountID: accountID, Payload: &EventPayloadAccountTransactionReceived{Amount: amount}}
+}
+
+// accountCreatedPayload returns the payload of an `AccountCreated` event.
+// Events can be built by hand, so the payload is checked rather than asserted.
+func (e Event) accountCreatedPayload() (*EventPayloadAccountCreated, error) {
+	payload, ok := e.Payload.(*EventPayloadAccountCreated)
+	if !ok || payload == nil {
+		return nil, newErrInvalidPayload(e)
+	}
+
+	return payload, nil
+}
+
+// transactionPayload returns the payload of an `AccountChargeReceived`, `AccountPaymentReceived` or
+// `AccountRecoveryReceived` event.
+func (e Event) transactionPayload() (*EventPayloadAccountTransactionReceived, error) {
+	payload, ok := e.Payload.(*EventPayloadAccountTransactionReceived)
+	if !ok || payload == nil {
+		return nil, newErrInvalidPayload(e)
+	}
+
+	return payload, nil
+}
+
 type EventPayloadAccountCreated struct {
 	Balance int `json:"Balance"`
 }
 
-// EventPayloadAccountTransactionReceived represents the payload for the `AccountChargeReceived` and `AccountPaymentReceived` events.
+// EventPayloadAccountTransactionReceived represents the payload for the `AccountChargeReceived`, `AccountPaymentReceived`
+// and `AccountRecoveryReceived` events.
 type EventPayloadAccountTransactionReceived struct {
 	Amount int `json:"Amount"`
+	// Invoice optionally references an invoice: the one a charge bills, or the one a payment settles first.
+	// A recovery pays the written-off amount as a whole, so it ignores its invoice.
+	Invoice string `json:"Invoice,omitempty"`
 }
 
 func (p *EventPayloadAccountCreated) UnmarshalJSON(data []byte) error {
-	aux := map[string]int{}
-	if err := json.Unmarshal(data, &aux); err != nil {
+	return p.unmarshalJSON(data, false)
+}
+
+func (p *EventPayloadAccountCreated) unmarshalJSON(data []byte, strict bool) error {
+	present, err := decodePayloadFields(data, strict, map[string]any{EventPayloadFieldBalance: &p.Balance})
+	if err != nil {
 		return err
 	}
 
-	balance, ok := aux[EventPayloadFieldBalance]
-	if !ok {
+	if !present[EventPayloadFieldBalance] {
 		return &ErrMissingFieldInEventPayloadField{Field: EventPayloadFieldBalance}
 	}
 
-	p.Balance = balance
-
 	return nil
 }
 
 func (p *EventPayloadAccountTransactionReceived) UnmarshalJSON(data []byte) error {
-	aux := map[string]int{}
-	if err := json.Unmarshal(data, &aux); err != nil {
+	return p.unmarshalJSON(data, false)
+}
+
+func (p *EventPayloadAccountTransactionReceived) unmarshalJSON(data []byte, strict bool) error {
+	present, err := decodePayloadFields(data, strict, map[string]any{
+		EventPayloadFieldAmount:  &p.Amount,
+		EventPayloadFieldInvoice: &p.Invoice,
+	})
+	if err != nil {
 		return err
 	}
 
-	amount, ok := aux[EventPayloadFieldAmount]
-	if !ok {
+	if !present[EventPayloadFieldAmount] {
 		return &ErrMissingFieldInEventPayloadField{Field: EventPayloadFieldAmount}
 	}
 
-	p.Amount = amount
-
 	return nil
 }
 
-func (e *Event) UnmarshalJSON(data []byte) error {
-	aux := &struct {
-		Type      string          `json:"Type"`
-		AccountID string          `json:"AccountID"`
-		Payload   json.RawMessage `json:"Payload"`
-	}{}
+// decodePayloadFields decodes the fields of a payload object listed in `known` into the values they point to,
+// e.g. an *int for an integer field, and reports which ones are present. Fields are decoded in order of their names.
+// Other fields are skipped without being decoded, so their type does not matter,
+// unless `strict` is set, in which case the first one (by name) is reported as ErrUnknownPayloadField.
+func decodePayloadFields(data []byte, strict bool, known map[string]any) (map[string]bool, error) {
+	aux := map[string]json.RawMessage{}
 	if err := json.Unmarshal(data, &aux); err != nil {
-		return err
+		return nil, err
 	}
 
-	var payload EventPayload
-	switch aux.Type {
+	names := make([]string, 0, len(aux))
+	for name := range aux {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	present := make(map[string]bool, len(known))
+	for _, name := range names {
+		value, ok := known[name]
+		if !ok {
+			if strict {
+				return nil, &ErrUnknownPayloadField{Field: name}
+			}
+			continue
+		}
+
+		if err := json.Unmarshal(aux[name], value); err != nil {
+			return nil, &ErrInvalidPayloadFieldValue{Field: name, Err: err}
+		}
+		present[name] = true
+	}
+
+	return present, nil
+}
+
+// UnmarshalJSON decodes an event in the wire format, upcasting it with DefaultSchema.
+func (e *Event) UnmarshalJSON(data []byte) error {
+	return e.unmarshalJSON(data, false, defaultSchema)
+}
+
+// MarshalJSON encodes the event in the wire format read by UnmarshalJSON.
+// The payload of an `AccountRecalled` or `AccountWrittenOff` event is always encoded as an empty object.
+func (e Event) MarshalJSON() ([]byte, error) {
+	var payload any
+	switch e.Type {
 	case EventTypeAccountCreated:
-		temp := &EventPayloadAccountCreated{}
-		if err := json.Unmarshal(aux.Payload, temp); err != nil {
-			return err
+		p, err := e.accountCreatedPayload()
+		if err != nil {
+			return nil, err
 		}
-		payload = temp
-	case EventTypeAccountChargeReceived, EventTypeAccountPaymentReceived:
-		temp := &EventPayloadAccountTransactionReceived{}
-		if err := json.Unmarshal(aux.Payload, temp); err != nil {
-			return err
+		payload = p
+	case EventTypeAccountChargeReceived, EventTypeAccountPaymentReceived, EventTypeAccountRecoveryReceived:
+		p, err := e.transactionPayload()
+		if err != nil {
+			return nil, err
 		}
-		payload = temp
-	case EventTypeAccountRecalled:
-		// No payload required. If network costs are a concern, we can enforce byte size limits for aux.Payload.
-		payload = nil
+		payload = p
+	case EventTypeAccountRecalled, EventTypeAccountWrittenOff:
+		payload = struct{}{}
 	default:
-		return &ErrUnsupportedEventType{Type: aux.Type}
+		return nil, &ErrUnsupportedEventType{Type: e.Type}
+	}
+
+	var timestamp, effectiveAt *time.Time
+	if !e.Timestamp.IsZero() {
+		timestamp = &e.Timestamp
+	}
+	if !e.EffectiveAt.IsZero() {
+		effectiveAt = &e.EffectiveAt
 	}
 
-	e.Type = aux.Type
+	return json.Marshal(struct {
+		Type        string     `json:"Type"`
+		TenantID    string     `json:"TenantID,omitempty"`
+		AccountID   string     `json:"AccountID"`
+		Timestamp   *time.Time `json:"Timestamp,omitempty"`
+		EffectiveAt *time.Time `json:"EffectiveAt,omitempty"`
+		Payload     any        `json:"Payload"`
+	}{
+		Type:        e.Type,
+		TenantID:    e.TenantID,
+		AccountID:   e.AccountID,
+		Timestamp:   timestamp,
+		EffectiveAt: effectiveAt,
+		Payload:     payload,
+	})
+}
+
+func (e *Event) unmarshalJSON(data []byte, strict bool, schema *Schema) error {
+	aux := &struct {
+		Type        string          `json:"Type"`
+		Version     int             `json:"Version"`
+		TenantID    string          `json:"TenantID"`
+		AccountID   string          `json:"AccountID"`
+		Timestamp   json.RawMessage `json:"Timestamp"`
+		EffectiveAt json.RawMessage `json:"EffectiveAt"`
+		Payload     json.RawMessage `json:"Payload"`
+	}{}
+	// Unmarshal into the struct itself: a JSON null must leave it empty rather than set a pointer to it to nil.
+	if err := json.Unmarshal(data, aux); err != nil {
+		return err
+	}
+
+	timestamp, err := decodeTimestamp(aux.Timestamp)
+	if err != nil {
+		return err
+	}
+	effectiveAt, err := decodeEffectiveAt(aux.EffectiveAt)
+	if err != nil {
+		return err
+	}
+
+	eventType, payloadData := aux.Type, []byte(aux.Payload)
+	upcaster, ok, err := schema.resolve(aux.Type, aux.Version)
+	if err != nil {
+		return err
+	}
+	if ok {
+		eventType, payloadData = upcaster.To, upcaster.upcastPayload(payloadData)
+	}
+
+	payload, err := decodeEventPayload(eventType, payloadData, strict)
+	if err != nil {
+		return err
+	}
+
+	e.Type = eventType
+	e.TenantID = aux.TenantID
 	e.AccountID = aux.AccountID
+	e.Timestamp = timestamp
+	e.EffectiveAt = effectiveAt
 	e.Payload = payload
 
 	return nil
 }
 
-func (s *EventService) ParseEvents(r io.Reader) ([]Event, error) {
-	events := []Event{}
+// decodeTimestamp decodes the Timestamp of an event, an RFC 3339 string. A missing Timestamp or a JSON null is the zero time.
+func decodeTimestamp(data []byte) (time.Time, error) {
+	var timestamp time.Time
+	if len(data) == 0 || string(data) == "null" {
+		return timestamp, nil
+	}
+	if err := timestamp.UnmarshalJSON(data); err != nil {
+		return time.Time{}, &ErrInvalidTimestamp{Err: err}
+	}
+
+	return timestamp, nil
+}
+
+// decodeEffectiveAt decodes the EffectiveAt of an event, like decodeTimestamp.
+func decodeEffectiveAt(data []byte) (time.Time, error) {
+	var effectiveAt time.Time
+	if len(data) == 0 || string(data) == "null" {
+		return effectiveAt, nil
+	}
+	if err := effectiveAt.UnmarshalJSON(data); err != nil {
+		return time.Time{}, &ErrInvalidEffectiveAt{Err: err}
+	}
+
+	return effectiveAt, nil
+}
+
+// decodeEventPayload decodes the payload of an event of a canonical event type.
+func decodeEventPayload(eventType string, data []byte, strict bool) (EventPayload, error) {
+	switch eventType {
+	case EventTypeAccountCreated:
+		payload := &EventPayloadAccountCreated{}
+		if err := payload.unmarshalJSON(data, strict); err != nil {
+			return nil, err
+		}
+		return payload, nil
+	case EventTypeAccountChargeReceived, EventTypeAccountPaymentReceived, EventTypeAccountRecoveryReceived:
+		payload := &EventPayloadAccountTransactionReceived{}
+		if err := payload.unmarshalJSON(data, strict); err != nil {
+			return nil, err
+		}
+		return payload, nil
+	case EventTypeAccountRecalled, EventTypeAccountWrittenOff:
+		// No payload required. If network costs are a concern, we can enforce byte size limits for the payload.
+		// In strict mode, the payload may still be omitted, but any field it carries is unknown.
+		if strict && len(data) > 0 {
+			if _, err := decodePayloadFields(data, strict, nil); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	default:
+		return nil, &ErrUnsupportedEventType{Type: eventType}
+	}
+}
+
+// eventDecoder lets a json.Decoder decode an Event with the service's schema mode and schema.
+type eventDecoder struct {
+	event  *Event
+	strict bool
+	schema *Schema
+}
+
+func (d *eventDecoder) UnmarshalJSON(data []byte) error {
+	return d.event.unmarshalJSON(data, d.strict, d.schema)
+}
 
-	decoder := json.NewDecoder(r)
-	token, err := decoder.Token()
+// eventStreamDecoder decodes the events of a JSON array one at a time.
+type eventStreamDecoder interface {
+	// Start consumes the opening bracket of the array.
+	Start() error
+	// Decode decodes the next event into e. It returns io.EOF after the last event.
+	Decode(e *Event) error
+	// Raw returns the JSON of the element of the last call to Decode, or nil if it is not a well-formed JSON value.
+	// It is only valid until the next call to Decode.
+	Raw() []byte
+}
+
+// jsonEventDecoder is the eventStreamDecoder built on encoding/json.
+type jsonEventDecoder struct {
+	decoder *json.Decoder
+	strict  bool
+	schema  *Schema
+	// keepRaw decodes each element to buf before decoding it to an event, so Raw can return it.
+	keepRaw bool
+	buf     json.RawMessage
+	raw     []byte
+}
+
+func newJSONEventDecoder(r io.Reader, strict, keepRaw bool, schema *Schema) *jsonEventDecoder {
+	return &jsonEventDecoder{decoder: json.NewDecoder(r), strict: strict, keepRaw: keepRaw, schema: schema}
+}
+
+func (d *jsonEventDecoder) Start() error {
+	token, err := d.decoder.Token()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	if token != json.Delim('[') {
-		return nil, ErrInputJSONIsNotArray
+		return ErrInputJSONIsNotArray
+	}
+
+	return nil
+}
+
+func (d *jsonEventDecoder) Decode(e *Event) error {
+	d.raw = nil
+	if !d.decoder.More() {
+		return io.EOF
+	}
+
+	if !d.keepRaw {
+		err := d.decoder.Decode(&eventDecoder{event: e, strict: d.strict, schema: d.schema})
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+
+		return err
 	}
 
-	for decoder.More() {
-		var event Event
-		if err := decoder.Decode(&event); err != nil {
+	if err := d.decoder.Decode(&d.buf); err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+
+		return err
+	}
+	d.raw = d.buf
+
+	return json.Unmarshal(d.buf, &eventDecoder{event: e, strict: d.strict, schema: d.schema})
+}
+
+func (d *jsonEventDecoder) Raw() []byte {
+	return d.raw
+}
+
+func (s *EventService) ParseEvents(r io.Reader) ([]Event, error) {
+	var decoder eventStreamDecoder = newJSONEventDecoder(r, s.strictSchema, s.deadLetters != nil, s.schema)
+	if s.fastDecoder {
+		decoder = newFastDecoder(r, s.strictSchema, s.deadLetters != nil, s.schema)
+	}
+	if err := decoder.Start(); err != nil {
+		return nil, err
+	}
+
+	return s.decodeEvents(decoder.Decode, decoder.Raw)
+}
+
+// EventDecoder decodes events one at a time from an input in another format than JSON, e.g. the rows of a CSV file.
+type EventDecoder interface {
+	// Decode returns the next event. It returns io.EOF after the last event.
+	Decode() (Event, error)
+	// Raw returns the input of the last call to Decode as JSON, for its dead letter, or nil if the decoder cannot carry
+	// on after it, e.g. because it is malformed. It is only valid until the next call to Decode.
+	Raw() []byte
+}
+
+// DecodeEvents is ParseEvents for the events of a decoder. Its events are validated, and quarantined to the
+// DeadLetterSink of the service when they are rejected, as the events of a JSON input are.
+func (s *EventService) DecodeEvents(decoder EventDecoder) ([]Event, error) {
+	return s.decodeEvents(func(e *Event) error {
+		var err error
+		*e, err = decoder.Decode()
+		return err
+	}, decoder.Raw)
+}
+
+// decodeEvents decodes events with `decode` until io.EOF, validating them unless disabled, and quarantining the ones
+// rejected with the input returned by `raw`, if any.
+func (s *EventService) decodeEvents(decode func(e *Event) error, raw func() []byte) ([]Event, error) {
+	events := []Event{}
+	letters := []DeadLetter{}
+
+	// A single event is decoded into, since it escapes through the decoder.
+	var event Event
+	for index := 0; ; index++ {
+		event = Event{}
+		err := decode(&event)
+		if err == io.EOF {
+			break
+		}
+		if err == nil && s.parseValidation {
+			err = event.Validate()
+		}
+		s.instrumentation.EventParsed(event, err)
+		if err != nil {
+			// Only a well-formed element can be skipped, since the next one starts right after it.
+			if raw := raw(); s.deadLetters != nil && raw != nil {
+				s.warnEvent("event quarantined while parsing", index, event, err)
+				letters = append(letters, newParseDeadLetter(index, raw, err))
+				continue
+			}
+			s.warnEvent("event rejected while parsing", index, event, err)
 			return nil, err
 		}
+		s.debugEvent("event parsed", index, event)
 		events = append(events, event)
 	}
 
+	if err := s.quarantine(letters); err != nil {
+		return nil, err
+	}
+
 	return events, nil
 }
 
 func (s *EventService) ProcessEvents(events []Event) (map[string]Account, error) {
-	accounts := map[string]Account{}
+	return s.ApplyEvents(map[string]Account{}, events)
+}
 
-	for _, event := range events {
-		switch event.Type {
-		case EventTypeAccountCreated:
-			if err := s.processEventTypeAccountCreated(event, accounts); err != nil {
-				return nil, err
-			}
-		case EventTypeAccountChargeReceived:
-			if err := s.processEventTypeAccountChargeReceived(event, accounts); err != nil {
-				return nil, err
-			}
-		case EventTypeAccountPaymentReceived:
-			if err := s.processEventTypeAccountPaymentReceived(event, accounts); err != nil {
-				return nil, err
-			}
-		case EventTypeAccountRecalled:
-			if err := s.processEventTypeAccountRecalled(event, accounts); err != nil {
-				return nil, err
+func (s *EventService) ApplyEvents(initial map[string]Account, events []Event) (map[string]Account, error) {
+	b, err := s.ApplyBatch(initial, events)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.Accounts, nil
+}
+
+func (s *EventService) ApplyBatch(initial map[string]Account, events []Event) (*Batch, error) {
+	b, err := s.applyBatch(initial, events)
+	if err != nil {
+		return nil, err
+	}
+	s.commitBatch(b)
+
+	return b, nil
+}
+
+// Batch is the outcome of a batch of events applied by a service.
+//
+// Its effects on the pending queue and the hash chain of the service are only made by commitBatch, once the batch is
+// committed, so a batch that is delivered again is not parked or chained twice.
+type Batch struct {
+	// Accounts holds the new state of the accounts.
+	Accounts map[string]Account
+	// Applied holds the events applied to the accounts, in order: the pending events that came due, then the events of
+	// the batch. Events quarantined or parked until they take effect are not applied.
+	Applied []Event
+
+	// pending are the events of the pending queue once the batch is committed.
+	pending []Event
+	// heads are the heads of the events of the batch in the hash chain, if any.
+	heads [][sha256.Size]byte
+}
+
+// commitBatch replaces the pending events with the ones of the batch, and appends its events to the hash chain.
+func (s *EventService) commitBatch(b *Batch) {
+	if s.pending != nil {
+		s.pending.set(b.pending)
+	}
+	if s.hashChain != nil {
+		s.hashChain.heads = append(s.hashChain.heads, b.heads...)
+	}
+}
+
+// applyBatch applies a batch of events on top of `initial`, and publishes its domain events and dead letters, leaving
+// the pending queue and the hash chain to commitBatch.
+func (s *EventService) applyBatch(initial map[string]Account, events []Event) (*Batch, error) {
+	// Accounts are values whose open items are copied on write, so a shallow copy is enough to leave `initial` untouched
+	// if an event fails.
+	accounts := make(map[string]Account, len(initial))
+	maps.Copy(accounts, initial)
+
+	// Domain events are only published once the whole batch is applied, so a failing batch emits nothing.
+	domainEvents := []DomainEvent{}
+	letters := []DeadLetter{}
+	applied := []Event{}
+
+	// The pending events due by now are applied before the events of the batch, as its first events.
+	now := s.clock.Now()
+	var due, pending []Event
+	if s.pending != nil {
+		due, pending = s.pending.due(now)
+	}
+	fed := slices.Concat(due, events)
+
+	for index, event := range fed {
+		before, existed := accounts[event.AccountID]
+
+		// An event taking effect later is only checked, and parked until it does.
+		scheduled := event.EffectiveAt.After(now)
+		var err error
+		if scheduled && s.pending == nil {
+			err = &ErrNoPendingQueue{AccountID: event.AccountID, EffectiveAt: event.EffectiveAt}
+		} else if scheduled {
+			err = s.checkEvent(event)
+		} else {
+			start := time.Now()
+			err = s.processEvent(event, accounts)
+			s.instrumentation.EventProcessed(event, time.Since(start), err)
+		}
+		if err != nil && s.deadLetters != nil {
+			// A rejected event never modifies the accounts, so skipping it leaves them as they were before it.
+			s.warnEvent("event quarantined while processing", index, event, err)
+			letters = append(letters, newDeadLetter(index, event, err))
+			continue
+		}
+		if err != nil {
+			s.warnEvent("event rejected while processing", index, event, err)
+			return nil, err
+		}
+		if scheduled {
+			s.debugEvent("event pending", index, event)
+			pending = append(pending, event)
+			continue
+		}
+		s.debugEvent("event processed", index, event)
+		applied = append(applied, event)
+
+		if existed {
+			if domainEvent, ok := newDomainEvent(index, event.TenantID, before, accounts[event.AccountID]); ok {
+				domainEvents = append(domainEvents, domainEvent)
 			}
-		default:
-			return nil, &ErrUnsupportedEventType{Type: event.Type}
 		}
 	}
 
-	return accounts, nil
+	// The chain already holds the pending events applied now, from the batch that parked them.
+	var heads [][sha256.Size]byte
+	if s.hashChain != nil {
+		var err error
+		if heads, err = s.hashChain.next(events); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.quarantine(letters); err != nil {
+		return nil, err
+	}
+
+	if len(domainEvents) > 0 {
+		if err := s.outbox.Publish(domainEvents); err != nil {
+			s.logger.Error("cannot publish domain events", slog.Int("domain_events", len(domainEvents)), slog.Any("error", err))
+			return nil, err
+		}
+	}
+
+	s.instrumentation.AccountsProcessed(accounts)
+	s.logger.Info("events processed", slog.Int("events", len(events)), slog.Int("accounts", len(accounts)))
+
+	return &Batch{Accounts: accounts, Applied: applied, pending: slices.Clone(pending), heads: heads}, nil
+}
+
+// checkEvent rejects an event the service can never apply, whatever the state of the accounts.
+func (s *EventService) checkEvent(event Event) error {
+	if err := event.Validate(); err != nil {
+		return err
+	}
+	if event.TenantID != s.tenantID {
+		return &ErrTenantMismatch{TenantID: s.tenantID, EventTenantID: event.TenantID, AccountID: event.AccountID}
+	}
+
+	return nil
+}
+
+func (s *EventService) processEvent(event Event, accounts map[string]Account) error {
+	if err := s.checkEvent(event); err != nil {
+		return err
+	}
+
+	switch event.Type {
+	case EventTypeAccountCreated:
+		return s.processEventTypeAccountCreated(event, accounts)
+	case EventTypeAccountChargeReceived:
+		return s.processEventTypeAccountChargeReceived(event, accounts)
+	case EventTypeAccountPaymentReceived:
+		return s.processEventTypeAccountPaymentReceived(event, accounts)
+	case EventTypeAccountRecalled:
+		return s.processEventTypeAccountRecalled(event, accounts)
+	case EventTypeAccountWrittenOff:
+		return s.processEventTypeAccountWrittenOff(event, accounts)
+	case EventTypeAccountRecoveryReceived:
+		return s.processEventTypeAccountRecoveryReceived(event, accounts)
+	default:
+		return &ErrUnsupportedEventType{Type: event.Type}
+	}
 }
 
 func (_ EventService) processEventTypeAccountCreated(event Event, accounts map[string]Account) error {
@@ -181,10 +746,16 @@ func (_ EventService) processEventTypeAccountCreated(event Event, accounts map[s
 		return &ErrAccountAlreadyExists{AccountID: event.AccountID}
 	}
 
+	payload, err := event.accountCreatedPayload()
+	if err != nil {
+		return err
+	}
+
+	// The opening balance is the first open item of the account.
 	account := &Account{
 		ID: event.AccountID,
 	}
-	if err := account.RecordTransaction(event.Payload.(*EventPayloadAccountCreated).Balance); err != nil {
+	if err := account.RecordCharge("", payload.Balance, event.Timestamp); err != nil {
 		return err
 	}
 
@@ -199,7 +770,7 @@ func (_ EventService) processEventTypeAccountRecalled(event Event, accounts map[
 		return &ErrAccountDoesNotExist{AccountID: event.AccountID}
 	}
 
-	account.status = AccountStatusRecalled
+	account.Recall()
 	accounts[event.AccountID] = account
 
 	return nil
@@ -211,7 +782,12 @@ func (_ EventService) processEventTypeAccountChargeReceived(event Event, account
 		return &ErrAccountDoesNotExist{AccountID: event.AccountID}
 	}
 
-	if err := account.RecordTransaction(event.Payload.(*EventPayloadAccountTransactionReceived).Amount); err != nil {
+	payload, err := event.transactionPayload()
+	if err != nil {
+		return err
+	}
+
+	if err := account.RecordCharge(payload.Invoice, payload.Amount, event.Timestamp); err != nil {
 		return err
 	}
 
@@ -226,7 +802,47 @@ func (_ EventService) processEventTypeAccountPaymentReceived(event Event, accoun
 		return &ErrAccountDoesNotExist{AccountID: event.AccountID}
 	}
 
-	if err := account.RecordTransaction(-event.Payload.(*EventPayloadAccountTransactionReceived).Amount); err != nil {
+	payload, err := event.transactionPayload()
+	if err != nil {
+		return err
+	}
+
+	if err := account.RecordPayment(payload.Invoice, payload.Amount); err != nil {
+		return err
+	}
+
+	accounts[event.AccountID] = account
+
+	return nil
+}
+
+func (_ EventService) processEventTypeAccountWrittenOff(event Event, accounts map[string]Account) error {
+	account, ok := accounts[event.AccountID]
+	if !ok {
+		return &ErrAccountDoesNotExist{AccountID: event.AccountID}
+	}
+
+	if err := account.WriteOff(); err != nil {
+		return err
+	}
+
+	accounts[event.AccountID] = account
+
+	return nil
+}
+
+func (_ EventService) processEventTypeAccountRecoveryReceived(event Event, accounts map[string]Account) error {
+	account, ok := accounts[event.AccountID]
+	if !ok {
+		return &ErrAccountDoesNotExist{AccountID: event.AccountID}
+	}
+
+	payload, err := event.transactionPayload()
+	if err != nil {
+		return err
+	}
+
+	if err := account.RecordRecovery(payload.Amount); err != nil {
 		return err
 	}
 
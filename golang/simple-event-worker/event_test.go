@@ -1,7 +1,10 @@
 package simpleeventworker_test
 
 import (
+	"bytes"
+	"encoding/json"
 	"io"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -60,6 +63,16 @@ func TestEvent_ParseEvents_Success(t *testing.T) {
 			input: strings.NewReader(`[]`),
 			want:  []event.Event{},
 		},
+		{
+			name:  "UnknownPayloadFieldIgnored",
+			input: strings.NewReader(`[{"Type":"AccountCreated","AccountID":"Jack","Payload":{"Balance":50,"Currency":"PHP"}}]`),
+			want:  []event.Event{{Type: event.EventTypeAccountCreated, AccountID: "Jack", Payload: &event.EventPayloadAccountCreated{Balance: 50}}},
+		},
+		{
+			name:  "TenantID",
+			input: strings.NewReader(`[{"Type":"AccountRecalled","TenantID":"acme","AccountID":"Jack"}]`),
+			want:  []event.Event{{Type: event.EventTypeAccountRecalled, TenantID: "acme", AccountID: "Jack"}},
+		},
 	}
 
 	for _, tt := range subtests {
@@ -102,6 +115,29 @@ func TestEvent_ParseEvents_CustomErrors(t *testing.T) {
 			input: strings.NewReader(`[{"Type":"AccountPaymentReceived","AccountID":"Jack","Payload":{"Balance":50}}]`),
 			want:  &event.ErrMissingFieldInEventPayloadField{Field: event.EventPayloadFieldAmount},
 		},
+		{
+			name:  "ErrInvalidPayloadFieldValue",
+			input: strings.NewReader(`[{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":"25"}}]`),
+			want: &event.ErrInvalidPayloadFieldValue{
+				Field: event.EventPayloadFieldAmount,
+				Err:   &json.UnmarshalTypeError{Value: "string", Type: reflect.TypeOf(0), Offset: 4},
+			},
+		},
+		{
+			name:  "ErrUnsupportedEventType NullEvent",
+			input: strings.NewReader(`[null]`),
+			want:  &event.ErrUnsupportedEventType{Type: ""},
+		},
+		{
+			name:  "ErrInvalidAmount",
+			input: strings.NewReader(`[{"Type":"AccountPaymentReceived","AccountID":"Jack","Payload":{"Amount":-25}}]`),
+			want:  &event.ErrInvalidAmount{AccountID: "Jack", Field: event.EventPayloadFieldAmount, Amount: -25},
+		},
+		{
+			name:  "ErrEmptyAccountID",
+			input: strings.NewReader(`[{"Type":"AccountCreated","AccountID":"","Payload":{"Balance":50}}]`),
+			want:  &event.ErrEmptyAccountID{Type: event.EventTypeAccountCreated},
+		},
 	}
 
 	for _, tt := range subtests {
@@ -113,6 +149,69 @@ func TestEvent_ParseEvents_CustomErrors(t *testing.T) {
 	}
 }
 
+func TestEvent_ParseEvents_StrictSchema(t *testing.T) {
+	s := event.NewService(event.WithStrictSchema(true))
+
+	subtests := []struct {
+		name  string
+		input io.Reader
+		want  error
+	}{
+		{
+			name:  "AccountCreated",
+			input: strings.NewReader(`[{"Type":"AccountCreated","AccountID":"Jack","Payload":{"Balance":50}}]`),
+			want:  nil,
+		},
+		{
+			name:  "AccountRecalled",
+			input: strings.NewReader(`[{"Type":"AccountRecalled","AccountID":"Jack","Payload":{}}]`),
+			want:  nil,
+		},
+		{
+			name:  "ErrUnknownPayloadField AccountCreated",
+			input: strings.NewReader(`[{"Type":"AccountCreated","AccountID":"Jack","Payload":{"Balance":50,"Currency":"PHP"}}]`),
+			want:  &event.ErrUnknownPayloadField{Field: "Currency"},
+		},
+		{
+			name:  "ErrUnknownPayloadField AccountChargeReceived",
+			input: strings.NewReader(`[{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":25,"Balance":50}}]`),
+			want:  &event.ErrUnknownPayloadField{Field: "Balance"},
+		},
+		{
+			name:  "ErrUnknownPayloadField AccountRecalled",
+			input: strings.NewReader(`[{"Type":"AccountRecalled","AccountID":"Jack","Payload":{"Amount":25}}]`),
+			want:  &event.ErrUnknownPayloadField{Field: "Amount"},
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.ParseEvents(tt.input)
+			if tt.want == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.want.Error())
+		})
+	}
+}
+
+func TestEvent_ParseEvents_WithParseValidation(t *testing.T) {
+	input := `[{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":-25}}]`
+
+	_, err := event.NewService().ParseEvents(strings.NewReader(input))
+	assert.EqualError(t, err, (&event.ErrInvalidAmount{AccountID: "Jack", Field: event.EventPayloadFieldAmount, Amount: -25}).Error())
+
+	s := event.NewService(event.WithParseValidation(false))
+	events, err := s.ParseEvents(strings.NewReader(input))
+	assert.NoError(t, err)
+	assert.Equal(t, []event.Event{event.NewChargeEvent("Jack", -25)}, events)
+
+	// The event is still rejected when it is processed.
+	_, err = s.ProcessEvents(events)
+	assert.EqualError(t, err, (&event.ErrInvalidAmount{AccountID: "Jack", Field: event.EventPayloadFieldAmount, Amount: -25}).Error())
+}
+
 func TestEvent_ProcessEvents_Success(t *testing.T) {
 	s := event.NewService()
 
@@ -138,7 +237,7 @@ func TestEvent_ProcessEvents_Success(t *testing.T) {
 				{Type: event.EventTypeAccountChargeReceived, AccountID: "Jack", Payload: &event.EventPayloadAccountTransactionReceived{Amount: 25}},
 			},
 			want: func() map[string]event.Account {
-				account := event.NewAccount("Jack", 75)
+				account := accountFromJSON(t, `{"ID":"Jack","Status":"Outstanding","Balance":75,"OpenItems":[{"Amount":50,"Remaining":50},{"Amount":25,"Remaining":25}]}`)
 				return map[string]event.Account{account.ID: *account}
 			}(),
 		},
@@ -149,7 +248,7 @@ func TestEvent_ProcessEvents_Success(t *testing.T) {
 				{Type: event.EventTypeAccountPaymentReceived, AccountID: "Jack", Payload: &event.EventPayloadAccountTransactionReceived{Amount: 25}},
 			},
 			want: func() map[string]event.Account {
-				account := event.NewAccount("Jack", 25)
+				account := accountFromJSON(t, `{"ID":"Jack","Status":"Outstanding","Balance":25,"OpenItems":[{"Amount":50,"Remaining":25}]}`)
 				return map[string]event.Account{account.ID: *account}
 			}(),
 		},
@@ -318,6 +417,51 @@ func TestEvent_ProcessEvents_CustomErrors(t *testing.T) {
 			},
 			want: &event.ErrCannotTransactWithRecalledAccount{AccountID: "Jack"},
 		},
+		{
+			name: "ErrInvalidAmount",
+			events: []event.Event{
+				{Type: event.EventTypeAccountCreated, AccountID: "Jack", Payload: &event.EventPayloadAccountCreated{Balance: -50}},
+			},
+			want: &event.ErrInvalidAmount{AccountID: "Jack", Field: event.EventPayloadFieldBalance, Amount: -50},
+		},
+		{
+			name: "ErrEmptyAccountID",
+			events: []event.Event{
+				{Type: event.EventTypeAccountCreated, AccountID: "", Payload: &event.EventPayloadAccountCreated{Balance: 50}},
+			},
+			want: &event.ErrEmptyAccountID{Type: event.EventTypeAccountCreated},
+		},
+		{
+			name: "ErrInvalidPayload AccountCreated NilPayload",
+			events: []event.Event{
+				{Type: event.EventTypeAccountCreated, AccountID: "Jack"},
+			},
+			want: &event.ErrInvalidPayload{Type: event.EventTypeAccountCreated, AccountID: "Jack", Payload: "<nil>"},
+		},
+		{
+			name: "ErrInvalidPayload AccountChargeReceived WrongPayload",
+			events: []event.Event{
+				{Type: event.EventTypeAccountCreated, AccountID: "Jack", Payload: &event.EventPayloadAccountCreated{Balance: 50}},
+				{Type: event.EventTypeAccountChargeReceived, AccountID: "Jack", Payload: &event.EventPayloadAccountCreated{Balance: 50}},
+			},
+			want: &event.ErrInvalidPayload{Type: event.EventTypeAccountChargeReceived, AccountID: "Jack", Payload: "*simpleeventworker.EventPayloadAccountCreated"},
+		},
+		{
+			name: "ErrInvalidPayload AccountPaymentReceived TypedNilPayload",
+			events: []event.Event{
+				{Type: event.EventTypeAccountCreated, AccountID: "Jack", Payload: &event.EventPayloadAccountCreated{Balance: 50}},
+				{Type: event.EventTypeAccountPaymentReceived, AccountID: "Jack", Payload: (*event.EventPayloadAccountTransactionReceived)(nil)},
+			},
+			want: &event.ErrInvalidPayload{Type: event.EventTypeAccountPaymentReceived, AccountID: "Jack", Payload: "*simpleeventworker.EventPayloadAccountTransactionReceived"},
+		},
+		{
+			name: "ErrTenantMismatch",
+			events: []event.Event{
+				event.NewAccountCreatedEvent("Jack", 50),
+				event.NewChargeEvent("Jack", 25).InTenant("acme"),
+			},
+			want: &event.ErrTenantMismatch{TenantID: "", EventTenantID: "acme", AccountID: "Jack"},
+		},
 	}
 
 	for _, tt := range subtests {
@@ -327,3 +471,127 @@ func TestEvent_ProcessEvents_CustomErrors(t *testing.T) {
 		})
 	}
 }
+
+func TestEvent_Constructors(t *testing.T) {
+	subtests := []struct {
+		name string
+		got  event.Event
+		want event.Event
+	}{
+		{
+			name: "NewAccountCreatedEvent",
+			got:  event.NewAccountCreatedEvent("Jack", 50),
+			want: event.Event{Type: event.EventTypeAccountCreated, AccountID: "Jack", Payload: &event.EventPayloadAccountCreated{Balance: 50}},
+		},
+		{
+			name: "NewChargeEvent",
+			got:  event.NewChargeEvent("Jack", 25),
+			want: event.Event{Type: event.EventTypeAccountChargeReceived, AccountID: "Jack", Payload: &event.EventPayloadAccountTransactionReceived{Amount: 25}},
+		},
+		{
+			name: "NewPaymentEvent",
+			got:  event.NewPaymentEvent("Jack", 25),
+			want: event.Event{Type: event.EventTypeAccountPaymentReceived, AccountID: "Jack", Payload: &event.EventPayloadAccountTransactionReceived{Amount: 25}},
+		},
+		{
+			name: "NewRecalledEvent",
+			got:  event.NewRecalledEvent("Jack"),
+			want: event.Event{Type: event.EventTypeAccountRecalled, AccountID: "Jack", Payload: nil},
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.got)
+		})
+	}
+}
+
+func TestEvent_MarshalJSON_Success(t *testing.T) {
+	subtests := []struct {
+		name  string
+		event event.Event
+		want  string
+	}{
+		{
+			name:  "AccountCreated",
+			event: event.NewAccountCreatedEvent("Jack", 50),
+			want:  `{"Type":"AccountCreated","AccountID":"Jack","Payload":{"Balance":50}}`,
+		},
+		{
+			name:  "AccountChargeReceived",
+			event: event.NewChargeEvent("Jack", 25),
+			want:  `{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":25}}`,
+		},
+		{
+			name:  "AccountPaymentReceived",
+			event: event.NewPaymentEvent("Jack", 25),
+			want:  `{"Type":"AccountPaymentReceived","AccountID":"Jack","Payload":{"Amount":25}}`,
+		},
+		{
+			name:  "AccountRecalled",
+			event: event.NewRecalledEvent("Jack"),
+			want:  `{"Type":"AccountRecalled","AccountID":"Jack","Payload":{}}`,
+		},
+		{
+			name:  "AccountRecalled PayloadIgnored",
+			event: event.Event{Type: event.EventTypeAccountRecalled, AccountID: "Jack", Payload: &event.EventPayloadAccountTransactionReceived{Amount: 25}},
+			want:  `{"Type":"AccountRecalled","AccountID":"Jack","Payload":{}}`,
+		},
+		{
+			name:  "TenantID",
+			event: event.NewChargeEvent("Jack", 25).InTenant("acme"),
+			want:  `{"Type":"AccountChargeReceived","TenantID":"acme","AccountID":"Jack","Payload":{"Amount":25}}`,
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.event)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestEvent_MarshalJSON_CustomErrors(t *testing.T) {
+	subtests := []struct {
+		name  string
+		event event.Event
+		want  error
+	}{
+		{
+			name:  "ErrUnsupportedEventType",
+			event: event.Event{Type: "AccountCreate", AccountID: "Jack"},
+			want:  &event.ErrUnsupportedEventType{Type: "AccountCreate"},
+		},
+		{
+			name:  "ErrInvalidPayload",
+			event: event.Event{Type: event.EventTypeAccountCreated, AccountID: "Jack"},
+			want:  &event.ErrInvalidPayload{Type: event.EventTypeAccountCreated, AccountID: "Jack", Payload: "<nil>"},
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.event.MarshalJSON()
+			assert.Equal(t, tt.want, err)
+		})
+	}
+}
+
+func TestEvent_MarshalJSON_RoundTrip(t *testing.T) {
+	events := []event.Event{
+		event.NewAccountCreatedEvent("Jack", 50),
+		event.NewChargeEvent("Jack", 25),
+		event.NewPaymentEvent("Jack", 100),
+		event.NewRecalledEvent("Jack"),
+	}
+
+	data, err := json.Marshal(events)
+	assert.NoError(t, err)
+
+	got, err := event.NewService(event.WithStrictSchema(true)).ParseEvents(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, events, got)
+}
@@ -0,0 +1,970 @@
+package simpleeventworker
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// maxNestingDepth matches the nesting limit of encoding/json, so both decoders reject the same deeply nested input.
+const maxNestingDepth = 10000
+
+// payloadSlabSize is how many payloads of a type fastDecoder allocates at once.
+const payloadSlabSize = 256
+
+// WithFastDecoder makes ParseEvents decode with a tokenizer specialized for the event schema instead of encoding/json.
+// Both decoders accept and reject the same input with the same errors, apart from the messages of malformed JSON.
+// The fast decoder allocates a fraction of what encoding/json does per event. It is disabled by default.
+func WithFastDecoder(enabled bool) ServiceOption {
+	return func(s *EventService) {
+		s.fastDecoder = enabled
+	}
+}
+
+type fastDecoderState int
+
+const (
+	fastDecoderFirst fastDecoderState = iota
+	fastDecoderNext
+	fastDecoderDone
+)
+
+// fastDecoder decodes a JSON array of events the way ParseEvents does with a json.Decoder and eventDecoder,
+// without the aux struct, json.RawMessage and map of payload fields of Event.unmarshalJSON:
+//   - each array element is read into a reused buffer and scanned in place,
+//   - tenant and account IDs are interned, so events of the same account share one string,
+//   - payloads are carved out of slabs rather than allocated one by one.
+type fastDecoder struct {
+	r      *bufio.Reader
+	strict bool
+	schema *Schema
+	state  fastDecoderState
+	// offset is the number of bytes consumed from r.
+	offset int64
+
+	// element holds the current array element, starting at elementOffset of the input.
+	element       []byte
+	elementOffset int64
+	pos           int
+	// keepRaw keeps the element in raw for Raw, once wellFormed tells it is a JSON value of its own.
+	keepRaw    bool
+	wellFormed bool
+	raw        []byte
+
+	typ       []byte
+	version   int
+	tenantID  []byte
+	accountID []byte
+	key       []byte
+	unknown   []byte
+
+	interned     map[string]string
+	created      []EventPayloadAccountCreated
+	transactions []EventPayloadAccountTransactionReceived
+}
+
+func newFastDecoder(r io.Reader, strict, keepRaw bool, schema *Schema) *fastDecoder {
+	return &fastDecoder{
+		r:        bufio.NewReader(r),
+		strict:   strict,
+		schema:   schema,
+		keepRaw:  keepRaw,
+		interned: map[string]string{},
+	}
+}
+
+func (d *fastDecoder) Decode(e *Event) error {
+	d.raw = nil
+	if d.state == fastDecoderDone {
+		return io.EOF
+	}
+
+	c, err := d.peekNonSpace()
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	if c == ']' || c == '}' {
+		d.state = fastDecoderDone
+		return io.EOF
+	}
+	if d.state == fastDecoderNext {
+		if c != ',' {
+			return d.syntaxError(d.offset, fmt.Sprintf("invalid character %q after array element", c))
+		}
+		d.offset++
+		_, _ = d.r.ReadByte()
+		if _, err := d.peekNonSpace(); err != nil {
+			return unexpectedEOF(err)
+		}
+	}
+	d.state = fastDecoderNext
+
+	if err := d.readElement(); err != nil {
+		return err
+	}
+
+	d.wellFormed = false
+	err = d.decodeEvent(e)
+	if d.keepRaw && d.wellFormed {
+		d.raw = d.element
+	}
+
+	return err
+}
+
+func (d *fastDecoder) Raw() []byte {
+	return d.raw
+}
+
+func (d *fastDecoder) Start() error {
+	c, err := d.peekNonSpace()
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+
+	switch c {
+	case '[':
+		d.offset++
+		_, _ = d.r.ReadByte()
+		return nil
+	case '{':
+		return ErrInputJSONIsNotArray
+	}
+
+	// Any other JSON value is not an array either, but only a well-formed one is reported as such.
+	// Like json.Decoder.Token, whatever follows the value is not looked at.
+	if err := d.readElement(); err != nil {
+		return err
+	}
+	if err := d.skipValue(0); err != nil {
+		return err
+	}
+	// json.Decoder.Token decodes a number as a float64, which fails if it is out of range.
+	if literal := d.element[:d.pos]; c == '-' || (c >= '0' && c <= '9') {
+		if _, err := strconv.ParseFloat(string(literal), 64); err != nil {
+			return &json.UnmarshalTypeError{Value: "number " + string(literal), Type: reflect.TypeFor[float64]()}
+		}
+	}
+
+	return ErrInputJSONIsNotArray
+}
+
+// endValue marks the element as well-formed if nothing follows the value skipped at its start.
+// A literal or number is only read up to the end of its value, like json.Decoder does, which leaves whatever
+// follows it for the next element. With keepRaw, the caller goes on to the next element after an error,
+// so it fails the way json.Decoder does once it gets there.
+func (d *fastDecoder) endValue() error {
+	if d.pos < len(d.element) {
+		if d.keepRaw {
+			return d.syntaxErrorAt(fmt.Sprintf("invalid character %q after array element", d.element[d.pos]))
+		}
+		return nil
+	}
+	d.wellFormed = true
+
+	return nil
+}
+
+func (d *fastDecoder) peekNonSpace() (byte, error) {
+	for {
+		c, err := d.r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if !isSpace(c) {
+			return c, d.r.UnreadByte()
+		}
+		d.offset++
+	}
+}
+
+func (d *fastDecoder) readByte() (byte, error) {
+	c, err := d.r.ReadByte()
+	if err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	d.offset++
+	d.element = append(d.element, c)
+
+	return c, nil
+}
+
+// readElement reads the next JSON value of the input into element.
+// It only finds where the value ends. Its syntax is checked while the element is scanned.
+func (d *fastDecoder) readElement() error {
+	d.element = d.element[:0]
+	d.elementOffset = d.offset
+	d.pos = 0
+
+	c, err := d.readByte()
+	if err != nil {
+		return err
+	}
+
+	switch c {
+	case '{', '[':
+		inString := false
+		for depth := 1; depth > 0; {
+			c, err := d.readByte()
+			if err != nil {
+				return err
+			}
+			if inString {
+				switch c {
+				case '\\':
+					if _, err := d.readByte(); err != nil {
+						return err
+					}
+				case '"':
+					inString = false
+				}
+				continue
+			}
+			switch c {
+			case '"':
+				inString = true
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			}
+		}
+	case '"':
+		for {
+			c, err := d.readByte()
+			if err != nil {
+				return err
+			}
+			if c == '\\' {
+				if _, err := d.readByte(); err != nil {
+					return err
+				}
+				continue
+			}
+			if c == '"' {
+				break
+			}
+		}
+	case ',', ':', ']', '}':
+		return d.syntaxError(d.elementOffset, fmt.Sprintf("invalid character %q looking for beginning of value", c))
+	default:
+		// A number or literal ends at the first byte that cannot be part of it.
+		for {
+			c, err := d.r.ReadByte()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if isSpace(c) || strings.IndexByte(",:[]{}\"", c) >= 0 {
+				if err := d.r.UnreadByte(); err != nil {
+					return err
+				}
+				break
+			}
+			d.offset++
+			d.element = append(d.element, c)
+		}
+	}
+
+	return nil
+}
+
+func (d *fastDecoder) decodeEvent(e *Event) error {
+	switch d.element[0] {
+	case '{':
+	case 'n':
+		// Like a JSON null decoded by Event.UnmarshalJSON, which leaves the event without a type.
+		// Whatever follows the value is left for the next element, so it is not looked at.
+		if err := d.skipValue(0); err != nil {
+			return err
+		}
+		if err := d.endValue(); err != nil {
+			return err
+		}
+		return &ErrUnsupportedEventType{}
+	default:
+		if err := d.skipValue(0); err != nil {
+			return err
+		}
+		if err := d.endValue(); err != nil {
+			return err
+		}
+		return &json.UnmarshalTypeError{Value: kindOf(d.element[0]), Type: reflect.TypeFor[Event]()}
+	}
+
+	d.typ = d.typ[:0]
+	d.version = 0
+	d.tenantID = d.tenantID[:0]
+	d.accountID = d.accountID[:0]
+	hasPayload := false
+	payloadStart, payloadEnd := 0, 0
+	timestampStart, timestampEnd := 0, 0
+	effectiveAtStart, effectiveAtEnd := 0, 0
+	// A value of the wrong type does not stop the scan, so malformed JSON after it is still reported first.
+	var typeErr error
+
+	d.pos++
+	d.skipSpace()
+	if d.peek() == '}' {
+		d.pos++
+	} else {
+		for {
+			d.skipSpace()
+			if d.peek() != '"' {
+				return d.syntaxErrorAt("looking for beginning of object key string")
+			}
+			key, err := d.readString(d.key[:0])
+			if err != nil {
+				return err
+			}
+			d.key = key
+			if err := d.expectColon(); err != nil {
+				return err
+			}
+			d.skipSpace()
+
+			// Like encoding/json, envelope fields match case-insensitively and the last occurrence wins.
+			switch {
+			case bytes.EqualFold(d.key, []byte("Type")):
+				d.typ, err = d.readStringField(d.typ, "Type", &typeErr)
+			case bytes.EqualFold(d.key, []byte("Version")):
+				d.version, err = d.readIntField(d.version, "Version", &typeErr)
+			case bytes.EqualFold(d.key, []byte("TenantID")):
+				d.tenantID, err = d.readStringField(d.tenantID, "TenantID", &typeErr)
+			case bytes.EqualFold(d.key, []byte("AccountID")):
+				d.accountID, err = d.readStringField(d.accountID, "AccountID", &typeErr)
+			case bytes.EqualFold(d.key, []byte("Timestamp")):
+				timestampStart = d.pos
+				err = d.skipValue(1)
+				timestampEnd = d.pos
+			case bytes.EqualFold(d.key, []byte("EffectiveAt")):
+				effectiveAtStart = d.pos
+				err = d.skipValue(1)
+				effectiveAtEnd = d.pos
+			case bytes.EqualFold(d.key, []byte("Payload")):
+				hasPayload = true
+				payloadStart = d.pos
+				err = d.skipValue(1)
+				payloadEnd = d.pos
+			default:
+				err = d.skipValue(1)
+			}
+			if err != nil {
+				return err
+			}
+
+			d.skipSpace()
+			c := d.peek()
+			d.pos++
+			if c == '}' {
+				break
+			}
+			if c != ',' {
+				return d.syntaxError(d.elementOffset+int64(d.pos-1), "after object key:value pair")
+			}
+		}
+	}
+	if d.pos < len(d.element) {
+		return d.syntaxErrorAt(fmt.Sprintf("invalid character %q after top-level value", d.element[d.pos]))
+	}
+	d.wellFormed = true
+	if typeErr != nil {
+		return typeErr
+	}
+	timestamp, err := decodeTimestamp(d.element[timestampStart:timestampEnd])
+	if err != nil {
+		return err
+	}
+	e.Timestamp = timestamp
+	effectiveAt, err := decodeEffectiveAt(d.element[effectiveAtStart:effectiveAtEnd])
+	if err != nil {
+		return err
+	}
+	e.EffectiveAt = effectiveAt
+
+	// Only canonical event types at version 1 are scanned in place. Others are upcast with the schema.
+	if d.version != 0 && d.version != 1 {
+		return d.decodeByJSON(e, hasPayload, payloadStart, payloadEnd)
+	}
+
+	var payload EventPayload
+	switch string(d.typ) {
+	case EventTypeAccountCreated:
+		balance, _, err := d.decodePayload(hasPayload, payloadStart, payloadEnd, EventPayloadFieldBalance, "")
+		if err != nil {
+			return err
+		}
+		payload = d.newCreatedPayload(balance)
+		e.Type = EventTypeAccountCreated
+	case EventTypeAccountChargeReceived, EventTypeAccountPaymentReceived, EventTypeAccountRecoveryReceived:
+		amount, hasInvoice, err := d.decodePayload(hasPayload, payloadStart, payloadEnd, EventPayloadFieldAmount, EventPayloadFieldInvoice)
+		if err != nil {
+			return err
+		}
+		if hasInvoice {
+			return d.decodeByJSON(e, hasPayload, payloadStart, payloadEnd)
+		}
+		payload = d.newTransactionPayload(amount)
+		switch string(d.typ) {
+		case EventTypeAccountChargeReceived:
+			e.Type = EventTypeAccountChargeReceived
+		case EventTypeAccountPaymentReceived:
+			e.Type = EventTypeAccountPaymentReceived
+		default:
+			e.Type = EventTypeAccountRecoveryReceived
+		}
+	case EventTypeAccountRecalled, EventTypeAccountWrittenOff:
+		if d.strict && hasPayload {
+			if _, _, _, err := d.decodePayloadField(payloadStart, payloadEnd, "", ""); err != nil {
+				return err
+			}
+		}
+		e.Type = EventTypeAccountRecalled
+		if string(d.typ) == EventTypeAccountWrittenOff {
+			e.Type = EventTypeAccountWrittenOff
+		}
+	default:
+		return d.decodeByJSON(e, hasPayload, payloadStart, payloadEnd)
+	}
+
+	e.TenantID = d.intern(d.tenantID)
+	e.AccountID = d.intern(d.accountID)
+	e.Payload = payload
+
+	return nil
+}
+
+// decodeByJSON decodes an event that is not of a canonical event type at version 1, or whose payload references an
+// invoice, the way Event.unmarshalJSON does. Such events are rare, so their payload is decoded by encoding/json
+// rather than scanned in place.
+func (d *fastDecoder) decodeByJSON(e *Event, hasPayload bool, start, end int) error {
+	eventType := string(d.typ)
+	var data []byte
+	if hasPayload {
+		data = d.element[start:end]
+	}
+
+	upcaster, ok, err := d.schema.resolve(eventType, d.version)
+	if err != nil {
+		return err
+	}
+	if ok {
+		eventType, data = upcaster.To, upcaster.upcastPayload(data)
+	}
+
+	payload, err := decodeEventPayload(eventType, data, d.strict)
+	if err != nil {
+		return err
+	}
+
+	e.Type = eventType
+	e.TenantID = d.intern(d.tenantID)
+	e.AccountID = d.intern(d.accountID)
+	e.Payload = payload
+
+	return nil
+}
+
+// readIntField decodes an integer. A JSON null leaves `value` as it is, like encoding/json does.
+// Any other value is recorded in typeErr, if it is the first.
+func (d *fastDecoder) readIntField(value int, field string, typeErr *error) (int, error) {
+	if d.peek() != 'n' {
+		n, err := d.readInt()
+		if err != nil {
+			if *typeErr == nil {
+				if e, ok := err.(*json.UnmarshalTypeError); ok {
+					e.Field = field
+				}
+				*typeErr = err
+			}
+		} else {
+			value = n
+		}
+	}
+
+	return value, d.skipValue(1)
+}
+
+// readStringField decodes a string into dst. A JSON null leaves dst as it is, like encoding/json does.
+// Any other value is recorded in typeErr, if it is the first.
+func (d *fastDecoder) readStringField(dst []byte, field string, typeErr *error) ([]byte, error) {
+	switch d.peek() {
+	case '"':
+		return d.readString(dst[:0])
+	case 'n':
+		return dst, d.skipValue(1)
+	}
+
+	if *typeErr == nil {
+		*typeErr = &json.UnmarshalTypeError{Value: kindOf(d.peek()), Type: reflect.TypeFor[string](), Field: field}
+	}
+
+	return dst, d.skipValue(1)
+}
+
+// decodePayload decodes a payload with one required integer field, unless it has the `optional` field.
+// See decodePayloadField.
+func (d *fastDecoder) decodePayload(hasPayload bool, start, end int, known, optional string) (value int, hasOptional bool, err error) {
+	if !hasPayload {
+		return 0, false, d.syntaxError(d.elementOffset+int64(len(d.element)), "unexpected end of JSON input")
+	}
+
+	value, present, hasOptional, err := d.decodePayloadField(start, end, known, optional)
+	if err != nil || hasOptional {
+		return 0, hasOptional, err
+	}
+	if !present {
+		return 0, false, &ErrMissingFieldInEventPayloadField{Field: known}
+	}
+
+	return value, false, nil
+}
+
+// decodePayloadField is decodePayloadFields for a payload with at most one known integer field, without the map.
+// Errors are reported in the same order: the field name that sorts first is checked first.
+// A payload with the `optional` field is only scanned for it, since decodePayloadFields has to decode it instead.
+func (d *fastDecoder) decodePayloadField(start, end int, known, optional string) (value int, present, hasOptional bool, err error) {
+	d.pos = start
+	switch d.peek() {
+	case 'n':
+		return 0, false, false, nil
+	case '{':
+	default:
+		return 0, false, false, &json.UnmarshalTypeError{Value: kindOf(d.peek()), Type: reflect.TypeFor[map[string]json.RawMessage]()}
+	}
+
+	var valueErr error
+	hasUnknown := false
+
+	// The span was checked by skipValue, so only its contents are of interest here.
+	d.pos++
+	for d.pos < end {
+		d.skipSpace()
+		if d.peek() == '}' {
+			break
+		}
+		d.key, err = d.readString(d.key[:0])
+		if err != nil {
+			return 0, false, false, err
+		}
+		if err := d.expectColon(); err != nil {
+			return 0, false, false, err
+		}
+		d.skipSpace()
+
+		if known != "" && string(d.key) == known {
+			present = true
+			value, valueErr = d.readInt()
+		} else if optional != "" && string(d.key) == optional {
+			hasOptional = true
+		} else if d.strict && (!hasUnknown || bytes.Compare(d.key, d.unknown) < 0) {
+			hasUnknown = true
+			d.unknown = append(d.unknown[:0], d.key...)
+		}
+		if err := d.skipValue(1); err != nil {
+			return 0, false, false, err
+		}
+
+		d.skipSpace()
+		if d.peek() == '}' {
+			break
+		}
+		d.pos++
+	}
+
+	if hasOptional {
+		return 0, false, true, nil
+	}
+	if present && valueErr != nil && (!hasUnknown || known < string(d.unknown)) {
+		return 0, false, false, &ErrInvalidPayloadFieldValue{Field: known, Err: valueErr}
+	}
+	if hasUnknown {
+		return 0, false, false, &ErrUnknownPayloadField{Field: string(d.unknown)}
+	}
+
+	return value, present, false, nil
+}
+
+// readInt decodes the value at pos as an int, without consuming it. A JSON null is 0.
+func (d *fastDecoder) readInt() (int, error) {
+	c := d.peek()
+	if c == 'n' {
+		return 0, nil
+	}
+	if c != '-' && (c < '0' || c > '9') {
+		return 0, &json.UnmarshalTypeError{Value: kindOf(c), Type: reflect.TypeFor[int]()}
+	}
+
+	end := d.pos
+	for end < len(d.element) && strings.IndexByte("-+.eE0123456789", d.element[end]) >= 0 {
+		end++
+	}
+	literal := d.element[d.pos:end]
+
+	digits := literal
+	negative := digits[0] == '-'
+	if negative {
+		digits = digits[1:]
+	}
+	limit := uint64(1<<(strconv.IntSize-1)) - 1
+	if negative {
+		limit++
+	}
+	var n uint64
+	for _, c := range digits {
+		if c < '0' || c > '9' || n > (limit-uint64(c-'0'))/10 {
+			return 0, &json.UnmarshalTypeError{Value: "number " + string(literal), Type: reflect.TypeFor[int]()}
+		}
+		n = n*10 + uint64(c-'0')
+	}
+	if negative {
+		return int(-n), nil
+	}
+
+	return int(n), nil
+}
+
+// skipValue checks the JSON value at pos and moves past it.
+func (d *fastDecoder) skipValue(depth int) error {
+	if depth > maxNestingDepth {
+		return d.syntaxErrorAt("exceeded max depth")
+	}
+	if d.pos >= len(d.element) {
+		return d.syntaxErrorAt("unexpected end of JSON input")
+	}
+
+	switch c := d.element[d.pos]; c {
+	case '{', '[':
+		closing := byte('}')
+		if c == '[' {
+			closing = ']'
+		}
+		d.pos++
+		d.skipSpace()
+		if d.peek() == closing {
+			d.pos++
+			return nil
+		}
+		for {
+			d.skipSpace()
+			if c == '{' {
+				if d.peek() != '"' {
+					return d.syntaxErrorAt("looking for beginning of object key string")
+				}
+				if err := d.skipString(); err != nil {
+					return err
+				}
+				if err := d.expectColon(); err != nil {
+					return err
+				}
+				d.skipSpace()
+			}
+			if err := d.skipValue(depth + 1); err != nil {
+				return err
+			}
+			d.skipSpace()
+			switch d.peek() {
+			case ',':
+				d.pos++
+			case closing:
+				d.pos++
+				return nil
+			default:
+				return d.syntaxErrorAt("after value in object or array")
+			}
+		}
+	case '"':
+		return d.skipString()
+	case 't':
+		return d.skipLiteral("true")
+	case 'f':
+		return d.skipLiteral("false")
+	case 'n':
+		return d.skipLiteral("null")
+	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return d.skipNumber()
+	default:
+		return d.syntaxErrorAt(fmt.Sprintf("invalid character %q looking for beginning of value", c))
+	}
+}
+
+func (d *fastDecoder) skipLiteral(literal string) error {
+	if !bytes.HasPrefix(d.element[d.pos:], []byte(literal)) {
+		return d.syntaxErrorAt(fmt.Sprintf("invalid literal, expected %q", literal))
+	}
+	d.pos += len(literal)
+
+	return nil
+}
+
+// skipNumber moves past a number with the grammar of RFC 8259.
+func (d *fastDecoder) skipNumber() error {
+	digits := func() int {
+		start := d.pos
+		for d.pos < len(d.element) && d.element[d.pos] >= '0' && d.element[d.pos] <= '9' {
+			d.pos++
+		}
+		return d.pos - start
+	}
+
+	if d.peek() == '-' {
+		d.pos++
+	}
+	switch c := d.peek(); {
+	case c == '0':
+		d.pos++
+	case c >= '1' && c <= '9':
+		digits()
+	default:
+		return d.syntaxErrorAt("in numeric literal")
+	}
+	if d.peek() == '.' {
+		d.pos++
+		if digits() == 0 {
+			return d.syntaxErrorAt("after decimal point in numeric literal")
+		}
+	}
+	if c := d.peek(); c == 'e' || c == 'E' {
+		d.pos++
+		if c := d.peek(); c == '+' || c == '-' {
+			d.pos++
+		}
+		if digits() == 0 {
+			return d.syntaxErrorAt("in exponent of numeric literal")
+		}
+	}
+
+	return nil
+}
+
+// skipString moves past a string, checking its escapes.
+func (d *fastDecoder) skipString() error {
+	d.pos++
+	for d.pos < len(d.element) {
+		c := d.element[d.pos]
+		switch {
+		case c == '"':
+			d.pos++
+			return nil
+		case c == '\\':
+			if _, err := d.readEscape(nil); err != nil {
+				return err
+			}
+		case c < 0x20:
+			return d.syntaxErrorAt("in string literal")
+		default:
+			d.pos++
+		}
+	}
+
+	return d.syntaxErrorAt("unexpected end of JSON input")
+}
+
+// readString decodes the string at pos and appends it to dst.
+// Like encoding/json, invalid UTF-8 and unpaired surrogates are replaced with U+FFFD.
+func (d *fastDecoder) readString(dst []byte) ([]byte, error) {
+	d.pos++
+	for d.pos < len(d.element) {
+		c := d.element[d.pos]
+		switch {
+		case c == '"':
+			d.pos++
+			return dst, nil
+		case c == '\\':
+			var err error
+			if dst, err = d.readEscape(dst); err != nil {
+				return dst, err
+			}
+		case c < 0x20:
+			return dst, d.syntaxErrorAt("in string literal")
+		case c < utf8.RuneSelf:
+			dst = append(dst, c)
+			d.pos++
+		default:
+			r, size := utf8.DecodeRune(d.element[d.pos:])
+			if r == utf8.RuneError && size == 1 {
+				dst = utf8.AppendRune(dst, utf8.RuneError)
+			} else {
+				dst = append(dst, d.element[d.pos:d.pos+size]...)
+			}
+			d.pos += size
+		}
+	}
+
+	return dst, d.syntaxErrorAt("unexpected end of JSON input")
+}
+
+// readEscape decodes the escape sequence at pos and appends it to dst.
+func (d *fastDecoder) readEscape(dst []byte) ([]byte, error) {
+	if d.pos+1 >= len(d.element) {
+		return dst, d.syntaxErrorAt("unexpected end of JSON input")
+	}
+
+	c := d.element[d.pos+1]
+	switch c {
+	case '"', '\\', '/':
+		dst = append(dst, c)
+	case 'b':
+		dst = append(dst, '\b')
+	case 'f':
+		dst = append(dst, '\f')
+	case 'n':
+		dst = append(dst, '\n')
+	case 'r':
+		dst = append(dst, '\r')
+	case 't':
+		dst = append(dst, '\t')
+	case 'u':
+		r, ok := d.readHex(d.pos + 2)
+		if !ok {
+			return dst, d.syntaxErrorAt("in \\u hexadecimal character escape")
+		}
+		d.pos += 6
+		if utf16.IsSurrogate(r) {
+			// The pair is only consumed if it decodes. Otherwise the next escape is decoded on its own.
+			r2, ok := rune(0), false
+			if d.pos+1 < len(d.element) && d.element[d.pos] == '\\' && d.element[d.pos+1] == 'u' {
+				r2, ok = d.readHex(d.pos + 2)
+			}
+			if decoded := utf16.DecodeRune(r, r2); ok && decoded != utf8.RuneError {
+				r = decoded
+				d.pos += 6
+			} else {
+				r = utf8.RuneError
+			}
+		}
+		return utf8.AppendRune(dst, r), nil
+	default:
+		return dst, d.syntaxErrorAt(fmt.Sprintf("invalid escape sequence %q in string", c))
+	}
+	d.pos += 2
+
+	return dst, nil
+}
+
+func (d *fastDecoder) readHex(pos int) (rune, bool) {
+	if pos+4 > len(d.element) {
+		return 0, false
+	}
+
+	var r rune
+	for _, c := range d.element[pos : pos+4] {
+		switch {
+		case c >= '0' && c <= '9':
+			c -= '0'
+		case c >= 'a' && c <= 'f':
+			c = c - 'a' + 10
+		case c >= 'A' && c <= 'F':
+			c = c - 'A' + 10
+		default:
+			return 0, false
+		}
+		r = r<<4 | rune(c)
+	}
+
+	return r, true
+}
+
+func (d *fastDecoder) expectColon() error {
+	d.skipSpace()
+	if d.peek() != ':' {
+		return d.syntaxErrorAt("after object key")
+	}
+	d.pos++
+
+	return nil
+}
+
+func (d *fastDecoder) skipSpace() {
+	for d.pos < len(d.element) && isSpace(d.element[d.pos]) {
+		d.pos++
+	}
+}
+
+// peek returns the byte at pos, or 0 past the end of the element.
+func (d *fastDecoder) peek() byte {
+	if d.pos >= len(d.element) {
+		return 0
+	}
+
+	return d.element[d.pos]
+}
+
+func (d *fastDecoder) intern(b []byte) string {
+	if s, ok := d.interned[string(b)]; ok {
+		return s
+	}
+	s := string(b)
+	d.interned[s] = s
+
+	return s
+}
+
+func (d *fastDecoder) newCreatedPayload(balance int) *EventPayloadAccountCreated {
+	if len(d.created) == 0 {
+		d.created = make([]EventPayloadAccountCreated, payloadSlabSize)
+	}
+	p := &d.created[0]
+	d.created = d.created[1:]
+	p.Balance = balance
+
+	return p
+}
+
+func (d *fastDecoder) newTransactionPayload(amount int) *EventPayloadAccountTransactionReceived {
+	if len(d.transactions) == 0 {
+		d.transactions = make([]EventPayloadAccountTransactionReceived, payloadSlabSize)
+	}
+	p := &d.transactions[0]
+	d.transactions = d.transactions[1:]
+	p.Amount = amount
+
+	return p
+}
+
+func (d *fastDecoder) syntaxErrorAt(reason string) error {
+	return d.syntaxError(d.elementOffset+int64(d.pos), reason)
+}
+
+func (d *fastDecoder) syntaxError(offset int64, reason string) error {
+	return &ErrMalformedJSON{Offset: offset, Reason: reason}
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// kindOf names the kind of JSON value starting with c, as encoding/json does in an UnmarshalTypeError.
+func kindOf(c byte) string {
+	switch c {
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	case '"':
+		return "string"
+	case 't', 'f':
+		return "bool"
+	case 'n':
+		return "null"
+	}
+
+	return "number"
+}
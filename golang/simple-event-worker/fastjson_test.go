@@ -0,0 +1,244 @@
+package simpleeventworker_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"unsafe"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// isMalformedJSONError reports whether err is about the JSON itself rather than the events in it.
+// Only the messages of these errors differ between the decoders.
+func isMalformedJSONError(err error) bool {
+	var syntaxErr *json.SyntaxError
+	var malformedErr *event.ErrMalformedJSON
+	var typeErr *json.UnmarshalTypeError
+
+	return errors.As(err, &syntaxErr) || errors.As(err, &malformedErr) ||
+		// A value of the wrong type is malformed only if it is not in a payload field.
+		(errors.As(err, &typeErr) && reflect.TypeOf(err) == reflect.TypeOf(typeErr)) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// assertDecoderParity checks that ParseEvents returns the same events, errors and dead letters with and without
+// WithFastDecoder, both without and with a DeadLetterSink.
+func assertDecoderParity(t *testing.T, data []byte) {
+	t.Helper()
+
+	for _, strict := range []bool{false, true} {
+		for _, lenient := range []bool{false, true} {
+			wantSink, gotSink := &fakeDeadLetterSink{}, &fakeDeadLetterSink{}
+			wantOpts := []event.ServiceOption{event.WithStrictSchema(strict)}
+			gotOpts := []event.ServiceOption{event.WithStrictSchema(strict), event.WithFastDecoder(true)}
+			if lenient {
+				wantOpts = append(wantOpts, event.WithDeadLetterSink(wantSink))
+				gotOpts = append(gotOpts, event.WithDeadLetterSink(gotSink))
+			}
+			msg := fmt.Sprintf("strict: %v, lenient: %v, input: %q", strict, lenient, data)
+
+			want, wantErr := event.NewService(wantOpts...).ParseEvents(bytes.NewReader(data))
+			got, gotErr := event.NewService(gotOpts...).ParseEvents(bytes.NewReader(data))
+			assertParseParity(t, msg, want, got, wantErr, gotErr)
+
+			require.Len(t, gotSink.quarantined, len(wantSink.quarantined), msg)
+			for i := range wantSink.quarantined {
+				assertDeadLetterParity(t, msg, strict, wantSink.quarantined[i], gotSink.quarantined[i])
+			}
+		}
+	}
+}
+
+func assertParseParity(t *testing.T, msg string, want, got []event.Event, wantErr, gotErr error) {
+	t.Helper()
+
+	if wantErr == nil {
+		require.NoError(t, gotErr, msg)
+		require.Equal(t, want, got, msg)
+		return
+	}
+
+	require.Error(t, gotErr, "%s, want: %v", msg, wantErr)
+	if isMalformedJSONError(wantErr) {
+		require.True(t, isMalformedJSONError(gotErr), "%s, want: %v, got: %v", msg, wantErr, gotErr)
+		return
+	}
+	require.IsType(t, wantErr, gotErr, msg)
+	require.EqualError(t, gotErr, wantErr.Error(), msg)
+}
+
+// assertDeadLetterParity checks that both decoders quarantine the same events. Like errors, the reasons of events
+// rejected for malformed JSON may differ.
+func assertDeadLetterParity(t *testing.T, msg string, strict bool, want, got []event.DeadLetter) {
+	t.Helper()
+
+	require.Len(t, got, len(want), msg)
+	for i := range want {
+		require.Equal(t, want[i].Stage, got[i].Stage, msg)
+		require.Equal(t, want[i].Index, got[i].Index, msg)
+		require.Equal(t, want[i].Event, got[i].Event, msg)
+
+		_, err := event.NewService(event.WithStrictSchema(strict)).ParseEvents(bytes.NewReader(append(append([]byte("["), want[i].Event...), ']')))
+		if !isMalformedJSONError(err) {
+			require.Equal(t, want[i].Reason, got[i].Reason, msg)
+		}
+	}
+}
+
+func TestEvent_ParseEvents_FastDecoderParity(t *testing.T) {
+	subtests := []struct {
+		name  string
+		input string
+	}{
+		{name: "Empty", input: `[]`},
+		{name: "AllTypes", input: `[
+			{"Type":"AccountCreated","AccountID":"Jack","Payload":{"Balance":50}},
+			{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":25}},
+			{"Type":"AccountPaymentReceived","AccountID":"Jack","Payload":{"Amount":100}},
+			{"Type":"AccountRecalled","AccountID":"Jack"}
+		]`},
+		{name: "FieldOrder", input: `[{"Payload":{"Balance":50},"AccountID":"Jack","Type":"AccountCreated"}]`},
+		{name: "CaseInsensitiveFields", input: `[{"type":"AccountCreated","ACCOUNTID":"Jack","payload":{"Balance":50}}]`},
+		{name: "TenantID", input: `[{"Type":"AccountRecalled","tenantId":"acme","AccountID":"Jack"},{"Type":"AccountRecalled","TenantID":null,"AccountID":"Jack"}]`},
+		{name: "Malformed TenantIDNumber", input: `[{"Type":"AccountRecalled","TenantID":7,"AccountID":"Jack"}]`},
+		{name: "DuplicateFields", input: `[{"Type":"AccountCreate","Type":"AccountCreated","AccountID":"Jack","Payload":{"Balance":5,"Balance":50}}]`},
+		{name: "NullFields", input: `[{"Type":"AccountCreated","AccountID":"Jack","Type":null,"Payload":{"Balance":null}}]`},
+		{name: "UnknownFields", input: `[{"Type":"AccountCreated","AccountID":"Jack","Extra":[{"a":null},true,-1.5e3],"Payload":{"Balance":50,"Note":"x"}}]`},
+		{name: "Escapes", input: `[{"Type":"AccountCreated","AccountID":"J\"a\\c\/k😀\ud800\t","Payload":{"Balance":50}}]`},
+		{name: "InvalidUTF8", input: "[{\"Type\":\"AccountRecalled\",\"AccountID\":\"J\xffack\"}]"},
+		{name: "MaxInt", input: `[{"Type":"AccountCreated","AccountID":"Jack","Payload":{"Balance":9223372036854775807}}]`},
+		{name: "Whitespace", input: " \n[ {\"Type\" : \"AccountRecalled\" ,\t\"AccountID\":\"Jack\" } ]\r\n"},
+		{name: "TrailingData", input: `[{"Type":"AccountRecalled","AccountID":"Jack"}] [`},
+		{name: "ErrInputJSONIsNotArray Object", input: `{"Type":"AccountRecalled","AccountID":"Jack"}`},
+		{name: "ErrInputJSONIsNotArray String", input: `"Jack"`},
+		{name: "ErrUnsupportedEventType", input: `[{"Type":"AccountCreate","AccountID":"Jack","Payload":{"Balance":50}}]`},
+		{name: "ErrUnsupportedEventType Null", input: `[null]`},
+		{name: "ErrUnsupportedEventType MissingType", input: `[{"AccountID":"Jack"}]`},
+		{name: "ErrMissingFieldInEventPayloadField", input: `[{"Type":"AccountCreated","AccountID":"Jack","Payload":{"Amount":50}}]`},
+		{name: "ErrMissingFieldInEventPayloadField Null", input: `[{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":null}]`},
+		{name: "ErrMissingFieldInEventPayloadField CaseSensitive", input: `[{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"amount":25}}]`},
+		{name: "ErrInvalidPayloadFieldValue String", input: `[{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":"25"}}]`},
+		{name: "ErrInvalidPayloadFieldValue Float", input: `[{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":2.5}}]`},
+		{name: "ErrInvalidPayloadFieldValue Overflow", input: `[{"Type":"AccountCreated","AccountID":"Jack","Payload":{"Balance":9223372036854775808}}]`},
+		{name: "ErrInvalidPayloadFieldValue BeforeUnknownField", input: `[{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":true,"Note":1}}]`},
+		{name: "ErrUnknownPayloadField BeforeInvalidValue", input: `[{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":true,"Amount2":1,"A":1}}]`},
+		{name: "ErrUnknownPayloadField AccountRecalled", input: `[{"Type":"AccountRecalled","AccountID":"Jack","Payload":{"Reason":"fraud"}}]`},
+		{name: "ErrInvalidAmount", input: `[{"Type":"AccountPaymentReceived","AccountID":"Jack","Payload":{"Amount":-25}}]`},
+		{name: "ErrEmptyAccountID", input: `[{"Type":"AccountRecalled","AccountID":""}]`},
+		{name: "Malformed MissingPayload", input: `[{"Type":"AccountCreated","AccountID":"Jack"}]`},
+		{name: "Malformed AccountIDNumber", input: `[{"Type":"AccountRecalled","AccountID":7}]`},
+		{name: "Malformed EventNumber", input: `[7]`},
+		{name: "Malformed PayloadArray", input: `[{"Type":"AccountCreated","AccountID":"Jack","Payload":[50]}]`},
+		{name: "Malformed Unterminated", input: `[{"Type":"AccountRecalled","AccountID":"Jack"}`},
+		{name: "Malformed TrailingComma", input: `[{"Type":"AccountRecalled","AccountID":"Jack"},]`},
+		{name: "Malformed MissingComma", input: `[{"Type":"AccountRecalled","AccountID":"Jack"} {}]`},
+		{name: "Malformed Number", input: `[{"Type":"AccountRecalled","AccountID":"Jack","Extra":01}]`},
+		{name: "Malformed Escape", input: `[{"Type":"AccountRecalled","AccountID":"J\x"}]`},
+		{name: "Malformed Empty", input: ``},
+		{name: "Quarantined", input: `[{"Type":"AccountCreate","AccountID":"Jack"},{"Type":"AccountRecalled","AccountID":"Jack"},7,null,"x",{"AccountID":7},[1]]`},
+		{name: "Malformed AfterQuarantined", input: `[{"Type":"AccountCreate","AccountID":"Jack"},{"Type":]`},
+		{name: "Malformed LiteralSuffix", input: `[null0]`},
+		{name: "Version", input: `[{"Type":"AccountCreated","Version":1,"AccountID":"Jack","Payload":{"Balance":50}},{"Type":"AccountRecalled","version":null,"AccountID":"Jack"}]`},
+		{name: "ChargePosted", input: `[{"Type":"ChargePosted","Version":2,"AccountID":"Jack","Payload":{"Reference":"inv-1","Amount":25}},{"Version":2,"Type":"ChargePosted","AccountID":"Jack","Payload":{"Amount":5,"Note":"x"}}]`},
+		{name: "ChargePosted NullPayload", input: `[{"Type":"ChargePosted","Version":2,"AccountID":"Jack","Payload":null}]`},
+		{name: "ChargePosted MissingPayload", input: `[{"Type":"ChargePosted","Version":2,"AccountID":"Jack"}]`},
+		{name: "ChargePosted ErrEmptyAccountID", input: `[{"Type":"ChargePosted","Version":2,"AccountID":"","Payload":{"Amount":25}}]`},
+		{name: "ErrUnsupportedEventVersion", input: `[{"Type":"AccountCreated","Version":2,"AccountID":"Jack","Payload":{"Balance":50}},{"Type":"ChargePosted","AccountID":"Jack","Payload":{"Amount":25}},{"Type":"AccountRecalled","Version":-1,"AccountID":"Jack"}]`},
+		{name: "ErrUnsupportedEventType Versioned", input: `[{"Type":"AccountCreate","Version":3,"AccountID":"Jack","Payload":{"Balance":50}}]`},
+		{name: "Malformed VersionString", input: `[{"Type":"AccountCreated","Version":"2","AccountID":"Jack","Payload":{"Balance":50}}]`},
+		{name: "Malformed VersionFloat", input: `[{"Type":"AccountRecalled","Version":1.5,"AccountID":"Jack"}]`},
+		{name: "Timestamp", input: `[{"Type":"AccountRecalled","AccountID":"Jack","Timestamp":"2026-03-01T09:30:00+08:00"},{"timestamp":null,"Type":"AccountRecalled","AccountID":"Jack"}]`},
+		{name: "Timestamp Upcast", input: `[{"Type":"ChargePosted","Version":2,"Timestamp":"2026-03-01T00:00:00Z","AccountID":"Jack","Payload":{"Amount":25}}]`},
+		{name: "ErrInvalidTimestamp", input: `[{"Type":"AccountRecalled","AccountID":"Jack","Timestamp":"2026-03-01"}]`},
+		{name: "ErrInvalidTimestamp Number", input: `[{"Type":"AccountCreated","AccountID":"Jack","Timestamp":1,"Payload":{"Balance":"50"}}]`},
+		{name: "EffectiveAt", input: `[{"Type":"AccountRecalled","AccountID":"Jack","EffectiveAt":"2026-04-01T00:00:00+08:00"},{"effectiveat":null,"Type":"AccountRecalled","AccountID":"Jack"}]`},
+		{name: "EffectiveAt Upcast", input: `[{"Type":"ChargePosted","Version":2,"EffectiveAt":"2026-04-01T00:00:00Z","AccountID":"Jack","Payload":{"Amount":25}}]`},
+		{name: "ErrInvalidEffectiveAt", input: `[{"Type":"AccountRecalled","AccountID":"Jack","Timestamp":"2026-03-01T00:00:00Z","EffectiveAt":"2026-04-01"}]`},
+		{name: "ErrInvalidTimestamp BeforeEffectiveAt", input: `[{"Type":"AccountRecalled","AccountID":"Jack","EffectiveAt":true,"Timestamp":1}]`},
+		{name: "Invoice", input: `[{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Invoice":"INV-1","Amount":25}},{"Type":"AccountPaymentReceived","AccountID":"Jack","Payload":{"Amount":25,"Invoice":null}}]`},
+		{name: "Invoice ChargePosted", input: `[{"Type":"ChargePosted","Version":2,"AccountID":"Jack","Payload":{"Reference":"inv-1","Amount":25}}]`},
+		{name: "Invoice Escaped", input: `[{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":25,"Invo\u0069ce":"INV-1"}}]`},
+		{name: "ErrInvalidPayloadFieldValue Invoice", input: `[{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":25,"Invoice":1}}]`},
+		{name: "ErrInvalidPayloadFieldValue AmountWithInvoice", input: `[{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":"25","Invoice":"INV-1"}}]`},
+		{name: "ErrUnknownPayloadField WithInvoice", input: `[{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":25,"Invoice":"INV-1","Due":"2026-04-01"}}]`},
+		{name: "ErrMissingFieldInEventPayloadField WithInvoice", input: `[{"Type":"AccountPaymentReceived","AccountID":"Jack","Payload":{"Invoice":"INV-1"}}]`},
+		{name: "WriteOff", input: `[{"Type":"AccountWrittenOff","AccountID":"Jack"},{"Type":"AccountRecoveryReceived","AccountID":"Jack","Payload":{"Amount":25}},{"Type":"AccountRecoveryReceived","AccountID":"Jack","Payload":{"Amount":5,"Invoice":"INV-1"}}]`},
+		{name: "ErrUnknownPayloadField WrittenOff", input: `[{"Type":"AccountWrittenOff","AccountID":"Jack","Payload":{"Amount":25}}]`},
+		{name: "ErrInvalidAmount Recovery", input: `[{"Type":"AccountRecoveryReceived","AccountID":"Jack","Payload":{"Amount":-25}}]`},
+		{name: "ErrUnknownPayloadField InvoiceOnAccountCreated", input: `[{"Type":"AccountCreated","AccountID":"Jack","Payload":{"Balance":50,"Invoice":"INV-1"}}]`},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertDecoderParity(t, []byte(tt.input))
+		})
+	}
+}
+
+func TestEvent_ParseEvents_FastDecoderInternsAccountIDs(t *testing.T) {
+	s := event.NewService(event.WithFastDecoder(true))
+
+	events, err := s.ParseEvents(strings.NewReader(`[
+		{"Type":"AccountCreated","AccountID":"Jack","Payload":{"Balance":50}},
+		{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":25}}
+	]`))
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+
+	assert.Equal(t, "Jack", events[1].AccountID)
+	assert.Same(t, unsafe.StringData(events[0].AccountID), unsafe.StringData(events[1].AccountID))
+	// Payloads from the same slab must still be distinct.
+	assert.NotSame(t, events[0].Payload, events[1].Payload)
+}
+
+func benchmarkParseEvents(b *testing.B, opts ...event.ServiceOption) {
+	const size = 10000
+
+	events := make([]event.Event, 0, size)
+	for i := range size / 4 {
+		id := fmt.Sprintf("Account-%d", i)
+		events = append(events,
+			event.NewAccountCreatedEvent(id, 100),
+			event.NewChargeEvent(id, 30),
+			event.NewPaymentEvent(id, 20),
+			event.NewRecalledEvent(id),
+		)
+	}
+	data, err := json.Marshal(events)
+	if err != nil {
+		b.Fatal(err)
+	}
+	s := event.NewService(opts...)
+
+	parse := func() {
+		if _, err := s.ParseEvents(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+	allocs := testing.AllocsPerRun(1, parse)
+
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for range b.N {
+		parse()
+	}
+	b.ReportMetric(allocs/size, "allocs/event")
+}
+
+func BenchmarkParseEvents_JSONDecoder(b *testing.B) {
+	benchmarkParseEvents(b)
+}
+
+func BenchmarkParseEvents_FastDecoder(b *testing.B) {
+	benchmarkParseEvents(b, event.WithFastDecoder(true))
+}
@@ -0,0 +1,191 @@
+package simpleeventworker_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+	"unicode/utf8"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+)
+
+var fuzzSeeds = []string{
+	`{"Type":"AccountCreated","AccountID":"Jack","Payload":{"Balance":50}}`,
+	`{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":25}}`,
+	`{"Type":"AccountPaymentReceived","AccountID":"Jack","Payload":{"Amount":25}}`,
+	`{"Type":"AccountRecalled","AccountID":"Jack","Payload":{}}`,
+	`{"Type":"AccountRecalled","AccountID":"Jack"}`,
+	`{"Type":"AccountCreated","AccountID":"Jack","Payload":null}`,
+	`{"Type":"AccountCreated","AccountID":"Jack"}`,
+	`{"Type":"AccountCreated","AccountID":"Jack","Payload":{"Balance":"50"}}`,
+	`{"Type":"AccountCreated","AccountID":"Jack","Payload":[50]}`,
+	`{"Type":"AccountCreated","AccountID":"","Payload":{"Balance":-50}}`,
+	`{"Type":"AccountCreated","AccountID":"Jack","Payload":{"Balance":1e3}}`,
+	`{"Type":"AccountCreated","AccountID":"Jack","Payload":{"Balance":99999999999999999999}}`,
+	`{"Type":"AccountWrittenOff","AccountID":"Jack"}`,
+	`{"Type":"AccountRecoveryReceived","AccountID":"Jack","Payload":{"Amount":25}}`,
+	`{"Type":7}`,
+	`null`,
+}
+
+// checkAccounts fails the test if the status of a processed account contradicts its balance.
+func checkAccounts(t *testing.T, accounts map[string]event.Account) {
+	t.Helper()
+
+	for id, account := range accounts {
+		if id != account.ID {
+			t.Fatalf("account %q stored under %q", account.ID, id)
+		}
+		if !account.IsRecalled() && !account.IsWrittenOff() && account.Status() != statusOf(account.Balance()) {
+			t.Fatalf("account %q has status %q with balance %d", id, account.Status(), account.Balance())
+		}
+		if account.IsWrittenOff() && (account.Balance() > 0 || account.Recovered() > account.WrittenOff()) {
+			t.Fatalf("written-off account %q has balance %d, and recovered %d of %d", id, account.Balance(), account.Recovered(), account.WrittenOff())
+		}
+		if account.Balance() != openItemsBalance(account) {
+			t.Fatalf("account %q has balance %d, but open items and credit of %d", id, account.Balance(), openItemsBalance(account))
+		}
+	}
+}
+
+func FuzzEvent_UnmarshalJSON(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var e event.Event
+		if err := json.Unmarshal(data, &e); err != nil {
+			return
+		}
+
+		// A decoded event always carries the payload type of its event type.
+		switch e.Type {
+		case event.EventTypeAccountCreated:
+			if _, ok := e.Payload.(*event.EventPayloadAccountCreated); !ok {
+				t.Fatalf("%s decoded with payload %T", e.Type, e.Payload)
+			}
+		case event.EventTypeAccountChargeReceived, event.EventTypeAccountPaymentReceived, event.EventTypeAccountRecoveryReceived:
+			if _, ok := e.Payload.(*event.EventPayloadAccountTransactionReceived); !ok {
+				t.Fatalf("%s decoded with payload %T", e.Type, e.Payload)
+			}
+		case event.EventTypeAccountRecalled, event.EventTypeAccountWrittenOff:
+			if e.Payload != nil {
+				t.Fatalf("%s decoded with payload %T", e.Type, e.Payload)
+			}
+		default:
+			t.Fatalf("unsupported event type %q decoded without error", e.Type)
+		}
+
+		// Processing a decoded event may fail, e.g. on validation, but must not panic.
+		accounts, err := event.NewService().ProcessEvents([]event.Event{e})
+		if err == nil {
+			checkAccounts(t, accounts)
+		}
+	})
+}
+
+func FuzzParseEvents(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add([]byte("[" + seed + "]"))
+	}
+	f.Add([]byte(`[` + fuzzSeeds[0] + `,` + fuzzSeeds[1] + `,` + fuzzSeeds[3] + `,` + fuzzSeeds[2] + `]`))
+	f.Add([]byte(`[]`))
+	f.Add([]byte(`{}`))
+	if data, err := os.ReadFile("events.json"); err == nil {
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, strict := range []bool{false, true} {
+			s := event.NewService(event.WithStrictSchema(strict))
+
+			events, err := s.ParseEvents(bytes.NewReader(data))
+			if err != nil {
+				continue
+			}
+
+			accounts, err := s.ProcessEvents(events)
+			if err == nil {
+				checkAccounts(t, accounts)
+			}
+		}
+	})
+}
+
+// FuzzParseEvents_FastDecoder checks that the fast decoder accepts and rejects the same input as encoding/json.
+func FuzzParseEvents_FastDecoder(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add([]byte("[" + seed + "]"))
+	}
+	f.Add([]byte(`[` + fuzzSeeds[0] + `, ` + fuzzSeeds[1] + `,` + "\n" + fuzzSeeds[3] + `]`))
+	f.Add([]byte(`[{"type":"AccountRecalled","accountid":"J\u00e9ck\ud83d\ude00","Extra":[1,{"a":"b"}]}]`))
+	f.Add([]byte(`[{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":true,"A":1}}]`))
+	f.Add([]byte(`[{"Type":"ChargePosted","Version":2,"AccountID":"Jack","Payload":{"Reference":"x","Amount":25}}]`))
+	f.Add([]byte(`[{"Type":"AccountPaymentReceived","AccountID":"Jack","Timestamp":"2026-03-01T00:00:00Z","Payload":{"Invoice":"x","Amount":25}}]`))
+	f.Add([]byte(`[{"Type":"AccountChargeReceived","AccountID":"Jack","EffectiveAt":"2026-04-01T00:00:00Z","Payload":{"Amount":25}}]`))
+	f.Add([]byte(`[]`))
+	f.Add([]byte(`{}`))
+	if data, err := os.ReadFile("events.json"); err == nil {
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		assertDecoderParity(t, data)
+	})
+}
+
+// FuzzProcessEvents_HandBuilt builds events the way producers using this package could, without the JSON decoder
+// guaranteeing their payload matches their type.
+func FuzzProcessEvents_HandBuilt(f *testing.F) {
+	f.Add(event.EventTypeAccountCreated, "Jack", uint8(0), 50)
+	f.Add(event.EventTypeAccountCreated, "Jack", uint8(1), 50)
+	f.Add(event.EventTypeAccountChargeReceived, "Jack", uint8(2), 25)
+	f.Add(event.EventTypeAccountPaymentReceived, "Jack", uint8(3), -25)
+	f.Add(event.EventTypeAccountRecalled, "", uint8(4), 0)
+	f.Add("AccountCreate", "Jack", uint8(0), 0)
+
+	f.Fuzz(func(t *testing.T, eventType, accountID string, payloadKind uint8, amount int) {
+		var payload event.EventPayload
+		switch payloadKind % 5 {
+		case 1:
+			payload = &event.EventPayloadAccountCreated{Balance: amount}
+		case 2:
+			payload = &event.EventPayloadAccountTransactionReceived{Amount: amount}
+		case 3:
+			payload = (*event.EventPayloadAccountCreated)(nil)
+		case 4:
+			payload = (*event.EventPayloadAccountTransactionReceived)(nil)
+		}
+
+		// The account exists beforehand, so charges, payments and recalls reach their payload checks.
+		events := []event.Event{
+			event.NewAccountCreatedEvent("Jack", 50),
+			{Type: eventType, AccountID: accountID, Payload: payload},
+		}
+
+		accounts, err := event.NewService().ProcessEvents(events)
+		if err == nil {
+			checkAccounts(t, accounts)
+		}
+
+		// An event that marshals successfully must decode back to itself.
+		data, err := json.Marshal(events[1])
+		if err != nil {
+			return
+		}
+		var decoded event.Event
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("cannot decode marshaled event %s: %v", data, err)
+		}
+		// JSON replaces invalid UTF-8 in strings, and a recall drops its payload, so neither round trips exactly.
+		if !utf8.ValidString(accountID) || eventType == event.EventTypeAccountRecalled {
+			return
+		}
+		if !reflect.DeepEqual(events[1], decoded) {
+			t.Fatalf("round trip changed %+v into %+v", events[1], decoded)
+		}
+	})
+}
@@ -0,0 +1,144 @@
+package simpleeventworker
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+)
+
+// HashChain is a tamper-evident record of the events applied by a service, for audits. The head of each event is
+// SHA-256(head of the previous event || canonical encoding of the event), starting from 32 zero bytes, so changing,
+// inserting or removing any event changes the head of every event after it.
+//
+// The canonical encoding of an event is its Event.MarshalJSON, i.e. after upcasting, so the chain does not depend on
+// the decoder or the input format. An event that cannot be encoded, e.g. of an unsupported type, cannot be chained.
+type HashChain struct {
+	heads [][sha256.Size]byte
+}
+
+// ChainLink is the head of the chain after an event, as written by WriteHashChain.
+type ChainLink struct {
+	Index int    `json:"Index"`
+	Head  string `json:"Head"`
+}
+
+func NewHashChain() *HashChain {
+	return &HashChain{}
+}
+
+// WithHashChain appends the events of every batch applied successfully to `chain`, including the events quarantined
+// to a DeadLetterSink, since they were fed to the service as well. A failing batch leaves the chain untouched,
+// like the accounts, and so does a batch of ProcessSource until its source committed it. A batch with an event that
+// cannot be chained fails with ErrUnchainableEvent. The chain is not safe for concurrent use, so every tenant needs
+// its own.
+func WithHashChain(chain *HashChain) ServiceOption {
+	return func(s *EventService) {
+		s.hashChain = chain
+	}
+}
+
+// Append appends events to the chain, e.g. to compute the chain of an input again. If an event cannot be encoded, it
+// fails with ErrUnchainableEvent and leaves the chain as it was.
+func (c *HashChain) Append(events ...Event) error {
+	heads, err := c.next(events)
+	if err != nil {
+		return err
+	}
+	c.heads = append(c.heads, heads...)
+
+	return nil
+}
+
+// next returns the heads of `events` appended to the chain, without appending them.
+func (c *HashChain) next(events []Event) ([][sha256.Size]byte, error) {
+	heads := make([][sha256.Size]byte, 0, len(events))
+	head := c.head()
+	for index, event := range events {
+		data, err := event.MarshalJSON()
+		if err != nil {
+			return nil, &ErrUnchainableEvent{Index: index, Err: err}
+		}
+
+		hash := sha256.New()
+		hash.Write(head[:])
+		hash.Write(data)
+		head = [sha256.Size]byte(hash.Sum(nil))
+		heads = append(heads, head)
+	}
+
+	return heads, nil
+}
+
+// Len returns the number of events in the chain.
+func (c *HashChain) Len() int {
+	return len(c.heads)
+}
+
+// Head returns the hex-encoded head of the last event, or 32 zero bytes if the chain is empty.
+func (c *HashChain) Head() string {
+	head := c.head()
+	return hex.EncodeToString(head[:])
+}
+
+func (c *HashChain) head() [sha256.Size]byte {
+	if len(c.heads) == 0 {
+		return [sha256.Size]byte{}
+	}
+
+	return c.heads[len(c.heads)-1]
+}
+
+// Diverges returns the index of the first event whose head differs between both chains, and whether there is one.
+// If one chain is a prefix of the other, they diverge at the first event missing from the shorter one.
+func (c *HashChain) Diverges(other *HashChain) (int, bool) {
+	for i := range min(len(c.heads), len(other.heads)) {
+		if c.heads[i] != other.heads[i] {
+			return i, true
+		}
+	}
+	if len(c.heads) != len(other.heads) {
+		return min(len(c.heads), len(other.heads)), true
+	}
+
+	return 0, false
+}
+
+// WriteHashChain writes the head after each event of the chain to w as NDJSON, one ChainLink per line.
+func WriteHashChain(w io.Writer, c *HashChain) error {
+	bw := bufio.NewWriter(w)
+	encoder := json.NewEncoder(bw)
+	for i, head := range c.heads {
+		if err := encoder.Encode(ChainLink{Index: i, Head: hex.EncodeToString(head[:])}); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// ReadHashChain reads a chain written by WriteHashChain. The links must be in order, from index 0.
+func ReadHashChain(r io.Reader) (*HashChain, error) {
+	c := NewHashChain()
+	decoder := json.NewDecoder(r)
+	for index := 0; ; index++ {
+		var link ChainLink
+		err := decoder.Decode(&link)
+		if err == io.EOF {
+			return c, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if link.Index != index {
+			return nil, &ErrInvalidChainLink{Index: index, Reason: "out of order"}
+		}
+		head, err := hex.DecodeString(link.Head)
+		if err != nil || len(head) != sha256.Size {
+			return nil, &ErrInvalidChainLink{Index: index, Reason: "head is not a hex-encoded SHA-256 hash"}
+		}
+		c.heads = append(c.heads, [sha256.Size]byte(head))
+	}
+}
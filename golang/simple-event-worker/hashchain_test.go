@@ -0,0 +1,205 @@
+package simpleeventworker_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func chainOf(events ...event.Event) *event.HashChain {
+	chain := event.NewHashChain()
+	_ = chain.Append(events...)
+
+	return chain
+}
+
+func TestHashChain_Append(t *testing.T) {
+	chain := event.NewHashChain()
+	assert.Equal(t, 0, chain.Len())
+	assert.Equal(t, strings.Repeat("0", 64), chain.Head())
+
+	require.NoError(t, chain.Append(event.NewAccountCreatedEvent("Jack", 50)))
+	want := sha256.Sum256(append(make([]byte, sha256.Size), `{"Type":"AccountCreated","AccountID":"Jack","Payload":{"Balance":50}}`...))
+	assert.Equal(t, 1, chain.Len())
+	assert.Equal(t, hex.EncodeToString(want[:]), chain.Head())
+
+	require.NoError(t, chain.Append(event.NewRecalledEvent("Jack")))
+	want = sha256.Sum256(append(want[:], `{"Type":"AccountRecalled","AccountID":"Jack","Payload":{}}`...))
+	assert.Equal(t, hex.EncodeToString(want[:]), chain.Head())
+
+	// Events without a canonical encoding cannot be chained, and leave the chain as it was.
+	head := chain.Head()
+	err := chain.Append(event.NewChargeEvent("Jack", 5), event.Event{Type: "AccountCreate", AccountID: "Jack"})
+	assert.Equal(t, &event.ErrUnchainableEvent{Index: 1, Err: &event.ErrUnsupportedEventType{Type: "AccountCreate"}}, err)
+	assert.Equal(t, 2, chain.Len())
+	assert.Equal(t, head, chain.Head())
+}
+
+func TestHashChain_Diverges(t *testing.T) {
+	events := []event.Event{
+		event.NewAccountCreatedEvent("Jack", 50),
+		event.NewChargeEvent("Jack", 25),
+		event.NewPaymentEvent("Jack", 10),
+	}
+
+	subtests := []struct {
+		name      string
+		other     *event.HashChain
+		wantIndex int
+		wantOk    bool
+	}{
+		{name: "Same", other: chainOf(events...)},
+		{name: "Changed", other: chainOf(events[0], event.NewChargeEvent("Jack", 26), events[2]), wantIndex: 1, wantOk: true},
+		{name: "Reordered", other: chainOf(events[0], events[2], events[1]), wantIndex: 1, wantOk: true},
+		{name: "Inserted", other: chainOf(event.NewAccountCreatedEvent("Jen", 0), events[0], events[1], events[2]), wantIndex: 0, wantOk: true},
+		{name: "Removed", other: chainOf(events[0], events[2]), wantIndex: 1, wantOk: true},
+		{name: "Truncated", other: chainOf(events[:2]...), wantIndex: 2, wantOk: true},
+		{name: "Appended", other: chainOf(append(events, event.NewRecalledEvent("Jack"))...), wantIndex: 3, wantOk: true},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			index, ok := chainOf(events...).Diverges(tt.other)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.wantIndex, index)
+		})
+	}
+}
+
+func TestHashChain_WithHashChain(t *testing.T) {
+	sink := &fakeDeadLetterSink{}
+	chain := event.NewHashChain()
+	s := event.NewService(event.WithHashChain(chain), event.WithDeadLetterSink(sink))
+
+	batch := []event.Event{
+		event.NewAccountCreatedEvent("Jack", 50),
+		event.NewChargeEvent("Jen", 25),
+		event.NewChargeEvent("Jack", 25),
+	}
+	_, err := s.ProcessEvents(batch)
+	require.NoError(t, err)
+	require.Len(t, sink.quarantined, 1)
+
+	// Quarantined events were fed to the service as well, so they are chained.
+	assert.Equal(t, chainOf(batch...).Head(), chain.Head())
+
+	// A failing batch leaves the chain untouched.
+	s = event.NewService(event.WithHashChain(chain))
+	_, err = s.ProcessEvents([]event.Event{event.NewChargeEvent("Jen", 25)})
+	assert.Error(t, err)
+	assert.Equal(t, 3, chain.Len())
+}
+
+func TestHashChain_ProcessSource(t *testing.T) {
+	batches := [][]event.Event{
+		{event.NewAccountCreatedEvent("Jack", 50), event.NewChargeEvent("Jack", 25)},
+		{event.NewPaymentEvent("Jack", 75)},
+	}
+	chain := event.NewHashChain()
+	s := event.NewService(event.WithHashChain(chain))
+
+	_, err := s.ProcessSource(context.Background(), nil, &fakeSource{batches: batches})
+	require.NoError(t, err)
+
+	// The chain rolls over batches.
+	_, ok := chain.Diverges(chainOf(append(batches[0], batches[1]...)...))
+	assert.False(t, ok)
+}
+
+func TestHashChain_ProcessSource_CommitFailure(t *testing.T) {
+	batch := []event.Event{event.NewAccountCreatedEvent("Jack", 50), event.NewChargeEvent("Jack", 25)}
+	chain := event.NewHashChain()
+	s := event.NewService(event.WithHashChain(chain))
+
+	// A batch that is not committed is delivered again, so it is not chained yet.
+	_, err := s.ProcessSource(context.Background(), nil, &fakeSource{batches: [][]event.Event{batch}, commitErr: errors.New("broker unavailable")})
+	assert.EqualError(t, err, "broker unavailable")
+	assert.Equal(t, 0, chain.Len())
+
+	// Once it is, it is chained once.
+	_, err = s.ProcessSource(context.Background(), nil, &fakeSource{batches: [][]event.Event{batch}})
+	require.NoError(t, err)
+	_, ok := chain.Diverges(chainOf(batch...))
+	assert.False(t, ok)
+	assert.Equal(t, 2, chain.Len())
+}
+
+func TestHashChain_CanonicalEncoding(t *testing.T) {
+	// Both generations of charges, and both decoders, produce the same chain.
+	inputs := []string{
+		`[{"Type":"AccountCreated","AccountID":"Jack","Payload":{"Balance":50}},{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":25}}]`,
+		`[{"AccountID":"Jack","Type":"AccountCreated","Payload":{"Balance":50,"Note":"x"}},{"Type":"ChargePosted","Version":2,"AccountID":"Jack","Payload":{"Amount":25}}]`,
+	}
+	want := chainOf(event.NewAccountCreatedEvent("Jack", 50), event.NewChargeEvent("Jack", 25))
+
+	for _, input := range inputs {
+		for _, fast := range []bool{false, true} {
+			chain := event.NewHashChain()
+			s := event.NewService(event.WithHashChain(chain), event.WithFastDecoder(fast))
+
+			events, err := s.ParseEvents(strings.NewReader(input))
+			require.NoError(t, err)
+			_, err = s.ProcessEvents(events)
+			require.NoError(t, err)
+			assert.Equal(t, want.Head(), chain.Head(), "fast: %v, input: %s", fast, input)
+		}
+	}
+}
+
+func TestHashChain_WriteHashChain(t *testing.T) {
+	chain := chainOf(event.NewAccountCreatedEvent("Jack", 50), event.NewRecalledEvent("Jack"))
+
+	var buf bytes.Buffer
+	require.NoError(t, event.WriteHashChain(&buf, chain))
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+	assert.JSONEq(t, `{"Index":1,"Head":"`+chain.Head()+`"}`, lines[1])
+
+	got, err := event.ReadHashChain(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, chain, got)
+}
+
+func TestHashChain_ReadHashChain_CustomErrors(t *testing.T) {
+	head := strings.Repeat("ab", 32)
+
+	subtests := []struct {
+		name  string
+		input string
+		want  error
+	}{
+		{
+			name:  "OutOfOrder",
+			input: `{"Index":0,"Head":"` + head + `"}` + "\n" + `{"Index":2,"Head":"` + head + `"}`,
+			want:  &event.ErrInvalidChainLink{Index: 1, Reason: "out of order"},
+		},
+		{
+			name:  "NotHex",
+			input: `{"Index":0,"Head":"xyz"}`,
+			want:  &event.ErrInvalidChainLink{Index: 0, Reason: "head is not a hex-encoded SHA-256 hash"},
+		},
+		{
+			name:  "Short",
+			input: `{"Index":0,"Head":"abab"}`,
+			want:  &event.ErrInvalidChainLink{Index: 0, Reason: "head is not a hex-encoded SHA-256 hash"},
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := event.ReadHashChain(strings.NewReader(tt.input))
+			assert.Equal(t, tt.want, err)
+		})
+	}
+
+	_, err := event.ReadHashChain(strings.NewReader(`{"Index":`))
+	assert.Error(t, err)
+}
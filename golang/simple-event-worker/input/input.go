@@ -0,0 +1,368 @@
+// Package input reads inputs of events split across several files, e.g. the parts of an archive, possibly compressed.
+//
+// The parts of an input are named by paths or glob patterns, and read in the order they are given. The files matching
+// a pattern are read in natural order, so "part-2.json" comes before "part-10.json". A part is decompressed according
+// to its extension: ".gz" (gzip), ".bz2" (bzip2) or ".zlib" (zlib). A part named ".ndjson" or ".jsonl" holds one event
+// per line rather than a JSON array, and is read as a JSON array, so EventService.ParseEvents reads every part.
+package input
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/tenant"
+)
+
+const (
+	CompressionNone  = ""
+	CompressionGzip  = "gzip"
+	CompressionBzip2 = "bzip2"
+	CompressionZlib  = "zlib"
+)
+
+// compressions maps the extensions of compressed parts to their compression.
+var compressions = map[string]string{
+	".gz":   CompressionGzip,
+	".bz2":  CompressionBzip2,
+	".zlib": CompressionZlib,
+}
+
+// unsupportedCompressions are the extensions of compressions without a decompressor in the standard library.
+var unsupportedCompressions = []string{".zst", ".xz", ".lz4", ".br", ".snappy"}
+
+type ErrNoParts struct {
+	Pattern string
+}
+
+func (e *ErrNoParts) Error() string {
+	return fmt.Sprintf(`no part of the input matches "%s"`, e.Pattern)
+}
+
+type ErrUnsupportedCompression struct {
+	Path string
+}
+
+func (e *ErrUnsupportedCompression) Error() string {
+	return fmt.Sprintf(`unsupported compression of part "%s": only gzip, bzip2 and zlib are supported`, e.Path)
+}
+
+type ErrPartFailed struct {
+	Path string
+	Err  error
+}
+
+func (e *ErrPartFailed) Error() string {
+	return fmt.Sprintf(`part "%s": %s`, e.Path, e.Err)
+}
+
+func (e *ErrPartFailed) Unwrap() error {
+	return e.Err
+}
+
+// ErrPartsOutOfOrder reports an event of an account in a part before the part with its AccountCreated event,
+// e.g. because the parts are named or given out of order.
+type ErrPartsOutOfOrder struct {
+	Path     string
+	Index    int
+	TenantID string
+	// AccountID is the account of the event, which is created in CreatedIn.
+	AccountID string
+	CreatedIn string
+}
+
+func (e *ErrPartsOutOfOrder) Error() string {
+	return fmt.Sprintf(`event %d of part "%s" for account "%s" comes before its AccountCreated event in part "%s"`,
+		e.Index, e.Path, tenant.AccountKey(e.TenantID, e.AccountID), e.CreatedIn)
+}
+
+// ErrOverlappingParts reports an AccountCreated event of an account created in an earlier part,
+// e.g. because two parts hold the same events.
+type ErrOverlappingParts struct {
+	Path      string
+	Index     int
+	TenantID  string
+	AccountID string
+	CreatedIn string
+}
+
+func (e *ErrOverlappingParts) Error() string {
+	return fmt.Sprintf(`AccountCreated event %d of part "%s" for account "%s" was already in part "%s"`,
+		e.Index, e.Path, tenant.AccountKey(e.TenantID, e.AccountID), e.CreatedIn)
+}
+
+// Part is a file holding some of the events of an input.
+type Part struct {
+	Path string
+	// Compression is one of the Compression constants, CompressionNone if the part is not compressed.
+	Compression string
+	// NDJSON is whether the part holds one event per line rather than a JSON array.
+	NDJSON bool
+}
+
+// NewPart describes the part at `path` by its extensions, e.g. "events.ndjson.gz" is gzip-compressed NDJSON.
+func NewPart(path string) (Part, error) {
+	p := Part{Path: path}
+
+	name := strings.ToLower(filepath.Base(path))
+	ext := filepath.Ext(name)
+	if compression, ok := compressions[ext]; ok {
+		p.Compression = compression
+		name = strings.TrimSuffix(name, ext)
+	} else if slices.Contains(unsupportedCompressions, ext) {
+		return Part{}, &ErrUnsupportedCompression{Path: path}
+	}
+
+	switch filepath.Ext(name) {
+	case ".ndjson", ".jsonl":
+		p.NDJSON = true
+	}
+
+	return p, nil
+}
+
+// Resolve returns the parts named by `patterns`, in order. Every pattern must match at least one file.
+// A file matching several patterns is only read once, in the place of the first.
+func Resolve(patterns ...string) ([]Part, error) {
+	parts := []Part{}
+	seen := map[string]bool{}
+
+	for _, pattern := range patterns {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(paths) == 0 {
+			return nil, &ErrNoParts{Pattern: pattern}
+		}
+		slices.SortFunc(paths, compareNatural)
+
+		for _, path := range paths {
+			if seen[filepath.Clean(path)] {
+				continue
+			}
+			seen[filepath.Clean(path)] = true
+
+			part, err := NewPart(path)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, part)
+		}
+	}
+
+	return parts, nil
+}
+
+// Open opens the part, decompressed, as a JSON array of events.
+func (p Part) Open() (io.ReadCloser, error) {
+	file, err := os.Open(p.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var r io.Reader = file
+	switch p.Compression {
+	case CompressionGzip:
+		r, err = gzip.NewReader(file)
+	case CompressionBzip2:
+		r = bzip2.NewReader(file)
+	case CompressionZlib:
+		r, err = zlib.NewReader(file)
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if p.NDJSON {
+		r = &ndjsonReader{decoder: json.NewDecoder(r)}
+	}
+
+	return &partReader{Reader: r, file: file}, nil
+}
+
+// partReader closes the file of a part, along with its decompressor, if any.
+type partReader struct {
+	io.Reader
+	file *os.File
+}
+
+func (r *partReader) Close() error {
+	if closer, ok := r.Reader.(io.Closer); ok {
+		closer.Close()
+	}
+
+	return r.file.Close()
+}
+
+// ndjsonReader reads a stream of JSON values as a JSON array of them. Each value is checked to be well-formed,
+// so a line cannot end the array early or hold several elements of it.
+type ndjsonReader struct {
+	decoder *json.Decoder
+	buf     bytes.Buffer
+	started bool
+	done    bool
+}
+
+func (r *ndjsonReader) Read(p []byte) (int, error) {
+	for r.buf.Len() == 0 && !r.done {
+		if err := r.next(); err != nil {
+			return 0, err
+		}
+	}
+	if r.buf.Len() == 0 {
+		return 0, io.EOF
+	}
+
+	return r.buf.Read(p)
+}
+
+// next buffers the next element of the array, or its end.
+func (r *ndjsonReader) next() error {
+	var value json.RawMessage
+	err := r.decoder.Decode(&value)
+	if err == io.EOF {
+		if !r.started {
+			r.buf.WriteByte('[')
+		}
+		r.buf.WriteByte(']')
+		r.done = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if r.started {
+		r.buf.WriteByte(',')
+	} else {
+		r.buf.WriteByte('[')
+		r.started = true
+	}
+	r.buf.Write(value)
+
+	return nil
+}
+
+// Read reads the events of every part with `parse`, e.g. EventService.ParseEvents, and returns them in order.
+// It fails with ErrPartsOutOfOrder or ErrOverlappingParts if the parts break the order ProcessEvents expects.
+// Events out of order within a part are left to ProcessEvents.
+func Read(parts []Part, parse func(io.Reader) ([]event.Event, error)) ([]event.Event, error) {
+	events := []event.Event{}
+	// bounds holds the index in `events` of the first event of each part, and the number of events after the last.
+	bounds := make([]int, 0, len(parts)+1)
+
+	for _, part := range parts {
+		bounds = append(bounds, len(events))
+
+		partEvents, err := readPart(part, parse)
+		if err != nil {
+			return nil, &ErrPartFailed{Path: part.Path, Err: err}
+		}
+		events = append(events, partEvents...)
+	}
+	bounds = append(bounds, len(events))
+
+	if err := checkOrder(parts, bounds, events); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func readPart(part Part, parse func(io.Reader) ([]event.Event, error)) ([]event.Event, error) {
+	r, err := part.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return parse(r)
+}
+
+type accountKey struct {
+	tenantID  string
+	accountID string
+}
+
+// checkOrder checks that no event of an account is in a part before the one creating it, and that no account is
+// created in several parts. The parts of events are given by `bounds`, as built by Read.
+func checkOrder(parts []Part, bounds []int, events []event.Event) error {
+	created := map[accountKey]int{}
+	for i := range parts {
+		for index, e := range events[bounds[i]:bounds[i+1]] {
+			if e.Type != event.EventTypeAccountCreated {
+				continue
+			}
+
+			key := accountKey{tenantID: e.TenantID, accountID: e.AccountID}
+			first, ok := created[key]
+			if !ok {
+				created[key] = i
+				continue
+			}
+			if first != i {
+				return &ErrOverlappingParts{Path: parts[i].Path, Index: index, TenantID: e.TenantID, AccountID: e.AccountID, CreatedIn: parts[first].Path}
+			}
+		}
+	}
+
+	for i := range parts {
+		for index, e := range events[bounds[i]:bounds[i+1]] {
+			key := accountKey{tenantID: e.TenantID, accountID: e.AccountID}
+			if first, ok := created[key]; ok && first > i {
+				return &ErrPartsOutOfOrder{Path: parts[i].Path, Index: index, TenantID: e.TenantID, AccountID: e.AccountID, CreatedIn: parts[first].Path}
+			}
+		}
+	}
+
+	return nil
+}
+
+// compareNatural compares paths as strings, except that runs of digits are compared as numbers.
+func compareNatural(a, b string) int {
+	for a != "" && b != "" {
+		da, db := leadingDigits(a), leadingDigits(b)
+		if da == "" || db == "" {
+			if a[0] != b[0] {
+				return int(a[0]) - int(b[0])
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+
+		// Leading zeros do not change the number, but tell runs apart, e.g. "01" and "1".
+		na, nb := strings.TrimLeft(da, "0"), strings.TrimLeft(db, "0")
+		if len(na) != len(nb) {
+			return len(na) - len(nb)
+		}
+		if c := strings.Compare(na, nb); c != 0 {
+			return c
+		}
+		if len(da) != len(db) {
+			return len(da) - len(db)
+		}
+		a, b = a[len(da):], b[len(db):]
+	}
+
+	return len(a) - len(b)
+}
+
+func leadingDigits(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+
+	return s[:i]
+}
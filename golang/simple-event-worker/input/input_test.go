@@ -0,0 +1,223 @@
+package input_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/input"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writePart writes `data` to the file `name` in `dir`, compressed according to its extension.
+func writePart(t *testing.T, dir, name, data string) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch filepath.Ext(name) {
+	case ".gz":
+		w = gzip.NewWriter(&buf)
+	case ".zlib":
+		w = zlib.NewWriter(&buf)
+	default:
+		buf.WriteString(data)
+	}
+	if w != nil {
+		_, err := w.Write([]byte(data))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+	}
+
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+
+	return path
+}
+
+func paths(parts []input.Part) []string {
+	paths := []string{}
+	for _, p := range parts {
+		paths = append(paths, filepath.Base(p.Path))
+	}
+
+	return paths
+}
+
+func TestNewPart(t *testing.T) {
+	subtests := []struct {
+		path string
+		want input.Part
+	}{
+		{path: "events.json", want: input.Part{Path: "events.json"}},
+		{path: "events.json.gz", want: input.Part{Path: "events.json.gz", Compression: input.CompressionGzip}},
+		{path: "dir/events.NDJSON.GZ", want: input.Part{Path: "dir/events.NDJSON.GZ", Compression: input.CompressionGzip, NDJSON: true}},
+		{path: "events.jsonl.bz2", want: input.Part{Path: "events.jsonl.bz2", Compression: input.CompressionBzip2, NDJSON: true}},
+		{path: "events.ndjson.zlib", want: input.Part{Path: "events.ndjson.zlib", Compression: input.CompressionZlib, NDJSON: true}},
+		{path: "events.ndjson", want: input.Part{Path: "events.ndjson", NDJSON: true}},
+		{path: "events", want: input.Part{Path: "events"}},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := input.NewPart(tt.path)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := input.NewPart("events.ndjson.zst")
+	assert.Equal(t, &input.ErrUnsupportedCompression{Path: "events.ndjson.zst"}, err)
+}
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"part-10.json", "part-2.json", "part-1.json.gz", "part-02.json", "other.ndjson"} {
+		writePart(t, dir, name, `[]`)
+	}
+
+	parts, err := input.Resolve(filepath.Join(dir, "part-*"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"part-1.json.gz", "part-2.json", "part-02.json", "part-10.json"}, paths(parts))
+
+	// Patterns are read in the order they are given, and a part matched twice is read once.
+	parts, err = input.Resolve(filepath.Join(dir, "other.ndjson"), filepath.Join(dir, "part-2.json"), filepath.Join(dir, "*.json"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"other.ndjson", "part-2.json", "part-02.json", "part-10.json"}, paths(parts))
+}
+
+func TestResolve_CustomErrors(t *testing.T) {
+	dir := t.TempDir()
+	writePart(t, dir, "part-1.json.zst", `[]`)
+
+	_, err := input.Resolve(filepath.Join(dir, "part-2.json"))
+	assert.Equal(t, &input.ErrNoParts{Pattern: filepath.Join(dir, "part-2.json")}, err)
+
+	_, err = input.Resolve(filepath.Join(dir, "part-*"))
+	assert.Equal(t, &input.ErrUnsupportedCompression{Path: filepath.Join(dir, "part-1.json.zst")}, err)
+
+	_, err = input.Resolve("[")
+	assert.Error(t, err)
+}
+
+func TestRead(t *testing.T) {
+	dir := t.TempDir()
+	writePart(t, dir, "part-1.json.gz", `[{"Type":"AccountCreated","AccountID":"Jack","Payload":{"Balance":50}}]`)
+	writePart(t, dir, "part-2.ndjson.zlib", "\n"+`{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":25}}`+"\n\n"+
+		`{"Type":"AccountCreated","TenantID":"acme","AccountID":"Jack","Payload":{"Balance":5}}`+"\n")
+	writePart(t, dir, "part-2b.ndjson", ``)
+	parts, err := input.Resolve(filepath.Join(dir, "part-*"), "testdata/part-3.ndjson.bz2")
+	require.NoError(t, err)
+
+	for _, fast := range []bool{false, true} {
+		s := event.NewService(event.WithFastDecoder(fast))
+
+		events, err := input.Read(parts, s.ParseEvents)
+		require.NoError(t, err, "fast: %v", fast)
+		assert.Equal(t, []event.Event{
+			event.NewAccountCreatedEvent("Jack", 50),
+			event.NewChargeEvent("Jack", 25),
+			event.NewAccountCreatedEvent("Jack", 5).InTenant("acme"),
+			event.NewPaymentEvent("Jack", 10),
+			event.NewRecalledEvent("Jack"),
+		}, events, "fast: %v", fast)
+	}
+}
+
+func TestRead_CustomErrors(t *testing.T) {
+	created := `{"Type":"AccountCreated","AccountID":"Jack","Payload":{"Balance":50}}`
+	charged := `{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":25}}`
+
+	subtests := []struct {
+		name  string
+		parts map[string]string
+		// want builds the error from the directory of the parts.
+		want func(dir string) error
+	}{
+		{
+			name:  "ErrPartsOutOfOrder",
+			parts: map[string]string{"part-1.json": `[` + charged + `]`, "part-2.json": `[` + created + `]`},
+			want: func(dir string) error {
+				return &input.ErrPartsOutOfOrder{Path: filepath.Join(dir, "part-1.json"), Index: 0, AccountID: "Jack", CreatedIn: filepath.Join(dir, "part-2.json")}
+			},
+		},
+		{
+			name:  "ErrOverlappingParts",
+			parts: map[string]string{"part-1.json": `[` + created + `,` + charged + `]`, "part-2.json": `[` + charged + `,` + created + `]`},
+			want: func(dir string) error {
+				return &input.ErrOverlappingParts{Path: filepath.Join(dir, "part-2.json"), Index: 1, AccountID: "Jack", CreatedIn: filepath.Join(dir, "part-1.json")}
+			},
+		},
+		{
+			name:  "ErrPartFailed",
+			parts: map[string]string{"part-1.json": `[` + created + `]`, "part-2.json": `[{"Type":"AccountCreate","AccountID":"Jen"}]`},
+			want: func(dir string) error {
+				return &input.ErrPartFailed{Path: filepath.Join(dir, "part-2.json"), Err: &event.ErrUnsupportedEventType{Type: "AccountCreate"}}
+			},
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, data := range tt.parts {
+				writePart(t, dir, name, data)
+			}
+			parts, err := input.Resolve(filepath.Join(dir, "*"))
+			require.NoError(t, err)
+
+			_, err = input.Read(parts, event.NewService().ParseEvents)
+			assert.Equal(t, tt.want(dir), err)
+		})
+	}
+}
+
+func TestRead_OrderWithinPartsAndTenants(t *testing.T) {
+	dir := t.TempDir()
+	// Events out of order within a part, and accounts of the same ID in other tenants, are left to ProcessEvents.
+	writePart(t, dir, "part-1.json", `[
+		{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":25}},
+		{"Type":"AccountCreated","AccountID":"Jack","Payload":{"Balance":50}},
+		{"Type":"AccountChargeReceived","TenantID":"acme","AccountID":"Jen","Payload":{"Amount":25}}
+	]`)
+	writePart(t, dir, "part-2.json", `[{"Type":"AccountCreated","TenantID":"beta","AccountID":"Jen","Payload":{"Balance":50}}]`)
+	parts, err := input.Resolve(filepath.Join(dir, "*"))
+	require.NoError(t, err)
+
+	events, err := input.Read(parts, event.NewService().ParseEvents)
+	require.NoError(t, err)
+	assert.Len(t, events, 4)
+}
+
+func TestRead_Malformed(t *testing.T) {
+	dir := t.TempDir()
+	subtests := map[string]string{
+		"unterminated.ndjson": `{"Type":"AccountRecalled","AccountID":"Jack"` + "\n",
+		"array.ndjson":        `{"Type":"AccountRecalled","AccountID":"Jack"}]` + "\n" + `[{"Type":"AccountRecalled","AccountID":"Jen"}`,
+		"truncated.json.gz":   "",
+	}
+
+	for name, data := range subtests {
+		t.Run(name, func(t *testing.T) {
+			path := writePart(t, dir, name, data)
+			if strings.HasSuffix(name, ".gz") {
+				require.NoError(t, os.WriteFile(path, []byte{0x1f, 0x8b, 0x08}, 0o644))
+			}
+			parts, err := input.Resolve(path)
+			require.NoError(t, err)
+
+			for _, fast := range []bool{false, true} {
+				_, err = input.Read(parts, event.NewService(event.WithFastDecoder(fast)).ParseEvents)
+				var partErr *input.ErrPartFailed
+				assert.ErrorAs(t, err, &partErr, "fast: %v", fast)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package simpleeventworker
+
+import "time"
+
+// Instrumentation receives measurements from an EventService as it parses and processes events.
+// Implementations must be cheap, since they are called once per event.
+type Instrumentation interface {
+	// EventParsed is called for each event read by ParseEvents.
+	// If parsing failed, `event` holds whatever was decoded before the error.
+	EventParsed(event Event, err error)
+	// EventProcessed is called for each event folded by ProcessEvents, including the one that fails the batch.
+	EventProcessed(event Event, duration time.Duration, err error)
+	// AccountsProcessed is called with the final state of the accounts once a batch is processed successfully.
+	AccountsProcessed(accounts map[string]Account)
+}
+
+// WithInstrumentation sets the Instrumentation notified by the service. By default, measurements are discarded.
+func WithInstrumentation(instrumentation Instrumentation) ServiceOption {
+	return func(s *EventService) {
+		if instrumentation == nil {
+			instrumentation = noopInstrumentation{}
+		}
+		s.instrumentation = instrumentation
+	}
+}
+
+type noopInstrumentation struct{}
+
+func (noopInstrumentation) EventParsed(Event, error) {}
+
+func (noopInstrumentation) EventProcessed(Event, time.Duration, error) {}
+
+func (noopInstrumentation) AccountsProcessed(map[string]Account) {}
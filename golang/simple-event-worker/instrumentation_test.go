@@ -0,0 +1,104 @@
+package simpleeventworker_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeInstrumentation struct {
+	parsed    []error
+	processed []error
+	accounts  []map[string]event.Account
+}
+
+func (f *fakeInstrumentation) EventParsed(_ event.Event, err error) {
+	f.parsed = append(f.parsed, err)
+}
+
+func (f *fakeInstrumentation) EventProcessed(_ event.Event, duration time.Duration, err error) {
+	f.processed = append(f.processed, err)
+}
+
+func (f *fakeInstrumentation) AccountsProcessed(accounts map[string]event.Account) {
+	f.accounts = append(f.accounts, accounts)
+}
+
+func TestInstrumentation_ParseEvents(t *testing.T) {
+	subtests := []struct {
+		name  string
+		input string
+		want  []error
+	}{
+		{
+			name: "Success",
+			input: `[
+				{"Type":"AccountCreated","AccountID":"Jack","Payload":{"Balance":50}},
+				{"Type":"AccountRecalled","AccountID":"Jack","Payload":{}}
+			]`,
+			want: []error{nil, nil},
+		},
+		{
+			name: "StopsAtFirstError",
+			input: `[
+				{"Type":"AccountCreated","AccountID":"Jack","Payload":{"Balance":50}},
+				{"Type":"AccountCreate","AccountID":"Jack","Payload":{"Balance":50}},
+				{"Type":"AccountRecalled","AccountID":"Jack","Payload":{}}
+			]`,
+			want: []error{nil, &event.ErrUnsupportedEventType{Type: "AccountCreate"}},
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeInstrumentation{}
+			s := event.NewService(event.WithInstrumentation(fake))
+
+			_, _ = s.ParseEvents(strings.NewReader(tt.input))
+			assert.Equal(t, tt.want, fake.parsed)
+		})
+	}
+}
+
+func TestInstrumentation_ProcessEvents(t *testing.T) {
+	subtests := []struct {
+		name         string
+		events       []event.Event
+		wantErrors   []error
+		wantAccounts int
+	}{
+		{
+			name: "Success",
+			events: []event.Event{
+				{Type: event.EventTypeAccountCreated, AccountID: "Jack", Payload: &event.EventPayloadAccountCreated{Balance: 50}},
+				{Type: event.EventTypeAccountChargeReceived, AccountID: "Jack", Payload: &event.EventPayloadAccountTransactionReceived{Amount: 25}},
+			},
+			wantErrors:   []error{nil, nil},
+			wantAccounts: 1,
+		},
+		{
+			name: "StopsAtFirstError",
+			events: []event.Event{
+				{Type: event.EventTypeAccountCreated, AccountID: "Jack", Payload: &event.EventPayloadAccountCreated{Balance: 50}},
+				{Type: event.EventTypeAccountChargeReceived, AccountID: "Jen", Payload: &event.EventPayloadAccountTransactionReceived{Amount: 25}},
+				{Type: event.EventTypeAccountChargeReceived, AccountID: "Jack", Payload: &event.EventPayloadAccountTransactionReceived{Amount: 25}},
+			},
+			wantErrors:   []error{nil, &event.ErrAccountDoesNotExist{AccountID: "Jen"}},
+			wantAccounts: 0,
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeInstrumentation{}
+			s := event.NewService(event.WithInstrumentation(fake))
+
+			_, _ = s.ProcessEvents(tt.events)
+			assert.Equal(t, tt.wantErrors, fake.processed)
+			assert.Len(t, fake.accounts, tt.wantAccounts)
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package simpleeventworker
+
+import (
+	"slices"
+	"time"
+)
+
+// OpenItem is a charge of an account that is not fully paid yet.
+type OpenItem struct {
+	// Invoice is the reference of the invoice the charge bills, empty if the charge did not reference one.
+	Invoice   string
+	Amount    int
+	Remaining int
+	// ChargedAt is the Timestamp of the charge, the zero time if it did not have one.
+	ChargedAt time.Time
+}
+
+// allocate pays up to `amount` of the item, and returns what is left of it.
+func (item *OpenItem) allocate(amount int) int {
+	paid := min(item.Remaining, amount)
+	item.Remaining -= paid
+
+	return amount - paid
+}
+
+// Aging sums the remaining amounts of the open items of an account by the number of days since they were charged.
+type Aging struct {
+	Days0To30  int
+	Days31To60 int
+	Days61To90 int
+	Over90Days int
+	// Undated is the remaining amount of the items charged without a Timestamp, which cannot be aged.
+	Undated int
+}
+
+// RecordCharge records a charge of the account as an open item billing `invoice`, which may be empty.
+// The credit of the account, if any, pays the charge first. Like RecordTransaction, it fails for a recalled or
+// written-off account.
+func (a *Account) RecordCharge(invoice string, amount int, chargedAt time.Time) error {
+	if a.IsRecalled() || a.IsWrittenOff() {
+		return &ErrCannotTransactWithRecalledAccount{AccountID: a.ID}
+	}
+	if amount < 0 {
+		return &ErrInvalidAmount{AccountID: a.ID, Field: EventPayloadFieldAmount, Amount: amount}
+	}
+
+	a.balance += amount
+	item := OpenItem{Invoice: invoice, Amount: amount, Remaining: amount, ChargedAt: chargedAt}
+	a.credit = item.allocate(a.credit)
+	if item.Remaining > 0 {
+		// Copies of the account, e.g. in the state given to ApplyEvents, share the items, so they are never modified in place.
+		a.items = append(slices.Clip(a.items), item)
+	}
+	a.updateStatus()
+
+	return nil
+}
+
+// RecordPayment allocates a payment to the open items of the account: first to the ones billing `invoice`, if not
+// empty, then to the oldest ones. What is left once every item is paid is kept as credit for the next charges.
+// A payment referencing an invoice without open items is allocated like one without a reference.
+func (a *Account) RecordPayment(invoice string, amount int) error {
+	if a.IsRecalled() || a.IsWrittenOff() {
+		return &ErrCannotTransactWithRecalledAccount{AccountID: a.ID}
+	}
+	if amount < 0 {
+		return &ErrInvalidAmount{AccountID: a.ID, Field: EventPayloadFieldAmount, Amount: amount}
+	}
+
+	a.balance -= amount
+	items := slices.Clone(a.items)
+	if invoice != "" {
+		for i := range items {
+			if items[i].Invoice == invoice {
+				amount = items[i].allocate(amount)
+			}
+		}
+	}
+	for i := range items {
+		amount = items[i].allocate(amount)
+	}
+	items = slices.DeleteFunc(items, func(item OpenItem) bool {
+		return item.Remaining == 0
+	})
+	if len(items) == 0 {
+		items = nil
+	}
+	a.items = items
+	a.credit += amount
+	a.updateStatus()
+
+	return nil
+}
+
+// OpenItems returns the open items of the account, oldest first. The opening balance of an account is an open item
+// without an invoice.
+func (a *Account) OpenItems() []OpenItem {
+	return slices.Clone(a.items)
+}
+
+// Credit returns the payments of the account not allocated to any open item. An account has either open items or credit.
+func (a *Account) Credit() int {
+	return a.credit
+}
+
+// Aging buckets the remaining amounts of the open items of the account by their age at `asOf`: 0-30, 31-60, 61-90 and
+// over 90 days. Items charged after `asOf` are 0 days old.
+func (a *Account) Aging(asOf time.Time) Aging {
+	aging := Aging{}
+	for _, item := range a.items {
+		if item.ChargedAt.IsZero() {
+			aging.Undated += item.Remaining
+			continue
+		}
+
+		switch days := asOf.Sub(item.ChargedAt) / (24 * time.Hour); {
+		case days <= 30:
+			aging.Days0To30 += item.Remaining
+		case days <= 60:
+			aging.Days31To60 += item.Remaining
+		case days <= 90:
+			aging.Days61To90 += item.Remaining
+		default:
+			aging.Over90Days += item.Remaining
+		}
+	}
+
+	return aging
+}
+
+// ForInvoice returns a copy of a charge, payment or recovery event referencing an invoice,
+// e.g. `NewPaymentEvent("Jack", 25).ForInvoice("INV-1")`. Other events are returned as they are.
+func (e Event) ForInvoice(invoice string) Event {
+	if payload, ok := e.Payload.(*EventPayloadAccountTransactionReceived); ok && payload != nil {
+		e.Payload = &EventPayloadAccountTransactionReceived{Amount: payload.Amount, Invoice: invoice}
+	}
+
+	return e
+}
+
+// At returns a copy of the event with a Timestamp, e.g. `NewChargeEvent("Jack", 25).At(invoicedAt)`.
+func (e Event) At(timestamp time.Time) Event {
+	e.Timestamp = timestamp
+	return e
+}
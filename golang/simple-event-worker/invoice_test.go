@@ -0,0 +1,225 @@
+package simpleeventworker_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	march1 = time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	april1 = time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// openItemsBalance is what the balance of an account must always be.
+func openItemsBalance(account event.Account) int {
+	balance := -account.Credit()
+	for _, item := range account.OpenItems() {
+		balance += item.Remaining
+	}
+
+	return balance
+}
+
+func TestInvoice_Allocation(t *testing.T) {
+	subtests := []struct {
+		name       string
+		events     []event.Event
+		want       []event.OpenItem
+		wantCredit int
+	}{
+		{
+			name: "OpeningBalance",
+			events: []event.Event{
+				event.NewAccountCreatedEvent("Jack", 50).At(march1),
+			},
+			want: []event.OpenItem{{Amount: 50, Remaining: 50, ChargedAt: march1}},
+		},
+		{
+			name: "FIFO",
+			events: []event.Event{
+				event.NewAccountCreatedEvent("Jack", 0),
+				event.NewChargeEvent("Jack", 25).ForInvoice("INV-1"),
+				event.NewChargeEvent("Jack", 40).ForInvoice("INV-2"),
+				event.NewPaymentEvent("Jack", 30),
+			},
+			want: []event.OpenItem{{Invoice: "INV-2", Amount: 40, Remaining: 35}},
+		},
+		{
+			name: "InvoiceReference",
+			events: []event.Event{
+				event.NewAccountCreatedEvent("Jack", 0),
+				event.NewChargeEvent("Jack", 25).ForInvoice("INV-1"),
+				event.NewChargeEvent("Jack", 40).ForInvoice("INV-2"),
+				event.NewPaymentEvent("Jack", 30).ForInvoice("INV-2"),
+			},
+			want: []event.OpenItem{{Invoice: "INV-1", Amount: 25, Remaining: 25}, {Invoice: "INV-2", Amount: 40, Remaining: 10}},
+		},
+		{
+			name: "InvoiceReference Excess",
+			events: []event.Event{
+				event.NewAccountCreatedEvent("Jack", 0),
+				event.NewChargeEvent("Jack", 25).ForInvoice("INV-1"),
+				event.NewChargeEvent("Jack", 40).ForInvoice("INV-2"),
+				event.NewPaymentEvent("Jack", 50).ForInvoice("INV-2"),
+			},
+			want: []event.OpenItem{{Invoice: "INV-1", Amount: 25, Remaining: 15}},
+		},
+		{
+			name: "InvoiceReference Unknown",
+			events: []event.Event{
+				event.NewAccountCreatedEvent("Jack", 0),
+				event.NewChargeEvent("Jack", 25).ForInvoice("INV-1"),
+				event.NewPaymentEvent("Jack", 10).ForInvoice("INV-9"),
+			},
+			want: []event.OpenItem{{Invoice: "INV-1", Amount: 25, Remaining: 15}},
+		},
+		{
+			name: "Credit",
+			events: []event.Event{
+				event.NewAccountCreatedEvent("Jack", 10),
+				event.NewPaymentEvent("Jack", 30),
+			},
+			wantCredit: 20,
+		},
+		{
+			name: "CreditPaysNextCharge",
+			events: []event.Event{
+				event.NewAccountCreatedEvent("Jack", 10),
+				event.NewPaymentEvent("Jack", 30),
+				event.NewChargeEvent("Jack", 25).ForInvoice("INV-1").At(april1),
+			},
+			want: []event.OpenItem{{Invoice: "INV-1", Amount: 25, Remaining: 5, ChargedAt: april1}},
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			accounts, err := event.NewService().ProcessEvents(tt.events)
+			require.NoError(t, err)
+
+			account := accounts["Jack"]
+			assert.Equal(t, tt.want, account.OpenItems())
+			assert.Equal(t, tt.wantCredit, account.Credit())
+			assert.Equal(t, openItemsBalance(account), account.Balance())
+		})
+	}
+}
+
+func TestInvoice_ApplyEvents_LeavesInitialOpenItems(t *testing.T) {
+	s := event.NewService()
+	initial, err := s.ProcessEvents([]event.Event{
+		event.NewAccountCreatedEvent("Jack", 50),
+		event.NewChargeEvent("Jack", 25),
+	})
+	require.NoError(t, err)
+	jack := initial["Jack"]
+	want := jack.OpenItems()
+
+	_, err = s.ApplyEvents(initial, []event.Event{event.NewPaymentEvent("Jack", 60), event.NewChargeEvent("Jack", 5)})
+	require.NoError(t, err)
+	jack = initial["Jack"]
+	assert.Equal(t, want, jack.OpenItems())
+}
+
+func TestInvoice_Aging(t *testing.T) {
+	asOf := time.Date(2026, time.June, 30, 12, 0, 0, 0, time.UTC)
+
+	accounts, err := event.NewService().ProcessEvents([]event.Event{
+		event.NewAccountCreatedEvent("Jack", 5),
+		event.NewChargeEvent("Jack", 10).At(asOf.AddDate(0, 0, -91)),
+		event.NewChargeEvent("Jack", 20).At(asOf.AddDate(0, 0, -90)),
+		event.NewChargeEvent("Jack", 40).At(asOf.AddDate(0, 0, -31)),
+		event.NewChargeEvent("Jack", 80).At(asOf.AddDate(0, 0, -30)),
+		event.NewChargeEvent("Jack", 160).At(asOf.AddDate(0, 0, 1)),
+		// The payment settles the undated opening balance and part of the oldest charge.
+		event.NewPaymentEvent("Jack", 8),
+	})
+	require.NoError(t, err)
+
+	jack := accounts["Jack"]
+	assert.Equal(t, event.Aging{Days0To30: 240, Days31To60: 40, Days61To90: 20, Over90Days: 7}, jack.Aging(asOf))
+
+	// Undated charges cannot be aged.
+	jen := event.NewAccount("Jen", 50)
+	assert.Equal(t, event.Aging{Undated: 50}, jen.Aging(asOf))
+}
+
+func TestInvoice_RecordCharge_CustomErrors(t *testing.T) {
+	account := event.NewAccount("Jack", 50)
+	assert.Equal(t, &event.ErrInvalidAmount{AccountID: "Jack", Field: event.EventPayloadFieldAmount, Amount: -5}, account.RecordCharge("INV-1", -5, march1))
+	assert.Equal(t, &event.ErrInvalidAmount{AccountID: "Jack", Field: event.EventPayloadFieldAmount, Amount: -5}, account.RecordPayment("INV-1", -5))
+
+	account.Recall()
+	assert.Equal(t, &event.ErrCannotTransactWithRecalledAccount{AccountID: "Jack"}, account.RecordCharge("INV-1", 5, march1))
+	assert.Equal(t, &event.ErrCannotTransactWithRecalledAccount{AccountID: "Jack"}, account.RecordPayment("INV-1", 5))
+	assert.Equal(t, 50, account.Balance())
+}
+
+func TestInvoice_ParseEvents(t *testing.T) {
+	input := `[
+		{"Type":"AccountCreated","AccountID":"Jack","Timestamp":"2026-03-01T00:00:00Z","Payload":{"Balance":50}},
+		{"Type":"AccountChargeReceived","AccountID":"Jack","Timestamp":"2026-04-01T00:00:00Z","Payload":{"Amount":25,"Invoice":"INV-1"}},
+		{"Type":"AccountPaymentReceived","AccountID":"Jack","Timestamp":null,"Payload":{"Invoice":"INV-1","Amount":10}}
+	]`
+	want := []event.Event{
+		event.NewAccountCreatedEvent("Jack", 50).At(march1),
+		event.NewChargeEvent("Jack", 25).ForInvoice("INV-1").At(april1),
+		event.NewPaymentEvent("Jack", 10).ForInvoice("INV-1"),
+	}
+
+	for _, fast := range []bool{false, true} {
+		for _, strict := range []bool{false, true} {
+			events, err := event.NewService(event.WithFastDecoder(fast), event.WithStrictSchema(strict)).ParseEvents(strings.NewReader(input))
+			require.NoError(t, err, "fast: %v, strict: %v", fast, strict)
+			assert.Equal(t, want, events, "fast: %v, strict: %v", fast, strict)
+		}
+	}
+
+	// The canonical encoding only has a Timestamp and an Invoice if the event does.
+	data, err := json.Marshal(want[1])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"Type":"AccountChargeReceived","AccountID":"Jack","Timestamp":"2026-04-01T00:00:00Z","Payload":{"Amount":25,"Invoice":"INV-1"}}`, string(data))
+	data, err = json.Marshal(event.NewChargeEvent("Jack", 25))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":25}}`, string(data))
+}
+
+func TestInvoice_ParseEvents_CustomErrors(t *testing.T) {
+	subtests := []struct {
+		name  string
+		input string
+		// wantField is the payload field rejected with ErrInvalidPayloadFieldValue, or empty for ErrInvalidTimestamp.
+		wantField string
+	}{
+		{name: "ErrInvalidTimestamp NotRFC3339", input: `[{"Type":"AccountRecalled","AccountID":"Jack","Timestamp":"2026-03-01"}]`},
+		{name: "ErrInvalidTimestamp NotString", input: `[{"Type":"AccountRecalled","AccountID":"Jack","Timestamp":1772323200}]`},
+		{
+			name:      "ErrInvalidPayloadFieldValue Invoice",
+			input:     `[{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":25,"Invoice":1}}]`,
+			wantField: event.EventPayloadFieldInvoice,
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, fast := range []bool{false, true} {
+				_, err := event.NewService(event.WithFastDecoder(fast)).ParseEvents(strings.NewReader(tt.input))
+				if tt.wantField == "" {
+					var timestampErr *event.ErrInvalidTimestamp
+					assert.ErrorAs(t, err, &timestampErr, "fast: %v", fast)
+					continue
+				}
+				var fieldErr *event.ErrInvalidPayloadFieldValue
+				if assert.ErrorAs(t, err, &fieldErr, "fast: %v", fast) {
+					assert.Equal(t, tt.wantField, fieldErr.Field)
+				}
+			}
+		})
+	}
+}
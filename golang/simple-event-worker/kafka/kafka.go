@@ -0,0 +1,235 @@
+// Package kafka adapts a Kafka-compatible topic to an event.Source.
+//
+// The adapter only depends on the Broker interface, the small subset of a Kafka client it needs,
+// so any client library can be plugged in. MemoryBroker implements it in memory for tests and local runs.
+package kafka
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+)
+
+const DefaultMaxBatchSize = 500
+
+// Message is a record of a topic partition. Its Value holds a single JSON-encoded event.
+type Message struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       []byte
+	Value     []byte
+}
+
+// Broker is the subset of a Kafka client used by the Consumer.
+type Broker interface {
+	// Partitions lists the partitions of a topic.
+	Partitions(ctx context.Context, topic string) ([]int, error)
+	// Fetch returns up to `limit` messages of a partition, starting at `offset`.
+	// It returns no messages if the partition has nothing at or after `offset` yet.
+	Fetch(ctx context.Context, topic string, partition int, offset int64, limit int) ([]Message, error)
+	// CommittedOffset returns the offset of the next message a consumer group should read from a partition.
+	// It is 0 if the group never committed an offset for the partition.
+	CommittedOffset(ctx context.Context, group, topic string, partition int) (int64, error)
+	// CommitOffset records the offset of the next message a consumer group should read from a partition.
+	CommitOffset(ctx context.Context, group, topic string, partition int, offset int64) error
+}
+
+// Consumer is an event.Source reading the events of a topic on behalf of a consumer group.
+//
+// Producers must key messages by AccountID, so all the events of an account land in the same partition in order.
+// Events of different accounts do not depend on each other, so partitions may be read in any order.
+type Consumer struct {
+	broker       Broker
+	group        string
+	topic        string
+	maxBatchSize int
+	service      *event.EventService
+
+	partitions []int
+	// positions are the offsets of the next messages to fetch, advanced by Commit only, so a batch that was polled but
+	// not committed, e.g. because it failed, is polled again.
+	positions map[int]int64
+	// uncommitted are the positions to commit once the last polled batch is processed.
+	uncommitted map[int]int64
+}
+
+type ConsumerOption func(*Consumer)
+
+// WithMaxBatchSize limits the number of events returned by a single Poll. The default is DefaultMaxBatchSize.
+func WithMaxBatchSize(size int) ConsumerOption {
+	return func(c *Consumer) {
+		c.maxBatchSize = size
+	}
+}
+
+// WithEventService decodes messages with the ParseEvents of `service`, so its schema mode, upcasters, decoder and
+// validation apply, as they do to a ReaderSource. The default is a service built with event.NewService().
+func WithEventService(service *event.EventService) ConsumerOption {
+	return func(c *Consumer) {
+		c.service = service
+	}
+}
+
+func NewConsumer(broker Broker, group, topic string, opts ...ConsumerOption) *Consumer {
+	c := &Consumer{
+		broker:       broker,
+		group:        group,
+		topic:        topic,
+		maxBatchSize: DefaultMaxBatchSize,
+		service:      event.NewService(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Poll fetches the next batch of events from the partitions of the topic, starting from the committed offsets of the group.
+// Until it is committed, every Poll returns the same batch again. It returns io.EOF once the consumer has caught up with
+// every partition.
+func (c *Consumer) Poll(ctx context.Context) ([]event.Event, error) {
+	if c.positions == nil {
+		if err := c.loadPositions(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	events := []event.Event{}
+	next := map[int]int64{}
+	for _, partition := range c.partitions {
+		limit := c.maxBatchSize - len(events)
+		if limit <= 0 {
+			break
+		}
+
+		messages, err := c.broker.Fetch(ctx, c.topic, partition, c.positions[partition], limit)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, message := range messages {
+			decoded, err := c.decodeMessage(message)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, decoded...)
+			next[partition] = message.Offset + 1
+		}
+	}
+
+	if len(next) == 0 {
+		return nil, io.EOF
+	}
+	c.uncommitted = next
+
+	return events, nil
+}
+
+// Commit commits the offsets following the last polled batch for the consumer group, and moves past the batch.
+func (c *Consumer) Commit(ctx context.Context) error {
+	for partition, offset := range c.uncommitted {
+		if err := c.broker.CommitOffset(ctx, c.group, c.topic, partition, offset); err != nil {
+			return err
+		}
+		c.positions[partition] = offset
+	}
+	c.uncommitted = nil
+
+	return nil
+}
+
+func (c *Consumer) loadPositions(ctx context.Context) error {
+	partitions, err := c.broker.Partitions(ctx, c.topic)
+	if err != nil {
+		return err
+	}
+
+	positions := make(map[int]int64, len(partitions))
+	for _, partition := range partitions {
+		offset, err := c.broker.CommittedOffset(ctx, c.group, c.topic, partition)
+		if err != nil {
+			return err
+		}
+		positions[partition] = offset
+	}
+
+	c.partitions = partitions
+	c.positions = positions
+
+	return nil
+}
+
+// decodeMessage decodes the event of a message as a single-event input of the service. A service with a DeadLetterSink
+// quarantines a rejected event, and the message decodes to no event.
+func (c *Consumer) decodeMessage(message Message) ([]event.Event, error) {
+	value, err := messageEvent(message.Value)
+	var events []event.Event
+	if err == nil {
+		events, err = c.service.ParseEvents(io.MultiReader(strings.NewReader("["), bytes.NewReader(value), strings.NewReader("]")))
+	}
+	if err != nil {
+		return nil, &ErrInvalidMessage{Topic: message.Topic, Partition: message.Partition, Offset: message.Offset, Err: err}
+	}
+
+	return events, nil
+}
+
+// messageEvent returns the JSON object of the event in the value of a message. An empty value, a value that is not an
+// object, e.g. an array of events, and bytes after the object are rejected, so a message holds exactly one event.
+func messageEvent(value []byte) (json.RawMessage, error) {
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	var object json.RawMessage
+	if err := decoder.Decode(&object); errors.Is(err, io.EOF) {
+		return nil, errors.New("a message must hold an event, not an empty value")
+	} else if err != nil {
+		return nil, err
+	}
+	if object[0] != '{' {
+		return nil, errors.New("a message must hold a single event object")
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("a message must hold a single event, with nothing after it")
+	}
+
+	return object, nil
+}
+
+type ErrInvalidMessage struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Err       error
+}
+
+func (e *ErrInvalidMessage) Error() string {
+	return fmt.Sprintf(`invalid event in message at "%s"/%d@%d: %s`, e.Topic, e.Partition, e.Offset, e.Err)
+}
+
+func (e *ErrInvalidMessage) Unwrap() error {
+	return e.Err
+}
+
+type ErrUnknownTopic struct {
+	Topic string
+}
+
+func (e *ErrUnknownTopic) Error() string {
+	return fmt.Sprintf(`unknown topic: "%s"`, e.Topic)
+}
+
+type ErrUnknownPartition struct {
+	Topic     string
+	Partition int
+}
+
+func (e *ErrUnknownPartition) Error() string {
+	return fmt.Sprintf(`unknown partition of topic "%s": %d`, e.Topic, e.Partition)
+}
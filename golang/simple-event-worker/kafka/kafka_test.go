@@ -0,0 +1,259 @@
+package kafka_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/kafka"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testTopic = "account-events"
+	testGroup = "simple-event-worker"
+)
+
+func produce(t *testing.T, broker *kafka.MemoryBroker, events ...event.Event) {
+	t.Helper()
+
+	for _, e := range events {
+		value, err := json.Marshal(e)
+		assert.NoError(t, err)
+
+		_, err = broker.Produce(context.Background(), testTopic, []byte(e.AccountID), value)
+		assert.NoError(t, err)
+	}
+}
+
+func committed(t *testing.T, broker *kafka.MemoryBroker) int64 {
+	t.Helper()
+
+	partitions, err := broker.Partitions(context.Background(), testTopic)
+	assert.NoError(t, err)
+
+	var total int64
+	for _, partition := range partitions {
+		offset, err := broker.CommittedOffset(context.Background(), testGroup, testTopic, partition)
+		assert.NoError(t, err)
+		total += offset
+	}
+
+	return total
+}
+
+func created(id string, balance int) event.Event {
+	return event.Event{Type: event.EventTypeAccountCreated, AccountID: id, Payload: &event.EventPayloadAccountCreated{Balance: balance}}
+}
+
+func charged(id string, amount int) event.Event {
+	return event.Event{Type: event.EventTypeAccountChargeReceived, AccountID: id, Payload: &event.EventPayloadAccountTransactionReceived{Amount: amount}}
+}
+
+func recalled(id string) event.Event {
+	return event.Event{Type: event.EventTypeAccountRecalled, AccountID: id}
+}
+
+func TestConsumer_ProcessSource_Success(t *testing.T) {
+	broker := kafka.NewMemoryBroker()
+	broker.CreateTopic(testTopic, 3)
+	produce(t, broker,
+		created("Jack", 50),
+		created("Jen", 100),
+		charged("Jack", 25),
+		created("Robert", 0),
+		recalled("Jen"),
+		charged("Robert", 10),
+	)
+
+	s := event.NewService()
+	consumer := kafka.NewConsumer(broker, testGroup, testTopic, kafka.WithMaxBatchSize(2))
+
+	got, err := s.ProcessSource(context.Background(), nil, consumer)
+	assert.NoError(t, err)
+
+	jack := event.NewAccount("Jack", 50)
+	_ = jack.RecordTransaction(25)
+	want := map[string]event.Account{
+		"Jack":   *jack,
+		"Jen":    *event.NewAccount("Jen", 100),
+		"Robert": *event.NewAccount("Robert", 10),
+	}
+	jen := want["Jen"]
+	jen.Recall()
+	want["Jen"] = jen
+
+	assert.Equal(t, want, got)
+	assert.Equal(t, int64(6), committed(t, broker))
+}
+
+func TestConsumer_ProcessSource_FailedBatchIsRedelivered(t *testing.T) {
+	broker := kafka.NewMemoryBroker()
+	broker.CreateTopic(testTopic, 1)
+	produce(t, broker,
+		created("Jack", 50),
+		recalled("Jack"),
+		charged("Jack", 25),
+	)
+
+	s := event.NewService()
+
+	// The first batch is folded and committed. The second one fails, so its offsets are not committed.
+	_, err := s.ProcessSource(context.Background(), nil, kafka.NewConsumer(broker, testGroup, testTopic, kafka.WithMaxBatchSize(2)))
+	assert.EqualError(t, err, (&event.ErrCannotTransactWithRecalledAccount{AccountID: "Jack"}).Error())
+	assert.Equal(t, int64(2), committed(t, broker))
+
+	// A new consumer of the same group resumes from the failed batch.
+	events, err := kafka.NewConsumer(broker, testGroup, testTopic).Poll(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []event.Event{charged("Jack", 25)}, events)
+}
+
+func TestConsumer_ProcessSource_Retry(t *testing.T) {
+	broker := kafka.NewMemoryBroker()
+	broker.CreateTopic(testTopic, 1)
+	produce(t, broker, created("Jack", 50), charged("Jen", 25))
+
+	s := event.NewService()
+	consumer := kafka.NewConsumer(broker, testGroup, testTopic, kafka.WithMaxBatchSize(1))
+	accounts, err := s.ProcessSource(context.Background(), nil, consumer)
+	assert.EqualError(t, err, (&event.ErrAccountDoesNotExist{AccountID: "Jen"}).Error())
+	assert.Nil(t, accounts)
+
+	// Retrying with the same consumer polls the failed batch again. Were it skipped, the retry would apply the account
+	// created after it, and succeed.
+	produce(t, broker, created("Jen", 0))
+	got, err := s.ProcessSource(context.Background(), map[string]event.Account{"Jack": *event.NewAccount("Jack", 50)}, consumer)
+	assert.EqualError(t, err, (&event.ErrAccountDoesNotExist{AccountID: "Jen"}).Error())
+	assert.Nil(t, got)
+	assert.Equal(t, int64(1), committed(t, broker))
+}
+
+func TestConsumer_ProcessSource_Resume(t *testing.T) {
+	broker := kafka.NewMemoryBroker()
+	broker.CreateTopic(testTopic, 1)
+	produce(t, broker, created("Jack", 50), charged("Jack", 25))
+
+	s := event.NewService()
+	before, err := s.ProcessSource(context.Background(), nil, kafka.NewConsumer(broker, testGroup, testTopic))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), committed(t, broker))
+
+	// A restarted consumer resumes after the committed offsets, on top of the accounts they produced.
+	produce(t, broker, charged("Jack", 10))
+	got, err := s.ProcessSource(context.Background(), before, kafka.NewConsumer(broker, testGroup, testTopic))
+	assert.NoError(t, err)
+
+	jack := before["Jack"]
+	assert.NoError(t, jack.RecordTransaction(10))
+	assert.Equal(t, map[string]event.Account{"Jack": jack}, got)
+	assert.Equal(t, int64(3), committed(t, broker))
+}
+
+func TestConsumer_Poll(t *testing.T) {
+	t.Run("EOFWhenCaughtUp", func(t *testing.T) {
+		broker := kafka.NewMemoryBroker()
+		broker.CreateTopic(testTopic, 2)
+
+		_, err := kafka.NewConsumer(broker, testGroup, testTopic).Poll(context.Background())
+		assert.ErrorIs(t, err, io.EOF)
+	})
+
+	t.Run("UncommittedBatchIsPolledAgain", func(t *testing.T) {
+		broker := kafka.NewMemoryBroker()
+		broker.CreateTopic(testTopic, 1)
+		produce(t, broker, created("Jack", 50), charged("Jack", 25))
+
+		consumer := kafka.NewConsumer(broker, testGroup, testTopic, kafka.WithMaxBatchSize(1))
+
+		first, err := consumer.Poll(context.Background())
+		assert.NoError(t, err)
+		again, err := consumer.Poll(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, []event.Event{created("Jack", 50)}, first)
+		assert.Equal(t, first, again)
+		assert.Equal(t, int64(0), committed(t, broker))
+
+		// Committing moves past the batch.
+		assert.NoError(t, consumer.Commit(context.Background()))
+		second, err := consumer.Poll(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, []event.Event{charged("Jack", 25)}, second)
+		assert.Equal(t, int64(1), committed(t, broker))
+	})
+
+	t.Run("WithEventService", func(t *testing.T) {
+		broker := kafka.NewMemoryBroker()
+		broker.CreateTopic(testTopic, 1)
+		_, err := broker.Produce(context.Background(), testTopic, []byte("Jack"), []byte(`{"Type":"ChargePosted","AccountID":"Jack","Payload":{"Amount":25}}`))
+		assert.NoError(t, err)
+
+		// Messages are upcast by the schema of the service.
+		schema, err := event.NewSchema(event.Upcaster{Type: "ChargePosted", Version: 1, To: event.EventTypeAccountChargeReceived})
+		assert.NoError(t, err)
+		events, err := kafka.NewConsumer(broker, testGroup, testTopic, kafka.WithEventService(event.NewService(event.WithSchema(schema)))).Poll(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, []event.Event{charged("Jack", 25)}, events)
+
+		// And rejected in its strict mode.
+		_, err = broker.Produce(context.Background(), testTopic, []byte("Jack"), []byte(`{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":25,"Memo":"late fee"}}`))
+		assert.NoError(t, err)
+		_, err = kafka.NewConsumer(broker, testGroup, testTopic, kafka.WithEventService(event.NewService(event.WithSchema(schema), event.WithStrictSchema(true)))).Poll(context.Background())
+		var target *kafka.ErrInvalidMessage
+		assert.True(t, errors.As(err, &target))
+		assert.Equal(t, int64(1), target.Offset)
+		var unknown *event.ErrUnknownPayloadField
+		assert.True(t, errors.As(err, &unknown))
+	})
+
+	t.Run("ErrInvalidMessage", func(t *testing.T) {
+		broker := kafka.NewMemoryBroker()
+		broker.CreateTopic(testTopic, 1)
+		_, err := broker.Produce(context.Background(), testTopic, []byte("Jack"), []byte(`{"Type":"AccountCreate","AccountID":"Jack"}`))
+		assert.NoError(t, err)
+
+		_, err = kafka.NewConsumer(broker, testGroup, testTopic).Poll(context.Background())
+
+		var target *kafka.ErrInvalidMessage
+		assert.True(t, errors.As(err, &target))
+		assert.Equal(t, int64(0), target.Offset)
+
+		var unsupported *event.ErrUnsupportedEventType
+		assert.True(t, errors.As(err, &unsupported))
+	})
+
+	t.Run("ErrInvalidMessage_NotSingleEvent", func(t *testing.T) {
+		created := `{"Type":"AccountCreated","AccountID":"Jack","Payload":{"Balance":50}}`
+		values := map[string]string{
+			"Empty":         "",
+			"Blank":         " \n",
+			"Array":         "[" + created + "]",
+			"TrailingBytes": created + "]",
+			"TwoEvents":     created + created,
+		}
+
+		for name, value := range values {
+			t.Run(name, func(t *testing.T) {
+				broker := kafka.NewMemoryBroker()
+				broker.CreateTopic(testTopic, 1)
+				produce(t, broker, charged("Jack", 25))
+				_, err := broker.Produce(context.Background(), testTopic, []byte("Jack"), []byte(value))
+				assert.NoError(t, err)
+
+				// The message is not skipped, so the batch is not committed.
+				_, err = kafka.NewConsumer(broker, testGroup, testTopic).Poll(context.Background())
+				var target *kafka.ErrInvalidMessage
+				assert.True(t, errors.As(err, &target))
+				assert.Equal(t, int64(1), target.Offset)
+			})
+		}
+	})
+
+	t.Run("ErrUnknownTopic", func(t *testing.T) {
+		_, err := kafka.NewConsumer(kafka.NewMemoryBroker(), testGroup, testTopic).Poll(context.Background())
+		assert.EqualError(t, err, (&kafka.ErrUnknownTopic{Topic: testTopic}).Error())
+	})
+}
@@ -0,0 +1,133 @@
+package kafka
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+)
+
+// MemoryBroker is a Broker keeping topics and committed offsets in memory. It is safe for concurrent use.
+type MemoryBroker struct {
+	mu      sync.Mutex
+	topics  map[string][][]Message
+	offsets map[offsetKey]int64
+}
+
+type offsetKey struct {
+	group     string
+	topic     string
+	partition int
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		topics:  map[string][][]Message{},
+		offsets: map[offsetKey]int64{},
+	}
+}
+
+// CreateTopic creates a topic with the given number of partitions. An existing topic is left untouched.
+func (b *MemoryBroker) CreateTopic(topic string, partitions int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.topics[topic]; ok {
+		return
+	}
+	b.topics[topic] = make([][]Message, max(partitions, 1))
+}
+
+// Produce appends a message to the partition of the topic chosen by hashing its key, like Kafka's default partitioner.
+func (b *MemoryBroker) Produce(_ context.Context, topic string, key, value []byte) (Message, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	partitions, ok := b.topics[topic]
+	if !ok {
+		return Message{}, &ErrUnknownTopic{Topic: topic}
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write(key)
+	partition := int(h.Sum32() % uint32(len(partitions)))
+
+	message := Message{
+		Topic:     topic,
+		Partition: partition,
+		Offset:    int64(len(partitions[partition])),
+		Key:       key,
+		Value:     value,
+	}
+	partitions[partition] = append(partitions[partition], message)
+
+	return message, nil
+}
+
+func (b *MemoryBroker) Partitions(_ context.Context, topic string) ([]int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	partitions, ok := b.topics[topic]
+	if !ok {
+		return nil, &ErrUnknownTopic{Topic: topic}
+	}
+
+	ids := make([]int, len(partitions))
+	for i := range partitions {
+		ids[i] = i
+	}
+
+	return ids, nil
+}
+
+func (b *MemoryBroker) Fetch(_ context.Context, topic string, partition int, offset int64, limit int) ([]Message, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	messages, err := b.partition(topic, partition)
+	if err != nil {
+		return nil, err
+	}
+
+	if offset < 0 || offset >= int64(len(messages)) {
+		return []Message{}, nil
+	}
+	end := min(offset+int64(limit), int64(len(messages)))
+
+	return append([]Message{}, messages[offset:end]...), nil
+}
+
+func (b *MemoryBroker) CommittedOffset(_ context.Context, group, topic string, partition int) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, err := b.partition(topic, partition); err != nil {
+		return 0, err
+	}
+
+	return b.offsets[offsetKey{group: group, topic: topic, partition: partition}], nil
+}
+
+func (b *MemoryBroker) CommitOffset(_ context.Context, group, topic string, partition int, offset int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, err := b.partition(topic, partition); err != nil {
+		return err
+	}
+	b.offsets[offsetKey{group: group, topic: topic, partition: partition}] = offset
+
+	return nil
+}
+
+func (b *MemoryBroker) partition(topic string, partition int) ([]Message, error) {
+	partitions, ok := b.topics[topic]
+	if !ok {
+		return nil, &ErrUnknownTopic{Topic: topic}
+	}
+	if partition < 0 || partition >= len(partitions) {
+		return nil, &ErrUnknownPartition{Topic: topic, Partition: partition}
+	}
+
+	return partitions[partition], nil
+}
@@ -0,0 +1,86 @@
+package kafka_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nogurenn/assorted-programs/simple-event-worker/kafka"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryBroker_Produce(t *testing.T) {
+	ctx := context.Background()
+	broker := kafka.NewMemoryBroker()
+	broker.CreateTopic(testTopic, 4)
+
+	first, err := broker.Produce(ctx, testTopic, []byte("Jack"), []byte("1"))
+	assert.NoError(t, err)
+	second, err := broker.Produce(ctx, testTopic, []byte("Jack"), []byte("2"))
+	assert.NoError(t, err)
+
+	// Messages with the same key land in the same partition, in order.
+	assert.Equal(t, first.Partition, second.Partition)
+	assert.Equal(t, int64(0), first.Offset)
+	assert.Equal(t, int64(1), second.Offset)
+
+	_, err = broker.Produce(ctx, "unknown", []byte("Jack"), []byte("1"))
+	assert.EqualError(t, err, (&kafka.ErrUnknownTopic{Topic: "unknown"}).Error())
+}
+
+func TestMemoryBroker_Fetch(t *testing.T) {
+	ctx := context.Background()
+	broker := kafka.NewMemoryBroker()
+	broker.CreateTopic(testTopic, 1)
+	for _, value := range []string{"a", "b", "c"} {
+		_, err := broker.Produce(ctx, testTopic, nil, []byte(value))
+		assert.NoError(t, err)
+	}
+
+	subtests := []struct {
+		name   string
+		offset int64
+		limit  int
+		want   []string
+	}{
+		{name: "FromStart", offset: 0, limit: 2, want: []string{"a", "b"}},
+		{name: "FromMiddle", offset: 1, limit: 10, want: []string{"b", "c"}},
+		{name: "PastEnd", offset: 3, limit: 10, want: []string{}},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages, err := broker.Fetch(ctx, testTopic, 0, tt.offset, tt.limit)
+			assert.NoError(t, err)
+
+			got := []string{}
+			for _, m := range messages {
+				got = append(got, string(m.Value))
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := broker.Fetch(ctx, testTopic, 1, 0, 1)
+	assert.EqualError(t, err, (&kafka.ErrUnknownPartition{Topic: testTopic, Partition: 1}).Error())
+}
+
+func TestMemoryBroker_CommitOffset(t *testing.T) {
+	ctx := context.Background()
+	broker := kafka.NewMemoryBroker()
+	broker.CreateTopic(testTopic, 1)
+
+	offset, err := broker.CommittedOffset(ctx, testGroup, testTopic, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), offset)
+
+	assert.NoError(t, broker.CommitOffset(ctx, testGroup, testTopic, 0, 5))
+
+	offset, err = broker.CommittedOffset(ctx, testGroup, testTopic, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), offset)
+
+	// Offsets are tracked per consumer group.
+	offset, err = broker.CommittedOffset(ctx, "another-group", testTopic, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), offset)
+}
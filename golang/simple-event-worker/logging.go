@@ -0,0 +1,84 @@
+package simpleeventworker
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+)
+
+// WithLogger sets the logger of the service. By default, the service logs nothing.
+//
+// Each parsed and processed event is logged at debug level with its index, type and account ID, and tenant ID if any.
+// Rejected events are logged at warning level with the reason. See WithLogPseudonyms to leave account IDs out.
+func WithLogger(logger *slog.Logger) ServiceOption {
+	return func(s *EventService) {
+		if logger == nil {
+			logger = newDiscardLogger()
+		}
+		s.logger = logger
+	}
+}
+
+// WithLogPseudonyms logs account IDs by `pseudonym` of them instead, e.g. reporting.Pseudonymizer.Pseudonym, since they
+// may embed the names of customers. The ID of an account quoted in the error logged along with its event is replaced
+// as well. By default, account IDs are logged as they are.
+func WithLogPseudonyms(pseudonym func(tenantID, accountID string) string) ServiceOption {
+	return func(s *EventService) {
+		s.logPseudonym = pseudonym
+	}
+}
+
+func newDiscardLogger() *slog.Logger {
+	return slog.New(discardHandler{})
+}
+
+// discardHandler drops every record. It is cheaper than a handler writing to io.Discard,
+// since records are never built in the first place.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool { return false }
+
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+
+func (h discardHandler) WithGroup(string) slog.Handler { return h }
+
+// debugEvent logs an event at debug level.
+// Its attributes are only built if the level is enabled, so a service logging nothing does not allocate for them.
+func (s *EventService) debugEvent(msg string, index int, event Event) {
+	if s.logger.Enabled(context.Background(), slog.LevelDebug) {
+		s.logger.Debug(msg, s.eventAttrs(index, event)...)
+	}
+}
+
+// warnEvent logs an event rejected with `err` at warning level.
+func (s *EventService) warnEvent(msg string, index int, event Event, err error) {
+	attrs := s.eventAttrs(index, event)
+	if s.logPseudonym != nil && event.AccountID != "" {
+		// Errors quote account IDs, e.g. `account with ID "Jack" does not exist`.
+		message := strings.ReplaceAll(err.Error(), `"`+event.AccountID+`"`, `"`+s.logPseudonym(event.TenantID, event.AccountID)+`"`)
+		attrs = append(attrs, slog.String("error", message))
+	} else {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	s.logger.Warn(msg, attrs...)
+}
+
+func (s *EventService) eventAttrs(index int, event Event) []any {
+	accountID := event.AccountID
+	if s.logPseudonym != nil && accountID != "" {
+		accountID = s.logPseudonym(event.TenantID, accountID)
+	}
+
+	attrs := []any{
+		slog.Int("index", index),
+		slog.String("type", event.Type),
+		slog.String("account_id", accountID),
+	}
+	if event.TenantID != "" {
+		attrs = append(attrs, slog.String("tenant_id", event.TenantID))
+	}
+
+	return attrs
+}
@@ -0,0 +1,142 @@
+package simpleeventworker_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/stretchr/testify/assert"
+)
+
+// logRecords decodes the records written by a slog.JSONHandler, dropping the time attribute.
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	records := []map[string]any{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		record := map[string]any{}
+		assert.NoError(t, json.Unmarshal([]byte(line), &record))
+		delete(record, slog.TimeKey)
+		records = append(records, record)
+	}
+
+	return records
+}
+
+func newTestLogger(buf *bytes.Buffer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+func TestLogging_ParseEvents(t *testing.T) {
+	var buf bytes.Buffer
+	s := event.NewService(event.WithLogger(newTestLogger(&buf)))
+
+	_, err := s.ParseEvents(strings.NewReader(`[
+		{"Type":"AccountCreated","AccountID":"Jack","Payload":{"Balance":50}},
+		{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":-25}}
+	]`))
+	assert.Error(t, err)
+
+	assert.Equal(t, []map[string]any{
+		{"level": "DEBUG", "msg": "event parsed", "index": float64(0), "type": "AccountCreated", "account_id": "Jack"},
+		{
+			"level":      "WARN",
+			"msg":        "event rejected while parsing",
+			"index":      float64(1),
+			"type":       "AccountChargeReceived",
+			"account_id": "Jack",
+			"error":      `invalid negative "Amount" for account with ID "Jack": -25`,
+		},
+	}, logRecords(t, &buf))
+}
+
+func TestLogging_ProcessEvents(t *testing.T) {
+	subtests := []struct {
+		name   string
+		events []event.Event
+		want   []map[string]any
+	}{
+		{
+			name: "Success",
+			events: []event.Event{
+				{Type: event.EventTypeAccountCreated, AccountID: "Jack", Payload: &event.EventPayloadAccountCreated{Balance: 50}},
+				{Type: event.EventTypeAccountRecalled, AccountID: "Jack", Payload: nil},
+			},
+			want: []map[string]any{
+				{"level": "DEBUG", "msg": "event processed", "index": float64(0), "type": "AccountCreated", "account_id": "Jack"},
+				{"level": "DEBUG", "msg": "event processed", "index": float64(1), "type": "AccountRecalled", "account_id": "Jack"},
+				{"level": "INFO", "msg": "events processed", "events": float64(2), "accounts": float64(1)},
+			},
+		},
+		{
+			name: "Rejected",
+			events: []event.Event{
+				{Type: event.EventTypeAccountRecalled, AccountID: "Jack", Payload: nil},
+			},
+			want: []map[string]any{
+				{
+					"level":      "WARN",
+					"msg":        "event rejected while processing",
+					"index":      float64(0),
+					"type":       "AccountRecalled",
+					"account_id": "Jack",
+					"error":      `account with ID does not exist: "Jack"`,
+				},
+			},
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			s := event.NewService(event.WithLogger(newTestLogger(&buf)))
+
+			_, _ = s.ProcessEvents(tt.events)
+			assert.Equal(t, tt.want, logRecords(t, &buf))
+		})
+	}
+}
+
+func TestLogging_DiscardByDefault(t *testing.T) {
+	s := event.NewService(event.WithLogger(nil))
+
+	_, err := s.ProcessEvents([]event.Event{
+		{Type: event.EventTypeAccountCreated, AccountID: "Jack", Payload: &event.EventPayloadAccountCreated{Balance: 50}},
+	})
+	assert.NoError(t, err)
+}
+
+func TestLogging_Pseudonyms(t *testing.T) {
+	var buf bytes.Buffer
+	pseudonyms := map[string]string{"/Jack": "acct-1", "acme/Jen": "acct-2"}
+	pseudonym := func(tenantID, accountID string) string {
+		return pseudonyms[tenantID+"/"+accountID]
+	}
+
+	_, err := event.NewService(event.WithLogger(newTestLogger(&buf)), event.WithLogPseudonyms(pseudonym)).
+		ProcessEvents([]event.Event{event.NewAccountCreatedEvent("Jack", 50)})
+	assert.NoError(t, err)
+	_, err = event.NewService(event.WithLogger(newTestLogger(&buf)), event.WithLogPseudonyms(pseudonym), event.WithTenant("acme")).
+		ProcessEvents([]event.Event{event.NewPaymentEvent("Jen", 25).InTenant("acme")})
+	assert.Error(t, err)
+
+	assert.Equal(t, []map[string]any{
+		{"level": "DEBUG", "msg": "event processed", "index": float64(0), "type": "AccountCreated", "account_id": "acct-1"},
+		{"level": "INFO", "msg": "events processed", "events": float64(1), "accounts": float64(1)},
+		{
+			"level":      "WARN",
+			"msg":        "event rejected while processing",
+			"index":      float64(0),
+			"type":       "AccountPaymentReceived",
+			"account_id": "acct-2",
+			"tenant_id":  "acme",
+			"error":      `account with ID does not exist: "acct-2"`,
+		},
+	}, logRecords(t, &buf))
+}
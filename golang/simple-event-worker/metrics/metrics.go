@@ -0,0 +1,251 @@
+// Package metrics implements a small registry of counters, gauges and histograms
+// exposed in the Prometheus text exposition format, and a Recorder that instruments an EventService with them.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	metricTypeCounter   = "counter"
+	metricTypeGauge     = "gauge"
+	metricTypeHistogram = "histogram"
+
+	contentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// Registry holds metrics in the order they were registered. It is safe for concurrent use.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []*metric
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// metric is a named family of series, one per combination of label values.
+type metric struct {
+	name       string
+	help       string
+	metricType string
+	labels     []string
+	buckets    []float64
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+	// Only used by histograms. bucketCounts[i] counts observations less than or equal to buckets[i].
+	bucketCounts []uint64
+	count        uint64
+}
+
+type CounterVec struct {
+	metric *metric
+}
+
+type GaugeVec struct {
+	metric *metric
+}
+
+type HistogramVec struct {
+	metric *metric
+}
+
+// NewCounterVec registers a counter partitioned by `labels`.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{metric: r.register(name, help, metricTypeCounter, labels, nil)}
+}
+
+// NewGaugeVec registers a gauge partitioned by `labels`.
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{metric: r.register(name, help, metricTypeGauge, labels, nil)}
+}
+
+// NewHistogramVec registers a histogram partitioned by `labels`. The `+Inf` bucket is always added.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	sorted := append([]float64{}, buckets...)
+	sort.Float64s(sorted)
+
+	return &HistogramVec{metric: r.register(name, help, metricTypeHistogram, labels, sorted)}
+}
+
+func (r *Registry) register(name, help, metricType string, labels []string, buckets []float64) *metric {
+	m := &metric{
+		name:       name,
+		help:       help,
+		metricType: metricType,
+		labels:     labels,
+		buckets:    buckets,
+		series:     map[string]*series{},
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, m)
+
+	return m
+}
+
+// Inc increments the counter of the series identified by `labelValues` by one.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increases the counter of the series identified by `labelValues`. Counters never decrease, so `v` must not be negative.
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("metrics: counter %s cannot decrease", c.metric.name))
+	}
+
+	c.metric.update(labelValues, func(s *series) {
+		s.value += v
+	})
+}
+
+// Set sets the gauge of the series identified by `labelValues`.
+func (g *GaugeVec) Set(v float64, labelValues ...string) {
+	g.metric.update(labelValues, func(s *series) {
+		s.value = v
+	})
+}
+
+// Observe records `v` in the histogram of the series identified by `labelValues`.
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	h.metric.update(labelValues, func(s *series) {
+		for i, upperBound := range h.metric.buckets {
+			if v <= upperBound {
+				s.bucketCounts[i]++
+			}
+		}
+		s.value += v
+		s.count++
+	})
+}
+
+func (m *metric) update(labelValues []string, fn func(s *series)) {
+	if len(labelValues) != len(m.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", m.name, len(m.labels), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.series[key]
+	if !ok {
+		s = &series{
+			labelValues:  append([]string{}, labelValues...),
+			bucketCounts: make([]uint64, len(m.buckets)),
+		}
+		m.series[key] = s
+	}
+	fn(s)
+}
+
+// WriteText writes every registered metric to w in the Prometheus text exposition format.
+// Series are sorted by label values so the output is stable.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	metrics := append([]*metric{}, r.metrics...)
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.writeText(bw)
+	}
+
+	return bw.Flush()
+}
+
+// Handler serves the registry in the text exposition format, e.g. under `/metrics`.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		if err := r.WriteText(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+func (m *metric) writeText(w *bufio.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", m.name, escapeHelp(m.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", m.name, m.metricType)
+
+	keys := make([]string, 0, len(m.series))
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// Histogram buckets carry an extra `le` label with their upper bound.
+	bucketLabels := append(append([]string{}, m.labels...), "le")
+
+	for _, key := range keys {
+		s := m.series[key]
+		if m.metricType != metricTypeHistogram {
+			fmt.Fprintf(w, "%s%s %s\n", m.name, formatLabels(m.labels, s.labelValues), formatFloat(s.value))
+			continue
+		}
+
+		bucketValues := append(append([]string{}, s.labelValues...), "")
+		for i, upperBound := range m.buckets {
+			bucketValues[len(bucketValues)-1] = formatFloat(upperBound)
+			fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, formatLabels(bucketLabels, bucketValues), s.bucketCounts[i])
+		}
+		bucketValues[len(bucketValues)-1] = "+Inf"
+		fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, formatLabels(bucketLabels, bucketValues), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", m.name, formatLabels(m.labels, s.labelValues), formatFloat(s.value))
+		fmt.Fprintf(w, "%s_count%s %d\n", m.name, formatLabels(m.labels, s.labelValues), s.count)
+	}
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf(`%s="%s"`, name, escapeLabelValue(values[i]))
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, +1) {
+		return "+Inf"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelValueEscaper.Replace(s)
+}
@@ -0,0 +1,100 @@
+package metrics_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/nogurenn/assorted-programs/simple-event-worker/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry_WriteText(t *testing.T) {
+	subtests := []struct {
+		name   string
+		record func(r *metrics.Registry)
+		want   string
+	}{
+		{
+			name: "Counter",
+			record: func(r *metrics.Registry) {
+				c := r.NewCounterVec("requests_total", "Number of requests.", "code")
+				c.Inc("500")
+				c.Inc("200")
+				c.Add(2, "200")
+			},
+			want: `# HELP requests_total Number of requests.
+# TYPE requests_total counter
+requests_total{code="200"} 3
+requests_total{code="500"} 1
+`,
+		},
+		{
+			name: "CounterWithoutSeries",
+			record: func(r *metrics.Registry) {
+				r.NewCounterVec("requests_total", "Number of requests.", "code")
+			},
+			want: `# HELP requests_total Number of requests.
+# TYPE requests_total counter
+`,
+		},
+		{
+			name: "Gauge",
+			record: func(r *metrics.Registry) {
+				g := r.NewGaugeVec("temperature", "Current temperature.")
+				g.Set(10)
+				g.Set(-2.5)
+			},
+			want: `# HELP temperature Current temperature.
+# TYPE temperature gauge
+temperature -2.5
+`,
+		},
+		{
+			name: "Histogram",
+			record: func(r *metrics.Registry) {
+				h := r.NewHistogramVec("latency_seconds", "Latency.", []float64{1, 0.1}, "path")
+				h.Observe(0.05, "/")
+				h.Observe(0.5, "/")
+				h.Observe(5, "/")
+			},
+			want: `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{path="/",le="0.1"} 1
+latency_seconds_bucket{path="/",le="1"} 2
+latency_seconds_bucket{path="/",le="+Inf"} 3
+latency_seconds_sum{path="/"} 5.55
+latency_seconds_count{path="/"} 3
+`,
+		},
+		{
+			name: "EscapedLabelValues",
+			record: func(r *metrics.Registry) {
+				c := r.NewCounterVec("errors_total", "Errors by\nmessage.", "message")
+				c.Inc("say \"hi\"\\")
+			},
+			want: `# HELP errors_total Errors by\nmessage.
+# TYPE errors_total counter
+errors_total{message="say \"hi\"\\"} 1
+`,
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := metrics.NewRegistry()
+			tt.record(r)
+
+			var buf bytes.Buffer
+			assert.NoError(t, r.WriteText(&buf))
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func TestRegistry_Panics(t *testing.T) {
+	r := metrics.NewRegistry()
+	c := r.NewCounterVec("requests_total", "Number of requests.", "code")
+
+	assert.Panics(t, func() { c.Inc() })
+	assert.Panics(t, func() { c.Add(-1, "200") })
+}
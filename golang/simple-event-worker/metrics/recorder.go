@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"errors"
+	"reflect"
+	"time"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+)
+
+const (
+	StageParse   = "parse"
+	StageProcess = "process"
+
+	// errorLabelOther labels errors that are not declared by the event package, e.g. malformed JSON.
+	errorLabelOther = "Other"
+)
+
+// DefaultLatencyBuckets are the upper bounds, in seconds, of the event processing latency histogram.
+// Folding a single event is in the order of microseconds.
+var DefaultLatencyBuckets = []float64{0.000001, 0.000005, 0.00001, 0.00005, 0.0001, 0.0005, 0.001, 0.005, 0.01}
+
+var eventPackagePath = reflect.TypeOf(event.Event{}).PkgPath()
+
+// Recorder is an event.Instrumentation that records measurements of an EventService into a Registry.
+type Recorder struct {
+	eventsParsed      *CounterVec
+	eventsProcessed   *CounterVec
+	errors            *CounterVec
+	processingSeconds *HistogramVec
+	accounts          *GaugeVec
+}
+
+// NewRecorder registers the event worker metrics in `registry`.
+func NewRecorder(registry *Registry) *Recorder {
+	return &Recorder{
+		eventsParsed: registry.NewCounterVec(
+			"simple_event_worker_events_parsed_total",
+			"Number of events parsed, by event type.",
+			"type",
+		),
+		eventsProcessed: registry.NewCounterVec(
+			"simple_event_worker_events_processed_total",
+			"Number of events processed, by event type.",
+			"type",
+		),
+		errors: registry.NewCounterVec(
+			"simple_event_worker_errors_total",
+			"Number of events rejected, by stage and error.",
+			"stage", "error",
+		),
+		processingSeconds: registry.NewHistogramVec(
+			"simple_event_worker_event_processing_seconds",
+			"Time taken to process a single event, by event type.",
+			DefaultLatencyBuckets,
+			"type",
+		),
+		accounts: registry.NewGaugeVec(
+			"simple_event_worker_accounts",
+			"Number of accounts after the last processed batch, by status.",
+			"status",
+		),
+	}
+}
+
+func (r *Recorder) EventParsed(e event.Event, err error) {
+	if err != nil {
+		r.errors.Inc(StageParse, ErrorLabel(err))
+		return
+	}
+	r.eventsParsed.Inc(e.Type)
+}
+
+func (r *Recorder) EventProcessed(e event.Event, duration time.Duration, err error) {
+	r.eventsProcessed.Inc(e.Type)
+	r.processingSeconds.Observe(duration.Seconds(), e.Type)
+	if err != nil {
+		r.errors.Inc(StageProcess, ErrorLabel(err))
+	}
+}
+
+func (r *Recorder) AccountsProcessed(accounts map[string]event.Account) {
+	counts := map[string]int{
+		event.AccountStatusOutstanding: 0,
+		event.AccountStatusSettled:     0,
+		event.AccountStatusOverpaid:    0,
+		event.AccountStatusRecalled:    0,
+		event.AccountStatusWrittenOff:  0,
+	}
+	for _, account := range accounts {
+		counts[account.Status()]++
+	}
+
+	for status, count := range counts {
+		r.accounts.Set(float64(count), status)
+	}
+}
+
+// ErrorLabel names an error by its type, e.g. "ErrAccountDoesNotExist", for use as a label value.
+// Errors not declared by the event package are labeled "Other".
+func ErrorLabel(err error) string {
+	if errors.Is(err, event.ErrInputJSONIsNotArray) {
+		return "ErrInputJSONIsNotArray"
+	}
+
+	t := reflect.TypeOf(err)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.PkgPath() != eventPackagePath || t.Name() == "" {
+		return errorLabelOther
+	}
+
+	return t.Name()
+}
@@ -0,0 +1,111 @@
+package metrics_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func scrape(t *testing.T, server *httptest.Server) string {
+	t.Helper()
+
+	resp, err := http.Get(server.URL + "/metrics")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain"))
+
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	return string(body)
+}
+
+func TestRecorder_Scrape(t *testing.T) {
+	registry := metrics.NewRegistry()
+	s := event.NewService(event.WithInstrumentation(metrics.NewRecorder(registry)))
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", registry.Handler())
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	events, err := s.ParseEvents(strings.NewReader(`[
+		{"Type":"AccountCreated","AccountID":"Jack","Payload":{"Balance":50}},
+		{"Type":"AccountCreated","AccountID":"Jen","Payload":{"Balance":0}},
+		{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":25}},
+		{"Type":"AccountRecalled","AccountID":"Jen","Payload":{}}
+	]`))
+	assert.NoError(t, err)
+
+	_, err = s.ProcessEvents(events)
+	assert.NoError(t, err)
+
+	// A failing batch is still counted, and its error is labeled by type.
+	_, err = s.ProcessEvents([]event.Event{
+		{Type: event.EventTypeAccountPaymentReceived, AccountID: "Robert", Payload: &event.EventPayloadAccountTransactionReceived{Amount: 25}},
+	})
+	assert.Error(t, err)
+
+	_, err = s.ParseEvents(strings.NewReader(`[{"Type":"AccountCreate","AccountID":"Jack","Payload":{"Balance":50}}]`))
+	assert.Error(t, err)
+
+	body := scrape(t, server)
+
+	for _, want := range []string{
+		`simple_event_worker_events_parsed_total{type="AccountChargeReceived"} 1`,
+		`simple_event_worker_events_parsed_total{type="AccountCreated"} 2`,
+		`simple_event_worker_events_parsed_total{type="AccountRecalled"} 1`,
+		`simple_event_worker_events_processed_total{type="AccountCreated"} 2`,
+		`simple_event_worker_events_processed_total{type="AccountPaymentReceived"} 1`,
+		`simple_event_worker_errors_total{stage="parse",error="ErrUnsupportedEventType"} 1`,
+		`simple_event_worker_errors_total{stage="process",error="ErrAccountDoesNotExist"} 1`,
+		`simple_event_worker_event_processing_seconds_count{type="AccountCreated"} 2`,
+		`simple_event_worker_event_processing_seconds_bucket{type="AccountCreated",le="+Inf"} 2`,
+		`simple_event_worker_accounts{status="Outstanding"} 1`,
+		`simple_event_worker_accounts{status="Recalled"} 1`,
+		`simple_event_worker_accounts{status="Settled"} 0`,
+		`simple_event_worker_accounts{status="Overpaid"} 0`,
+		`simple_event_worker_accounts{status="WrittenOff"} 0`,
+	} {
+		assert.Contains(t, body, want)
+	}
+}
+
+func TestErrorLabel(t *testing.T) {
+	subtests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "TypedError",
+			err:  &event.ErrCannotTransactWithRecalledAccount{AccountID: "Jack"},
+			want: "ErrCannotTransactWithRecalledAccount",
+		},
+		{
+			name: "SentinelError",
+			err:  event.ErrInputJSONIsNotArray,
+			want: "ErrInputJSONIsNotArray",
+		},
+		{
+			name: "Other",
+			err:  errors.New("unexpected EOF"),
+			want: "Other",
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, metrics.ErrorLabel(tt.err))
+		})
+	}
+}
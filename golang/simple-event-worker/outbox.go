@@ -0,0 +1,111 @@
+package simpleeventworker
+
+const (
+	DomainEventTypeAccountSettled           = "AccountSettled"
+	DomainEventTypeAccountBecameOverpaid    = "AccountBecameOverpaid"
+	DomainEventTypeAccountBecameOutstanding = "AccountBecameOutstanding"
+	DomainEventTypeAccountRecalledFinal     = "AccountRecalledFinal"
+	DomainEventTypeAccountWrittenOff        = "AccountWrittenOff"
+)
+
+// DomainEvent tells downstream systems (collections, notifications, ...) that an account changed status.
+// Unlike an Event, it is derived by the worker rather than received from upstream.
+type DomainEvent struct {
+	Type      string `json:"Type"`
+	TenantID  string `json:"TenantID,omitempty"`
+	AccountID string `json:"AccountID"`
+	// EventIndex is the index, in its batch, of the event that caused the transition.
+	EventIndex int    `json:"EventIndex"`
+	FromStatus string `json:"FromStatus"`
+	ToStatus   string `json:"ToStatus"`
+	Balance    int    `json:"Balance"`
+	// WrittenOff is the debt written off the account, for an AccountWrittenOff domain event.
+	WrittenOff int `json:"WrittenOff,omitempty"`
+}
+
+// Outbox receives the domain events of a batch.
+type Outbox interface {
+	// Publish is called once per batch applied successfully, with its domain events in the order they happened.
+	// If Publish fails, the batch fails as well.
+	Publish(events []DomainEvent) error
+}
+
+// CommitOutbox is an Outbox holding the domain events published until the batches they came from are committed, e.g.
+// to notify systems outside the worker only of batches that will not be delivered again.
+type CommitOutbox interface {
+	Outbox
+	// Commit releases the domain events published since the last Commit or Rollback.
+	Commit() error
+	// Rollback drops the domain events published since the last Commit or Rollback, whose batches were not committed.
+	Rollback()
+}
+
+// WithOutbox sets the Outbox receiving domain events. By default, domain events are discarded.
+//
+// Domain events are only published for batches applied successfully. If the batch comes from a Source and
+// its commit fails afterwards, the batch is delivered again, so an outbox must tolerate duplicates, unless it is a
+// CommitOutbox: ProcessSource commits it once the source committed the batch, and rolls it back otherwise. Callers
+// applying batches themselves commit it once their state is saved.
+func WithOutbox(outbox Outbox) ServiceOption {
+	return func(s *EventService) {
+		if outbox == nil {
+			outbox = noopOutbox{}
+		}
+		s.outbox = outbox
+	}
+}
+
+type noopOutbox struct{}
+
+func (noopOutbox) Publish([]DomainEvent) error { return nil }
+
+// commitOutbox commits the outbox of the service, if it is a CommitOutbox.
+func (s *EventService) commitOutbox() error {
+	if o, ok := s.outbox.(CommitOutbox); ok {
+		return o.Commit()
+	}
+
+	return nil
+}
+
+// rollbackOutbox rolls the outbox of the service back, if it is a CommitOutbox.
+func (s *EventService) rollbackOutbox() {
+	if o, ok := s.outbox.(CommitOutbox); ok {
+		o.Rollback()
+	}
+}
+
+// newDomainEvent derives the domain event of an existing account going from `before` to `after`, if its status changed.
+// Creating an account is not a transition.
+func newDomainEvent(index int, tenantID string, before, after Account) (DomainEvent, bool) {
+	if before.status == after.status {
+		return DomainEvent{}, false
+	}
+
+	var domainEventType string
+	switch after.status {
+	case AccountStatusSettled:
+		domainEventType = DomainEventTypeAccountSettled
+	case AccountStatusOverpaid:
+		domainEventType = DomainEventTypeAccountBecameOverpaid
+	case AccountStatusOutstanding:
+		domainEventType = DomainEventTypeAccountBecameOutstanding
+	case AccountStatusRecalled:
+		domainEventType = DomainEventTypeAccountRecalledFinal
+	case AccountStatusWrittenOff:
+		domainEventType = DomainEventTypeAccountWrittenOff
+	default:
+		return DomainEvent{}, false
+	}
+
+	return DomainEvent{
+		Type:       domainEventType,
+		TenantID:   tenantID,
+		AccountID:  after.ID,
+		EventIndex: index,
+		FromStatus: before.status,
+		ToStatus:   after.status,
+		Balance:    after.balance,
+		WrittenOff: after.writtenOff,
+	}, true
+}
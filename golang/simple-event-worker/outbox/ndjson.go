@@ -0,0 +1,72 @@
+// Package outbox implements event.Outbox destinations for domain events.
+package outbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+)
+
+// NDJSONWriter writes domain events to an io.Writer as newline-delimited JSON, one event per line.
+// It is safe for concurrent use.
+type NDJSONWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewNDJSONWriter(w io.Writer) *NDJSONWriter {
+	return &NDJSONWriter{w: w}
+}
+
+// Publish encodes the whole batch before writing it with a single Write,
+// so a batch that cannot be encoded leaves nothing behind.
+func (o *NDJSONWriter) Publish(events []event.DomainEvent) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, e := range events {
+		if err := encoder.Encode(e); err != nil {
+			return err
+		}
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	_, err := o.w.Write(buf.Bytes())
+	return err
+}
+
+// NDJSONFile is an NDJSONWriter appending to a file. Each batch is synced to disk before Publish returns.
+type NDJSONFile struct {
+	*NDJSONWriter
+	file *os.File
+}
+
+// OpenNDJSONFile opens, or creates, the file at `path` for appending.
+func OpenNDJSONFile(path string) (*NDJSONFile, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NDJSONFile{
+		NDJSONWriter: NewNDJSONWriter(file),
+		file:         file,
+	}, nil
+}
+
+func (o *NDJSONFile) Publish(events []event.DomainEvent) error {
+	if err := o.NDJSONWriter.Publish(events); err != nil {
+		return err
+	}
+
+	return o.file.Sync()
+}
+
+func (o *NDJSONFile) Close() error {
+	return o.file.Close()
+}
@@ -0,0 +1,73 @@
+package outbox_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/outbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func testEvents() []event.Event {
+	return []event.Event{
+		{Type: event.EventTypeAccountCreated, AccountID: "Jack", Payload: &event.EventPayloadAccountCreated{Balance: 50}},
+		{Type: event.EventTypeAccountPaymentReceived, AccountID: "Jack", Payload: &event.EventPayloadAccountTransactionReceived{Amount: 50}},
+		{Type: event.EventTypeAccountRecalled, AccountID: "Jack", Payload: nil},
+	}
+}
+
+const wantNDJSON = `{"Type":"AccountSettled","AccountID":"Jack","EventIndex":1,"FromStatus":"Outstanding","ToStatus":"Settled","Balance":0}
+{"Type":"AccountRecalledFinal","AccountID":"Jack","EventIndex":2,"FromStatus":"Settled","ToStatus":"Recalled","Balance":0}
+`
+
+func TestNDJSONWriter_Publish(t *testing.T) {
+	var buf bytes.Buffer
+	s := event.NewService(event.WithOutbox(outbox.NewNDJSONWriter(&buf)))
+
+	_, err := s.ProcessEvents(testEvents())
+	assert.NoError(t, err)
+	assert.Equal(t, wantNDJSON, buf.String())
+}
+
+func TestNDJSONWriter_Publish_FailedBatch(t *testing.T) {
+	var buf bytes.Buffer
+	s := event.NewService(event.WithOutbox(outbox.NewNDJSONWriter(&buf)))
+
+	events := append(testEvents(), event.Event{
+		Type:      event.EventTypeAccountChargeReceived,
+		AccountID: "Jack",
+		Payload:   &event.EventPayloadAccountTransactionReceived{Amount: 25},
+	})
+
+	_, err := s.ProcessEvents(events)
+	assert.Error(t, err)
+	assert.Empty(t, buf.String())
+}
+
+func TestNDJSONFile_Publish(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.ndjson")
+
+	file, err := outbox.OpenNDJSONFile(path)
+	assert.NoError(t, err)
+
+	s := event.NewService(event.WithOutbox(file))
+	_, err = s.ProcessEvents(testEvents())
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+
+	// Reopening the file appends to it.
+	file, err = outbox.OpenNDJSONFile(path)
+	assert.NoError(t, err)
+
+	s = event.NewService(event.WithOutbox(file))
+	_, err = s.ProcessEvents(testEvents())
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+
+	got, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, wantNDJSON+wantNDJSON, string(got))
+}
@@ -0,0 +1,331 @@
+package outbox
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+)
+
+// SignatureHeader carries the HMAC-SHA256 of the body of a webhook request, as "sha256=<hex>".
+const SignatureHeader = "X-Webhook-Signature"
+
+// Delivery is the outcome of posting a domain event to a webhook, as recorded in its delivery log.
+type Delivery struct {
+	Event event.DomainEvent `json:"Event"`
+	// Attempts is the number of requests made, retries included.
+	Attempts int `json:"Attempts"`
+	// StatusCode is the status of the last response, or 0 if there was none.
+	StatusCode int `json:"StatusCode,omitempty"`
+	// Error is why the event was not delivered, or empty if it was.
+	Error string `json:"Error,omitempty"`
+	// Time is when the last attempt ended.
+	Time time.Time `json:"Time"`
+}
+
+type ErrDeliveryFailed struct {
+	StatusCode int
+}
+
+func (e *ErrDeliveryFailed) Error() string {
+	return fmt.Sprintf("webhook responded with status %d", e.StatusCode)
+}
+
+// WebhookOption configures a Webhook.
+type WebhookOption func(*Webhook)
+
+// WithWebhookSecret signs every request with HMAC-SHA256 keyed by `secret`, in the SignatureHeader.
+// By default, requests are not signed.
+func WithWebhookSecret(secret []byte) WebhookOption {
+	return func(w *Webhook) {
+		w.secret = secret
+	}
+}
+
+// WithWebhookTypes sets the types of domain events posted. By default, they are
+// DomainEventTypeAccountSettled and DomainEventTypeAccountRecalledFinal.
+func WithWebhookTypes(types ...string) WebhookOption {
+	return func(w *Webhook) {
+		w.types = types
+	}
+}
+
+// WithWebhookRetry makes up to `attempts` requests per domain event, waiting `backoff` before the first retry and
+// twice as long before each next one. By default, a request is attempted 3 times, starting with a 500ms backoff.
+func WithWebhookRetry(attempts int, backoff time.Duration) WebhookOption {
+	return func(w *Webhook) {
+		w.attempts = max(attempts, 1)
+		w.backoff = backoff
+	}
+}
+
+// WithWebhookClient sets the HTTP client of the requests. By default, it is a client with a 10s timeout.
+func WithWebhookClient(client *http.Client) WebhookOption {
+	return func(w *Webhook) {
+		w.client = client
+	}
+}
+
+// WithWebhookDeliveryLog writes the Delivery of every domain event posted to `log`, as newline-delimited JSON.
+func WithWebhookDeliveryLog(log io.Writer) WebhookOption {
+	return func(w *Webhook) {
+		w.log = log
+	}
+}
+
+// Webhook posts domain events as JSON to an HTTP endpoint, e.g. a notification service. It is safe for concurrent use.
+//
+// It is a CommitOutbox: Publish only holds the domain events of a batch, and Commit hands them over to a goroutine
+// posting them in the background, so the requests and their retries never hold up the batches. Close waits for the
+// domain events committed so far to be posted.
+//
+// A webhook never fails its batch: an event that cannot be delivered after its retries is recorded in the delivery log
+// and dropped, since the accounts are already applied. Placed last in a Fanout, it only holds the domain events of
+// batches that every other outbox accepted. Services sharing a webhook, e.g. the tenants of a tenant.Processor, commit
+// and roll back the domain events of all of them at once.
+type Webhook struct {
+	url      string
+	secret   []byte
+	types    []string
+	attempts int
+	backoff  time.Duration
+	client   *http.Client
+	log      io.Writer
+
+	mu sync.Mutex
+	// held are the domain events published since the last Commit or Rollback.
+	held []event.DomainEvent
+	// committed feeds the committed domain events to the goroutine posting them, in order. It is started by the
+	// first Commit, and stopped by Close.
+	committed chan []event.DomainEvent
+	done      chan struct{}
+	closed    bool
+	// err is the first error writing the delivery log, read once done is closed.
+	err error
+}
+
+// committedBatches bounds the batches committed but not posted yet. Once they are all waiting, Commit waits too.
+const committedBatches = 64
+
+var ErrWebhookClosed = errors.New("outbox: webhook is closed")
+
+func NewWebhook(url string, opts ...WebhookOption) *Webhook {
+	w := &Webhook{
+		url:      url,
+		types:    []string{event.DomainEventTypeAccountSettled, event.DomainEventTypeAccountRecalledFinal},
+		attempts: 3,
+		backoff:  500 * time.Millisecond,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		log:      io.Discard,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w
+}
+
+// Publish holds the domain events of the types of the webhook until Commit. It never fails.
+func (w *Webhook) Publish(events []event.DomainEvent) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, e := range events {
+		if slices.Contains(w.types, e.Type) {
+			w.held = append(w.held, e)
+		}
+	}
+
+	return nil
+}
+
+// Commit posts the domain events held since the last Commit or Rollback in the background, one at a time, in order.
+// It fails with ErrWebhookClosed once the webhook is closed.
+func (w *Webhook) Commit() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return ErrWebhookClosed
+	}
+	if len(w.held) == 0 {
+		return nil
+	}
+	if w.committed == nil {
+		w.committed = make(chan []event.DomainEvent, committedBatches)
+		w.done = make(chan struct{})
+		go w.run()
+	}
+	w.committed <- w.held
+	w.held = nil
+
+	return nil
+}
+
+// Rollback drops the domain events held since the last Commit or Rollback.
+func (w *Webhook) Rollback() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.held = nil
+}
+
+// Close waits for the domain events committed so far to be posted, and drops the ones still held. It fails if the
+// delivery log could not be written.
+func (w *Webhook) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.held = nil
+	started := w.committed != nil
+	if started {
+		close(w.committed)
+	}
+	w.mu.Unlock()
+
+	if !started {
+		return nil
+	}
+	<-w.done
+
+	return w.err
+}
+
+// run posts the committed domain events until Close, and logs their deliveries. Once the delivery log cannot be
+// written, the next events are still posted, but not logged.
+func (w *Webhook) run() {
+	defer close(w.done)
+
+	encoder := json.NewEncoder(w.log)
+	for events := range w.committed {
+		for _, e := range events {
+			d := w.deliver(e)
+			if w.err == nil {
+				w.err = encoder.Encode(d)
+			}
+		}
+	}
+}
+
+// deliver posts a domain event until it is accepted, or its attempts run out.
+func (w *Webhook) deliver(e event.DomainEvent) Delivery {
+	d := Delivery{Event: e}
+
+	body, err := json.Marshal(e)
+	if err != nil {
+		d.Error = err.Error()
+		d.Time = time.Now()
+		return d
+	}
+
+	backoff := w.backoff
+	for d.Attempts < w.attempts {
+		if d.Attempts > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		d.Attempts++
+
+		var retry bool
+		d.StatusCode, retry, err = w.post(body)
+		if err == nil || !retry {
+			break
+		}
+	}
+	d.Time = time.Now()
+	if err != nil {
+		d.Error = err.Error()
+	}
+
+	return d
+}
+
+// post makes one request, and tells whether a failed one is worth retrying: after a network error, a 5xx or a 429.
+func (w *Webhook) post(body []byte) (int, bool, error) {
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.secret != nil {
+		req.Header.Set(SignatureHeader, Sign(w.secret, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, true, err
+	}
+	defer resp.Body.Close()
+	// Drain the body, so the connection is reused.
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+
+	return resp.StatusCode, retry, &ErrDeliveryFailed{StatusCode: resp.StatusCode}
+}
+
+// Sign returns the value of the SignatureHeader of a request with `body`.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature tells whether `signature`, the SignatureHeader of a request, signs `body` with `secret`.
+// Receivers should verify it before trusting the request.
+func VerifySignature(secret, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// Fanout publishes the domain events of a batch to several outboxes in order, and stops at the first failure,
+// which fails the batch. Outboxes that never fail, like a Webhook, belong last.
+//
+// It is a CommitOutbox, committing and rolling back the outboxes that are CommitOutboxes, in order.
+type Fanout []event.Outbox
+
+func (f Fanout) Publish(events []event.DomainEvent) error {
+	for _, o := range f {
+		if err := o.Publish(events); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (f Fanout) Commit() error {
+	for _, o := range f {
+		if o, ok := o.(event.CommitOutbox); ok {
+			if err := o.Commit(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (f Fanout) Rollback() {
+	for _, o := range f {
+		if o, ok := o.(event.CommitOutbox); ok {
+			o.Rollback()
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package outbox_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/outbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeReceiver records the requests of a webhook, and answers them with `statuses` in turn, then 200.
+type fakeReceiver struct {
+	mu       sync.Mutex
+	statuses []int
+	bodies   [][]byte
+	headers  []http.Header
+}
+
+func (f *fakeReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.bodies = append(f.bodies, body)
+	f.headers = append(f.headers, r.Header.Clone())
+	status := http.StatusOK
+	if len(f.statuses) > 0 {
+		status, f.statuses = f.statuses[0], f.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func readDeliveries(t *testing.T, log *bytes.Buffer) []outbox.Delivery {
+	t.Helper()
+
+	deliveries := []outbox.Delivery{}
+	decoder := json.NewDecoder(log)
+	for decoder.More() {
+		var d outbox.Delivery
+		require.NoError(t, decoder.Decode(&d))
+		assert.False(t, d.Time.IsZero())
+		d.Time = time.Time{}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries
+}
+
+var (
+	settled = event.DomainEvent{Type: event.DomainEventTypeAccountSettled, AccountID: "Jack", EventIndex: 1, FromStatus: "Outstanding", ToStatus: "Settled", Balance: 0}
+	final   = event.DomainEvent{Type: event.DomainEventTypeAccountRecalledFinal, AccountID: "Jack", EventIndex: 2, FromStatus: "Settled", ToStatus: "Recalled", Balance: 0}
+)
+
+func TestWebhook_Publish(t *testing.T) {
+	receiver := &fakeReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	secret := []byte("s3cret")
+	var log bytes.Buffer
+	webhook := outbox.NewWebhook(server.URL, outbox.WithWebhookSecret(secret), outbox.WithWebhookDeliveryLog(&log))
+	s := event.NewService(event.WithOutbox(webhook))
+
+	_, err := s.ProcessEvents(testEvents())
+	require.NoError(t, err)
+	// The domain events are held until they are committed.
+	require.NoError(t, webhook.Close())
+	assert.Empty(t, receiver.bodies)
+
+	webhook = outbox.NewWebhook(server.URL, outbox.WithWebhookSecret(secret), outbox.WithWebhookDeliveryLog(&log))
+	s = event.NewService(event.WithOutbox(webhook))
+	_, err = s.ProcessEvents(testEvents())
+	require.NoError(t, err)
+	require.NoError(t, webhook.Commit())
+	require.NoError(t, webhook.Close())
+	assert.ErrorIs(t, webhook.Commit(), outbox.ErrWebhookClosed)
+
+	require.Len(t, receiver.bodies, 2)
+	for i, want := range []event.DomainEvent{settled, final} {
+		var got event.DomainEvent
+		require.NoError(t, json.Unmarshal(receiver.bodies[i], &got))
+		assert.Equal(t, want, got)
+		assert.Equal(t, "application/json", receiver.headers[i].Get("Content-Type"))
+		assert.True(t, outbox.VerifySignature(secret, receiver.bodies[i], receiver.headers[i].Get(outbox.SignatureHeader)))
+		assert.False(t, outbox.VerifySignature([]byte("other"), receiver.bodies[i], receiver.headers[i].Get(outbox.SignatureHeader)))
+	}
+
+	assert.Equal(t, []outbox.Delivery{
+		{Event: settled, Attempts: 1, StatusCode: http.StatusOK},
+		{Event: final, Attempts: 1, StatusCode: http.StatusOK},
+	}, readDeliveries(t, &log))
+}
+
+func TestWebhook_Publish_Types(t *testing.T) {
+	receiver := &fakeReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	webhook := outbox.NewWebhook(server.URL)
+	s := event.NewService(event.WithOutbox(webhook))
+	_, err := s.ProcessEvents([]event.Event{
+		event.NewAccountCreatedEvent("Jack", 50),
+		event.NewPaymentEvent("Jack", 60),
+		event.NewChargeEvent("Jack", 20),
+	})
+	require.NoError(t, err)
+	require.NoError(t, webhook.Commit())
+	require.NoError(t, webhook.Close())
+	assert.Empty(t, receiver.bodies)
+
+	// Unsigned webhooks do not send a signature.
+	webhook = outbox.NewWebhook(server.URL, outbox.WithWebhookTypes(event.DomainEventTypeAccountBecameOverpaid))
+	require.NoError(t, webhook.Publish([]event.DomainEvent{settled, {Type: event.DomainEventTypeAccountBecameOverpaid, AccountID: "Jack"}}))
+	require.NoError(t, webhook.Commit())
+	require.NoError(t, webhook.Close())
+	require.Len(t, receiver.bodies, 1)
+	assert.Empty(t, receiver.headers[0].Get(outbox.SignatureHeader))
+}
+
+func TestWebhook_Publish_Retry(t *testing.T) {
+	subtests := []struct {
+		name     string
+		statuses []int
+		want     outbox.Delivery
+	}{
+		{
+			name:     "Delivered",
+			statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests},
+			want:     outbox.Delivery{Event: settled, Attempts: 3, StatusCode: http.StatusOK},
+		},
+		{
+			name:     "AttemptsExhausted",
+			statuses: []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable},
+			want:     outbox.Delivery{Event: settled, Attempts: 3, StatusCode: http.StatusServiceUnavailable, Error: (&outbox.ErrDeliveryFailed{StatusCode: http.StatusServiceUnavailable}).Error()},
+		},
+		{
+			// A client error is not retried, since the same request would fail again.
+			name:     "ClientError",
+			statuses: []int{http.StatusBadRequest},
+			want:     outbox.Delivery{Event: settled, Attempts: 1, StatusCode: http.StatusBadRequest, Error: (&outbox.ErrDeliveryFailed{StatusCode: http.StatusBadRequest}).Error()},
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := &fakeReceiver{statuses: tt.statuses}
+			server := httptest.NewServer(receiver)
+			defer server.Close()
+
+			var log bytes.Buffer
+			webhook := outbox.NewWebhook(server.URL, outbox.WithWebhookRetry(3, time.Millisecond), outbox.WithWebhookDeliveryLog(&log))
+
+			// An undelivered event does not fail the batch.
+			assert.NoError(t, webhook.Publish([]event.DomainEvent{settled}))
+			assert.NoError(t, webhook.Commit())
+			assert.NoError(t, webhook.Close())
+			assert.Len(t, receiver.bodies, tt.want.Attempts)
+			assert.Equal(t, []outbox.Delivery{tt.want}, readDeliveries(t, &log))
+		})
+	}
+}
+
+func TestWebhook_Publish_Unreachable(t *testing.T) {
+	server := httptest.NewServer(&fakeReceiver{})
+	server.Close()
+
+	var log bytes.Buffer
+	webhook := outbox.NewWebhook(server.URL, outbox.WithWebhookRetry(2, time.Millisecond), outbox.WithWebhookDeliveryLog(&log))
+	assert.NoError(t, webhook.Publish([]event.DomainEvent{settled}))
+	assert.NoError(t, webhook.Commit())
+	assert.NoError(t, webhook.Close())
+
+	deliveries := readDeliveries(t, &log)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, 2, deliveries[0].Attempts)
+	assert.Equal(t, 0, deliveries[0].StatusCode)
+	assert.NotEmpty(t, deliveries[0].Error)
+}
+
+type failingOutbox struct{}
+
+func (failingOutbox) Publish([]event.DomainEvent) error {
+	return errors.New("disk full")
+}
+
+func TestWebhook_Publish_FailedBatch(t *testing.T) {
+	receiver := &fakeReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	// A batch failing while applied publishes nothing.
+	webhook := outbox.NewWebhook(server.URL)
+	s := event.NewService(event.WithOutbox(webhook))
+	_, err := s.ProcessEvents(append(testEvents(), event.NewChargeEvent("Jack", 25)))
+	assert.Error(t, err)
+
+	// Nor does a batch failing in an outbox before the webhook.
+	s = event.NewService(event.WithOutbox(outbox.Fanout{failingOutbox{}, webhook}))
+	_, err = s.ProcessEvents(testEvents())
+	assert.EqualError(t, err, "disk full")
+
+	require.NoError(t, webhook.Commit())
+	require.NoError(t, webhook.Close())
+	assert.Empty(t, receiver.bodies)
+}
+
+// fakeSource delivers `batches` in turn, and fails the commit of the batch at index `failCommit`.
+type fakeSource struct {
+	batches    [][]event.Event
+	failCommit int
+	polled     int
+}
+
+func (f *fakeSource) Poll(_ context.Context) ([]event.Event, error) {
+	if f.polled == len(f.batches) {
+		return nil, io.EOF
+	}
+	f.polled++
+
+	return f.batches[f.polled-1], nil
+}
+
+func (f *fakeSource) Commit(_ context.Context) error {
+	if f.polled-1 == f.failCommit {
+		return errors.New("broker unavailable")
+	}
+
+	return nil
+}
+
+func TestWebhook_ProcessSource(t *testing.T) {
+	receiver := &fakeReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	webhook := outbox.NewWebhook(server.URL)
+	s := event.NewService(event.WithOutbox(webhook))
+	source := &fakeSource{
+		batches: [][]event.Event{
+			testEvents(),
+			{event.NewAccountCreatedEvent("Jen", 50), event.NewPaymentEvent("Jen", 50)},
+		},
+		failCommit: 1,
+	}
+
+	// The second batch is not committed, so it will be delivered again, and its domain events are dropped.
+	_, err := s.ProcessSource(context.Background(), nil, source)
+	assert.EqualError(t, err, "broker unavailable")
+	require.NoError(t, webhook.Close())
+
+	require.Len(t, receiver.bodies, 2)
+	for i, want := range []event.DomainEvent{settled, final} {
+		var got event.DomainEvent
+		require.NoError(t, json.Unmarshal(receiver.bodies[i], &got))
+		assert.Equal(t, want, got)
+	}
+}
+
+func TestFanout_Publish(t *testing.T) {
+	receiver := &fakeReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	var buf bytes.Buffer
+	webhook := outbox.NewWebhook(server.URL)
+	fanout := outbox.Fanout{outbox.NewNDJSONWriter(&buf), webhook}
+	s := event.NewService(event.WithOutbox(fanout))
+
+	_, err := s.ProcessEvents(testEvents())
+	require.NoError(t, err)
+	assert.Equal(t, wantNDJSON, buf.String())
+	require.NoError(t, fanout.Commit())
+	require.NoError(t, webhook.Close())
+	assert.Len(t, receiver.bodies, 2)
+}
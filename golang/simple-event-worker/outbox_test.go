@@ -0,0 +1,103 @@
+package simpleeventworker_test
+
+import (
+	"errors"
+	"testing"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeOutbox struct {
+	published [][]event.DomainEvent
+	err       error
+}
+
+func (f *fakeOutbox) Publish(events []event.DomainEvent) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.published = append(f.published, events)
+
+	return nil
+}
+
+func TestOutbox_ProcessEvents(t *testing.T) {
+	subtests := []struct {
+		name   string
+		events []event.Event
+		want   [][]event.DomainEvent
+	}{
+		{
+			name: "Transitions",
+			events: []event.Event{
+				{Type: event.EventTypeAccountCreated, AccountID: "Jack", Payload: &event.EventPayloadAccountCreated{Balance: 50}},
+				{Type: event.EventTypeAccountCreated, AccountID: "Jen", Payload: &event.EventPayloadAccountCreated{Balance: 0}},
+				{Type: event.EventTypeAccountPaymentReceived, AccountID: "Jack", Payload: &event.EventPayloadAccountTransactionReceived{Amount: 50}},
+				{Type: event.EventTypeAccountPaymentReceived, AccountID: "Jack", Payload: &event.EventPayloadAccountTransactionReceived{Amount: 10}},
+				{Type: event.EventTypeAccountChargeReceived, AccountID: "Jen", Payload: &event.EventPayloadAccountTransactionReceived{Amount: 10}},
+				{Type: event.EventTypeAccountChargeReceived, AccountID: "Jen", Payload: &event.EventPayloadAccountTransactionReceived{Amount: 10}},
+				{Type: event.EventTypeAccountRecalled, AccountID: "Jen", Payload: nil},
+			},
+			want: [][]event.DomainEvent{{
+				{Type: event.DomainEventTypeAccountSettled, AccountID: "Jack", EventIndex: 2, FromStatus: event.AccountStatusOutstanding, ToStatus: event.AccountStatusSettled, Balance: 0},
+				{Type: event.DomainEventTypeAccountBecameOverpaid, AccountID: "Jack", EventIndex: 3, FromStatus: event.AccountStatusSettled, ToStatus: event.AccountStatusOverpaid, Balance: -10},
+				{Type: event.DomainEventTypeAccountBecameOutstanding, AccountID: "Jen", EventIndex: 4, FromStatus: event.AccountStatusSettled, ToStatus: event.AccountStatusOutstanding, Balance: 10},
+				{Type: event.DomainEventTypeAccountRecalledFinal, AccountID: "Jen", EventIndex: 6, FromStatus: event.AccountStatusOutstanding, ToStatus: event.AccountStatusRecalled, Balance: 20},
+			}},
+		},
+		{
+			name: "NoTransitions",
+			events: []event.Event{
+				{Type: event.EventTypeAccountCreated, AccountID: "Jack", Payload: &event.EventPayloadAccountCreated{Balance: 50}},
+				{Type: event.EventTypeAccountChargeReceived, AccountID: "Jack", Payload: &event.EventPayloadAccountTransactionReceived{Amount: 50}},
+			},
+			want: nil,
+		},
+		{
+			name: "FailedBatch",
+			events: []event.Event{
+				{Type: event.EventTypeAccountCreated, AccountID: "Jack", Payload: &event.EventPayloadAccountCreated{Balance: 50}},
+				{Type: event.EventTypeAccountRecalled, AccountID: "Jack", Payload: nil},
+				{Type: event.EventTypeAccountRecalled, AccountID: "Jen", Payload: nil},
+			},
+			want: nil,
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeOutbox{}
+			s := event.NewService(event.WithOutbox(fake))
+
+			_, _ = s.ProcessEvents(tt.events)
+			assert.Equal(t, tt.want, fake.published)
+		})
+	}
+}
+
+func TestOutbox_ProcessEvents_Tenant(t *testing.T) {
+	fake := &fakeOutbox{}
+	s := event.NewService(event.WithOutbox(fake), event.WithTenant("acme"))
+
+	_, err := s.ProcessEvents([]event.Event{
+		event.NewAccountCreatedEvent("Jack", 50).InTenant("acme"),
+		event.NewRecalledEvent("Jack").InTenant("acme"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, [][]event.DomainEvent{{
+		{Type: event.DomainEventTypeAccountRecalledFinal, TenantID: "acme", AccountID: "Jack", EventIndex: 1, FromStatus: event.AccountStatusOutstanding, ToStatus: event.AccountStatusRecalled, Balance: 50},
+	}}, fake.published)
+}
+
+func TestOutbox_PublishError(t *testing.T) {
+	fake := &fakeOutbox{err: errors.New("disk full")}
+	s := event.NewService(event.WithOutbox(fake))
+
+	got, err := s.ProcessEvents([]event.Event{
+		{Type: event.EventTypeAccountCreated, AccountID: "Jack", Payload: &event.EventPayloadAccountCreated{Balance: 50}},
+		{Type: event.EventTypeAccountRecalled, AccountID: "Jack", Payload: nil},
+	})
+	assert.EqualError(t, err, "disk full")
+	assert.Nil(t, got)
+}
@@ -0,0 +1,262 @@
+package simpleeventworker_test
+
+import (
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+	"testing/quick"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/stretchr/testify/assert"
+)
+
+// eventSequence is a random sequence of events that satisfies the ordering the fold relies on:
+// an account is created once before any of its other events, and recalled accounts receive no more charges or payments.
+type eventSequence []event.Event
+
+func (eventSequence) Generate(r *rand.Rand, size int) reflect.Value {
+	ids := []string{}
+	recalled := map[string]bool{}
+	events := eventSequence{}
+
+	// active lists the accounts that can still receive charges, payments and recalls.
+	active := func() []string {
+		a := []string{}
+		for _, id := range ids {
+			if !recalled[id] {
+				a = append(a, id)
+			}
+		}
+		return a
+	}
+
+	for range r.Intn(size*4 + 1) {
+		candidates := active()
+		if len(candidates) == 0 || r.Intn(5) == 0 {
+			id := "Account-" + strconv.Itoa(len(ids))
+			ids = append(ids, id)
+			events = append(events, event.Event{
+				Type:      event.EventTypeAccountCreated,
+				AccountID: id,
+				Payload:   &event.EventPayloadAccountCreated{Balance: r.Intn(1000)},
+			})
+			continue
+		}
+
+		id := candidates[r.Intn(len(candidates))]
+		switch n := r.Intn(20); {
+		case n < 9:
+			events = append(events, event.Event{
+				Type:      event.EventTypeAccountChargeReceived,
+				AccountID: id,
+				Payload:   &event.EventPayloadAccountTransactionReceived{Amount: r.Intn(1000), Invoice: randomInvoice(r)},
+			})
+		case n < 18:
+			events = append(events, event.Event{
+				Type:      event.EventTypeAccountPaymentReceived,
+				AccountID: id,
+				Payload:   &event.EventPayloadAccountTransactionReceived{Amount: r.Intn(1000), Invoice: randomInvoice(r)},
+			})
+		default:
+			recalled[id] = true
+			events = append(events, event.Event{Type: event.EventTypeAccountRecalled, AccountID: id})
+		}
+	}
+
+	return reflect.ValueOf(events)
+}
+
+// randomInvoice returns one of a few invoice references, or none, so that payments reference some of the charges.
+func randomInvoice(r *rand.Rand) string {
+	if n := r.Intn(4); n > 0 {
+		return "INV-" + strconv.Itoa(n)
+	}
+
+	return ""
+}
+
+// modelAccount is a reference model of an account: the signed postings it received, in order.
+type modelAccount struct {
+	postings []int
+	recalled bool
+}
+
+func (m modelAccount) balance() int {
+	sum := 0
+	for _, posting := range m.postings {
+		sum += posting
+	}
+	return sum
+}
+
+// model folds events the simplest way possible. It trusts the events to be valid.
+func model(events []event.Event) map[string]*modelAccount {
+	accounts := map[string]*modelAccount{}
+	for _, e := range events {
+		switch e.Type {
+		case event.EventTypeAccountCreated:
+			accounts[e.AccountID] = &modelAccount{postings: []int{e.Payload.(*event.EventPayloadAccountCreated).Balance}}
+		case event.EventTypeAccountChargeReceived:
+			a := accounts[e.AccountID]
+			a.postings = append(a.postings, e.Payload.(*event.EventPayloadAccountTransactionReceived).Amount)
+		case event.EventTypeAccountPaymentReceived:
+			a := accounts[e.AccountID]
+			a.postings = append(a.postings, -e.Payload.(*event.EventPayloadAccountTransactionReceived).Amount)
+		case event.EventTypeAccountRecalled:
+			accounts[e.AccountID].recalled = true
+		}
+	}
+	return accounts
+}
+
+func statusOf(balance int) string {
+	switch {
+	case balance > 0:
+		return event.AccountStatusOutstanding
+	case balance < 0:
+		return event.AccountStatusOverpaid
+	default:
+		return event.AccountStatusSettled
+	}
+}
+
+var quickConfig = &quick.Config{MaxCount: 300, Rand: rand.New(rand.NewSource(1))}
+
+func TestProperty_MatchesModel(t *testing.T) {
+	s := event.NewService()
+
+	property := func(events eventSequence) bool {
+		got, err := s.ProcessEvents(events)
+		if err != nil {
+			t.Logf("valid sequence rejected: %v", err)
+			return false
+		}
+
+		want := model(events)
+		if len(got) != len(want) {
+			return false
+		}
+		for id, m := range want {
+			account, ok := got[id]
+			if !ok || account.ID != id {
+				return false
+			}
+			// The balance equals the sum of the signed postings, even for recalled accounts.
+			if account.Balance() != m.balance() {
+				return false
+			}
+			if account.IsRecalled() != m.recalled {
+				return false
+			}
+		}
+		return true
+	}
+
+	assert.NoError(t, quick.Check(property, quickConfig))
+}
+
+func TestProperty_StatusMatchesBalanceSign(t *testing.T) {
+	s := event.NewService()
+
+	property := func(events eventSequence) bool {
+		accounts := map[string]event.Account{}
+		for _, e := range events {
+			var err error
+			accounts, err = s.ApplyEvents(accounts, []event.Event{e})
+			if err != nil {
+				return false
+			}
+
+			// The invariant holds after every event, not only at the end.
+			for _, account := range accounts {
+				want := statusOf(account.Balance())
+				if account.IsRecalled() {
+					want = event.AccountStatusRecalled
+				}
+				if account.Status() != want {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	assert.NoError(t, quick.Check(property, quickConfig))
+}
+
+func TestProperty_BalanceMatchesOpenItems(t *testing.T) {
+	s := event.NewService()
+
+	property := func(events eventSequence) bool {
+		accounts := map[string]event.Account{}
+		for _, e := range events {
+			var err error
+			accounts, err = s.ApplyEvents(accounts, []event.Event{e})
+			if err != nil {
+				return false
+			}
+
+			for _, account := range accounts {
+				if account.Balance() != openItemsBalance(account) {
+					return false
+				}
+				// Credit pays the next charges, so an account never has both.
+				if account.Credit() < 0 || (account.Credit() > 0 && len(account.OpenItems()) > 0) {
+					return false
+				}
+				for _, item := range account.OpenItems() {
+					if item.Remaining <= 0 || item.Remaining > item.Amount {
+						return false
+					}
+				}
+			}
+		}
+		return true
+	}
+
+	assert.NoError(t, quick.Check(property, quickConfig))
+}
+
+func TestProperty_RecalledAccountsNeverChange(t *testing.T) {
+	s := event.NewService()
+
+	property := func(events eventSequence, amount uint16, charge bool) bool {
+		final, err := s.ProcessEvents(events)
+		if err != nil {
+			return false
+		}
+
+		for i, e := range events {
+			if e.Type != event.EventTypeAccountRecalled {
+				continue
+			}
+
+			// The account is the same right after its recall and at the end of the sequence.
+			atRecall, err := s.ProcessEvents(events[:i+1])
+			if err != nil || !reflect.DeepEqual(atRecall[e.AccountID], final[e.AccountID]) {
+				return false
+			}
+
+			// Any further charge or payment is rejected, and does not leak into the given state.
+			eventType := event.EventTypeAccountPaymentReceived
+			if charge {
+				eventType = event.EventTypeAccountChargeReceived
+			}
+			_, err = s.ApplyEvents(final, []event.Event{{
+				Type:      eventType,
+				AccountID: e.AccountID,
+				Payload:   &event.EventPayloadAccountTransactionReceived{Amount: int(amount)},
+			}})
+			if _, ok := err.(*event.ErrCannotTransactWithRecalledAccount); !ok {
+				return false
+			}
+			if account := final[e.AccountID]; !account.IsRecalled() {
+				return false
+			}
+		}
+		return true
+	}
+
+	assert.NoError(t, quick.Check(property, quickConfig))
+}
@@ -0,0 +1,438 @@
+// Package repl steps through events interactively, e.g. to find out how a customer's balance came to be without editing
+// and rerunning an input. A Session applies events one at a time with an EventService, and keeps every state it went
+// through, so any step can be undone.
+package repl
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+)
+
+var (
+	ErrNoMoreEvents  = errors.New("repl: no more loaded events")
+	ErrNothingToUndo = errors.New("repl: nothing to undo")
+)
+
+// Entry is an event applied by a session, with the state of its account right after it.
+type Entry struct {
+	// Index is the index of the event in the loaded events, or -1 if it was injected.
+	Index int
+	Event event.Event
+	// Account is the account of the event after it, the zero Account if it does not exist.
+	Account event.Account
+	// Pending is set if the event takes effect after the clock of the service, and was parked rather than applied.
+	Pending bool
+	// Due is set if the event was parked by an earlier step, and applied by this one once it took effect. Its Index is
+	// the one of the step that parked it.
+	Due bool
+}
+
+// state is everything a step changes, so that undoing a step restores the state before it.
+type state struct {
+	accounts map[string]event.Account
+	pending  []event.Event
+	// next is the index of the next loaded event to step through.
+	next    int
+	history []Entry
+}
+
+// Option configures a Session.
+type Option func(*Session)
+
+// WithServiceOptions sets the options of the EventService applying and parsing the events, e.g. its schema or clock.
+func WithServiceOptions(opts ...event.ServiceOption) Option {
+	return func(s *Session) {
+		s.serviceOpts = opts
+	}
+}
+
+// WithLoader sets how the `load` command reads the events of a path. By default, it parses a JSON file.
+func WithLoader(load func(path string) ([]event.Event, error)) Option {
+	return func(s *Session) {
+		s.load = load
+	}
+}
+
+// Session holds the events loaded and the state of the accounts after the steps taken so far.
+type Session struct {
+	serviceOpts []event.ServiceOption
+	load        func(path string) ([]event.Event, error)
+	events      []event.Event
+	state       state
+	undo        []state
+}
+
+func New(opts ...Option) *Session {
+	s := &Session{state: state{accounts: map[string]event.Account{}}}
+	s.load = s.loadJSON
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+func (s *Session) loadJSON(path string) ([]event.Event, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return event.NewService(s.serviceOpts...).ParseEvents(file)
+}
+
+// Load starts over from no accounts with `events` to step through.
+func (s *Session) Load(events []event.Event) {
+	s.events = events
+	s.state = state{accounts: map[string]event.Account{}}
+	s.undo = nil
+}
+
+// Remaining returns the number of loaded events not stepped through yet.
+func (s *Session) Remaining() int {
+	return len(s.events) - s.state.next
+}
+
+// Step applies the next loaded event. A rejected event is not stepped over, so it can be fixed by injecting the
+// events it needs first, or skipped with Skip.
+func (s *Session) Step() (Entry, error) {
+	if s.Remaining() == 0 {
+		return Entry{}, ErrNoMoreEvents
+	}
+
+	return s.apply(s.state.next, s.events[s.state.next])
+}
+
+// Skip steps over the next loaded event without applying it.
+func (s *Session) Skip() (event.Event, error) {
+	if s.Remaining() == 0 {
+		return event.Event{}, ErrNoMoreEvents
+	}
+
+	e := s.events[s.state.next]
+	s.undo = append(s.undo, s.state)
+	s.state.next++
+
+	return e, nil
+}
+
+// Inject applies an event that is not part of the loaded events, before the next loaded one.
+func (s *Session) Inject(e event.Event) (Entry, error) {
+	return s.apply(-1, e)
+}
+
+// apply applies an event with a new service, on top of the current state, and records the state before it for Undo.
+// The pending events that came due are applied first, and recorded in the history along with the event.
+func (s *Session) apply(index int, e event.Event) (Entry, error) {
+	accounts, pending := s.state.accounts, s.state.pending
+	entries := []Entry{}
+
+	// Due events are applied one at a time, so each is recorded with the state of its account right after it. The
+	// pending events are in the order they take effect, so the first one that is not due yet ends them.
+	for len(pending) > 0 {
+		due := event.NewPendingQueue(pending[0])
+		b, err := s.service(due).ApplyBatch(accounts, nil)
+		if err != nil {
+			return Entry{}, err
+		}
+		if due.Len() > 0 {
+			break
+		}
+		accounts = b.Accounts
+		// An event quarantined by a DeadLetterSink of the session is not applied.
+		if len(b.Applied) > 0 {
+			entries = append(entries, Entry{Index: s.parkedAt(pending[0]), Event: pending[0], Account: accounts[pending[0].AccountID], Due: true})
+		}
+		pending = pending[1:]
+	}
+
+	queue := event.NewPendingQueue(pending...)
+	b, err := s.service(queue).ApplyBatch(accounts, []event.Event{e})
+	if err != nil {
+		return Entry{}, err
+	}
+
+	entry := Entry{
+		Index:   index,
+		Event:   e,
+		Account: b.Accounts[e.AccountID],
+		Pending: slices.Contains(queue.Events(), e),
+	}
+	entries = append(entries, entry)
+
+	s.undo = append(s.undo, s.state)
+	next := s.state.next
+	if index >= 0 {
+		next++
+	}
+	s.state = state{
+		accounts: b.Accounts,
+		pending:  queue.Events(),
+		next:     next,
+		// The history of the state before is never appended to again, so the states share it.
+		history: append(slices.Clip(s.state.history), entries...),
+	}
+
+	return entry, nil
+}
+
+// service returns a new service applying events with the options of the session and `queue`.
+func (s *Session) service(queue *event.PendingQueue) *event.EventService {
+	return event.NewService(append(slices.Clone(s.serviceOpts), event.WithPendingQueue(queue))...)
+}
+
+// parkedAt returns the index of the step that parked a pending event, or -1 if it was injected.
+func (s *Session) parkedAt(e event.Event) int {
+	for _, entry := range slices.Backward(s.state.history) {
+		if entry.Pending && entry.Event == e {
+			return entry.Index
+		}
+	}
+
+	return -1
+}
+
+// Undo restores the state before the last step, skip or injected event.
+func (s *Session) Undo() error {
+	if len(s.undo) == 0 {
+		return ErrNothingToUndo
+	}
+
+	s.state = s.undo[len(s.undo)-1]
+	s.undo = s.undo[:len(s.undo)-1]
+
+	return nil
+}
+
+// Account returns the current state of an account, and whether it exists.
+func (s *Session) Account(id string) (event.Account, bool) {
+	account, ok := s.state.accounts[id]
+	return account, ok
+}
+
+// Accounts returns the current state of every account, by ID.
+func (s *Session) Accounts() *event.AccountSet {
+	return event.NewAccountSet(s.state.accounts)
+}
+
+// History returns the events applied to an account so far, oldest first.
+func (s *Session) History(id string) []Entry {
+	history := []Entry{}
+	for _, entry := range s.state.history {
+		if entry.Event.AccountID == id {
+			history = append(history, entry)
+		}
+	}
+
+	return history
+}
+
+// Pending returns the events parked until they take effect.
+func (s *Session) Pending() []event.Event {
+	return slices.Clone(s.state.pending)
+}
+
+const help = `Commands:
+  load <path>          start over with the events of a file
+  step [n]             apply the next n loaded events, 1 by default
+  run                  apply the remaining loaded events, stopping at the first rejected one
+  skip                 step over the next loaded event without applying it
+  inject <event JSON>  apply an event before the next loaded one, e.g. inject {"Type":"AccountRecalled","AccountID":"Jack"}
+  undo                 undo the last step, skip or injected event
+  print <account ID>   print the status, balance and history of an account
+  accounts             print every account
+  pending              print the events pending until they take effect
+  help                 print this help
+  quit                 leave`
+
+// Run reads commands from r, one per line, and writes their output to w, until r ends or a `quit` command.
+// A command that fails prints its error and leaves the state as it was, so Run only fails if r or w do.
+func (s *Session) Run(r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	// Injected events are single lines of JSON, which may be longer than the default limit.
+	scanner.Buffer(nil, 1<<20)
+
+	for {
+		if _, err := fmt.Fprint(w, "> "); err != nil {
+			return err
+		}
+		if !scanner.Scan() {
+			_, err := fmt.Fprintln(w)
+			return errors.Join(scanner.Err(), err)
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+		command, args, _ := strings.Cut(line, " ")
+		if command == "quit" || command == "exit" {
+			return nil
+		}
+		if err := s.exec(w, command, strings.TrimSpace(args)); err != nil {
+			if _, err := fmt.Fprintf(w, "error: %s\n", err); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Session) exec(w io.Writer, command, args string) error {
+	switch command {
+	case "":
+		return nil
+	case "help":
+		_, err := fmt.Fprintln(w, help)
+		return err
+	case "load":
+		if args == "" {
+			return errors.New("usage: load <path>")
+		}
+		events, err := s.load(args)
+		if err != nil {
+			return err
+		}
+		s.Load(events)
+		_, err = fmt.Fprintf(w, "loaded %d events\n", len(events))
+		return err
+	case "step", "run":
+		n := s.Remaining()
+		if command == "step" {
+			n = 1
+			if args != "" {
+				var err error
+				if n, err = strconv.Atoi(args); err != nil || n < 1 {
+					return fmt.Errorf(`invalid number of steps: "%s"`, args)
+				}
+			}
+		}
+		if s.Remaining() == 0 {
+			return ErrNoMoreEvents
+		}
+		// Stepping past the last loaded event stops at it.
+		for range min(n, s.Remaining()) {
+			applied := len(s.state.history)
+			if _, err := s.Step(); err != nil {
+				return fmt.Errorf("event %d: %w", s.state.next, err)
+			}
+			if err := writeEntries(w, s.state.history[applied:]); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "skip":
+		e, err := s.Skip()
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "skipped %d: %s %s\n", s.state.next-1, e.Type, e.AccountID)
+		return err
+	case "inject":
+		events, err := event.NewService(s.serviceOpts...).ParseEvents(strings.NewReader("[" + args + "]"))
+		if err != nil {
+			return err
+		}
+		if len(events) != 1 {
+			return errors.New("usage: inject <event JSON>")
+		}
+		applied := len(s.state.history)
+		if _, err := s.Inject(events[0]); err != nil {
+			return err
+		}
+		return writeEntries(w, s.state.history[applied:])
+	case "undo":
+		if err := s.Undo(); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintf(w, "undone, %d loaded events remaining\n", s.Remaining())
+		return err
+	case "print":
+		account, ok := s.Account(args)
+		if !ok {
+			return &event.ErrAccountDoesNotExist{AccountID: args}
+		}
+		if err := writeAccount(w, args, account); err != nil {
+			return err
+		}
+		for _, entry := range s.History(args) {
+			if _, err := fmt.Fprint(w, "  "); err != nil {
+				return err
+			}
+			if err := writeEntry(w, entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "accounts":
+		for id, account := range s.Accounts().All() {
+			if err := writeAccount(w, id, account); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "pending":
+		for _, e := range s.Pending() {
+			if _, err := fmt.Fprintf(w, "%s: pending %s effective at %s\n", e.AccountID, e.Type, e.EffectiveAt.Format(time.RFC3339)); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf(`unknown command "%s", see help`, command)
+	}
+}
+
+// writeAccount writes an account as the worker prints it.
+func writeAccount(w io.Writer, id string, account event.Account) error {
+	_, err := fmt.Fprintf(w, "%s: {Status: %s, Balance: %d}\n", id, account.Status(), account.Balance())
+	return err
+}
+
+// writeEntries writes the entries of a step: the pending events that came due, then its event.
+func writeEntries(w io.Writer, entries []Entry) error {
+	for _, entry := range entries {
+		if err := writeEntry(w, entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeEntry writes an event and the state of its account after it, e.g.
+// `3: AccountChargeReceived Jack {"Amount":25} -> {Status: Outstanding, Balance: 75}`. A pending event that came due
+// is written as `3: due AccountChargeReceived ...`.
+func writeEntry(w io.Writer, entry Entry) error {
+	description := fmt.Sprintf("%s %s", entry.Event.Type, entry.Event.AccountID)
+	if entry.Due {
+		description = "due " + description
+	}
+	if entry.Index >= 0 {
+		description = fmt.Sprintf("%d: %s", entry.Index, description)
+	} else {
+		description = "injected: " + description
+	}
+	// Events without a payload, like AccountRecalled, print none.
+	if entry.Event.Payload != nil {
+		payload, err := json.Marshal(entry.Event.Payload)
+		if err != nil {
+			return err
+		}
+		description += " " + string(payload)
+	}
+
+	if entry.Pending {
+		_, err := fmt.Fprintf(w, "%s -> pending\n", description)
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%s -> {Status: %s, Balance: %d}\n", description, entry.Account.Status(), entry.Account.Balance())
+	return err
+}
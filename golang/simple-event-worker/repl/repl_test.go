@@ -0,0 +1,272 @@
+package repl_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/repl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	march1 = time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	april1 = time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func testEvents() []event.Event {
+	return []event.Event{
+		event.NewAccountCreatedEvent("Jack", 50),
+		event.NewChargeEvent("Jack", 25),
+		event.NewPaymentEvent("Jen", 10),
+		event.NewPaymentEvent("Jack", 75),
+	}
+}
+
+func TestSession_Step(t *testing.T) {
+	s := repl.New()
+	s.Load(testEvents())
+
+	entry, err := s.Step()
+	require.NoError(t, err)
+	assert.Equal(t, 0, entry.Index)
+	assert.Equal(t, 50, entry.Account.Balance())
+	entry, err = s.Step()
+	require.NoError(t, err)
+	assert.Equal(t, 1, entry.Index)
+	assert.Equal(t, 75, entry.Account.Balance())
+	assert.Equal(t, 2, s.Remaining())
+
+	// A rejected event is not stepped over.
+	_, err = s.Step()
+	assert.Equal(t, &event.ErrAccountDoesNotExist{AccountID: "Jen"}, err)
+	assert.Equal(t, 2, s.Remaining())
+
+	skipped, err := s.Skip()
+	require.NoError(t, err)
+	assert.Equal(t, event.NewPaymentEvent("Jen", 10), skipped)
+	entry, err = s.Step()
+	require.NoError(t, err)
+	assert.Equal(t, 3, entry.Index)
+	assert.Equal(t, event.AccountStatusSettled, entry.Account.Status())
+
+	_, err = s.Step()
+	assert.ErrorIs(t, err, repl.ErrNoMoreEvents)
+	_, err = s.Skip()
+	assert.ErrorIs(t, err, repl.ErrNoMoreEvents)
+
+	jack, ok := s.Account("Jack")
+	require.True(t, ok)
+	assert.Equal(t, 0, jack.Balance())
+	_, ok = s.Account("Jen")
+	assert.False(t, ok)
+	assert.Equal(t, []int{0, 1, 3}, entryIndexes(s.History("Jack")))
+	assert.Empty(t, s.History("Jen"))
+}
+
+func TestSession_Inject(t *testing.T) {
+	s := repl.New()
+	s.Load(testEvents())
+	for range 2 {
+		_, err := s.Step()
+		require.NoError(t, err)
+	}
+
+	// Injecting the missing account lets the rejected event through.
+	entry, err := s.Inject(event.NewAccountCreatedEvent("Jen", 0))
+	require.NoError(t, err)
+	assert.Equal(t, -1, entry.Index)
+	assert.Equal(t, 2, s.Remaining())
+
+	entry, err = s.Step()
+	require.NoError(t, err)
+	assert.Equal(t, 2, entry.Index)
+	assert.Equal(t, -10, entry.Account.Balance())
+	assert.Equal(t, []int{-1, 2}, entryIndexes(s.History("Jen")))
+
+	_, err = s.Inject(event.NewChargeEvent("Jen", -5))
+	assert.Equal(t, &event.ErrInvalidAmount{AccountID: "Jen", Field: event.EventPayloadFieldAmount, Amount: -5}, err)
+	assert.Len(t, s.History("Jen"), 2)
+}
+
+func TestSession_Undo(t *testing.T) {
+	s := repl.New()
+	s.Load(testEvents())
+	require.ErrorIs(t, s.Undo(), repl.ErrNothingToUndo)
+
+	_, err := s.Step()
+	require.NoError(t, err)
+	_, err = s.Step()
+	require.NoError(t, err)
+	_, err = s.Skip()
+	require.NoError(t, err)
+	_, err = s.Inject(event.NewRecalledEvent("Jack"))
+	require.NoError(t, err)
+
+	// Every step, skip and injected event is undone in turn.
+	require.NoError(t, s.Undo())
+	jack, _ := s.Account("Jack")
+	assert.Equal(t, event.AccountStatusOutstanding, jack.Status())
+	assert.Equal(t, 1, s.Remaining())
+	require.NoError(t, s.Undo())
+	assert.Equal(t, 2, s.Remaining())
+	require.NoError(t, s.Undo())
+	jack, _ = s.Account("Jack")
+	assert.Equal(t, 50, jack.Balance())
+	assert.Equal(t, []int{0}, entryIndexes(s.History("Jack")))
+
+	// A step after an undo takes another path, without the undone history.
+	entry, err := s.Step()
+	require.NoError(t, err)
+	assert.Equal(t, 75, entry.Account.Balance())
+	assert.Equal(t, []int{0, 1}, entryIndexes(s.History("Jack")))
+
+	require.NoError(t, s.Undo())
+	require.NoError(t, s.Undo())
+	assert.ErrorIs(t, s.Undo(), repl.ErrNothingToUndo)
+	assert.Equal(t, 0, s.Accounts().Len())
+}
+
+func TestSession_Pending(t *testing.T) {
+	s := repl.New(repl.WithServiceOptions(event.WithClock(event.ClockFunc(func() time.Time { return march1 }))))
+	s.Load([]event.Event{
+		event.NewAccountCreatedEvent("Jack", 50),
+		event.NewChargeEvent("Jack", 25).EffectiveFrom(april1),
+	})
+
+	_, err := s.Step()
+	require.NoError(t, err)
+	entry, err := s.Step()
+	require.NoError(t, err)
+	assert.True(t, entry.Pending)
+	assert.Equal(t, 50, entry.Account.Balance())
+	assert.Equal(t, []event.Event{event.NewChargeEvent("Jack", 25).EffectiveFrom(april1)}, s.Pending())
+
+	require.NoError(t, s.Undo())
+	assert.Empty(t, s.Pending())
+}
+
+func TestSession_Pending_Due(t *testing.T) {
+	now := march1
+	s := repl.New(repl.WithServiceOptions(event.WithClock(event.ClockFunc(func() time.Time { return now }))))
+	installment := event.NewChargeEvent("Jack", 25).EffectiveFrom(april1)
+	s.Load([]event.Event{
+		event.NewAccountCreatedEvent("Jack", 50),
+		installment,
+		event.NewPaymentEvent("Jack", 5),
+	})
+
+	require.NoError(t, s.Run(strings.NewReader("step 2"), io.Discard))
+
+	// The step after the installment takes effect applies it first, and records it with the state of Jack after it.
+	now = april1
+	var out strings.Builder
+	require.NoError(t, s.Run(strings.NewReader("step\nprint Jack"), &out))
+	assert.Empty(t, s.Pending())
+	history := s.History("Jack")
+	assert.Equal(t, []int{0, 1, 1, 2}, entryIndexes(history))
+	assert.Equal(t, repl.Entry{Index: 1, Event: installment, Account: history[2].Account, Due: true}, history[2])
+	assert.Equal(t, 75, history[2].Account.Balance())
+	assert.Equal(t, 70, history[3].Account.Balance())
+
+	assert.Equal(t, `> 1: due AccountChargeReceived Jack {"Amount":25} -> {Status: Outstanding, Balance: 75}
+2: AccountPaymentReceived Jack {"Amount":5} -> {Status: Outstanding, Balance: 70}
+> Jack: {Status: Outstanding, Balance: 70}
+  0: AccountCreated Jack {"Balance":50} -> {Status: Outstanding, Balance: 50}
+  1: AccountChargeReceived Jack {"Amount":25} -> pending
+  1: due AccountChargeReceived Jack {"Amount":25} -> {Status: Outstanding, Balance: 75}
+  2: AccountPaymentReceived Jack {"Amount":5} -> {Status: Outstanding, Balance: 70}
+> 
+`, out.String())
+
+	// Undoing the step parks the installment again.
+	require.NoError(t, s.Undo())
+	assert.Equal(t, []event.Event{installment}, s.Pending())
+	assert.Equal(t, []int{0, 1}, entryIndexes(s.History("Jack")))
+}
+
+func TestSession_Run(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "events.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[
+		{"Type":"AccountCreated","AccountID":"Jack","Payload":{"Balance":50}},
+		{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":25}},
+		{"Type":"AccountPaymentReceived","AccountID":"Jen","Payload":{"Amount":10}}
+	]`), 0o644))
+
+	commands := strings.Join([]string{
+		"load " + path,
+		"step 2",
+		"run",
+		`inject {"Type":"AccountCreated","AccountID":"Jen","Payload":{"Balance":0}}`,
+		"step",
+		"print Jen",
+		"undo",
+		"accounts",
+		"step 5",
+		"step x",
+		"frobnicate",
+		"quit",
+		"step",
+	}, "\n")
+
+	var out strings.Builder
+	require.NoError(t, repl.New().Run(strings.NewReader(commands), &out))
+	assert.Equal(t, `> loaded 3 events
+> 0: AccountCreated Jack {"Balance":50} -> {Status: Outstanding, Balance: 50}
+1: AccountChargeReceived Jack {"Amount":25} -> {Status: Outstanding, Balance: 75}
+> error: event 2: account with ID does not exist: "Jen"
+> injected: AccountCreated Jen {"Balance":0} -> {Status: Settled, Balance: 0}
+> 2: AccountPaymentReceived Jen {"Amount":10} -> {Status: Overpaid, Balance: -10}
+> Jen: {Status: Overpaid, Balance: -10}
+  injected: AccountCreated Jen {"Balance":0} -> {Status: Settled, Balance: 0}
+  2: AccountPaymentReceived Jen {"Amount":10} -> {Status: Overpaid, Balance: -10}
+> undone, 1 loaded events remaining
+> Jack: {Status: Outstanding, Balance: 75}
+Jen: {Status: Settled, Balance: 0}
+> 2: AccountPaymentReceived Jen {"Amount":10} -> {Status: Overpaid, Balance: -10}
+> error: invalid number of steps: "x"
+> error: unknown command "frobnicate", see help
+> `, out.String())
+}
+
+func TestSession_Run_Errors(t *testing.T) {
+	subtests := []struct {
+		name    string
+		command string
+		want    string
+	}{
+		{name: "NoEventsLoaded", command: "step", want: repl.ErrNoMoreEvents.Error()},
+		{name: "NothingToUndo", command: "undo", want: repl.ErrNothingToUndo.Error()},
+		{name: "MissingPath", command: "load", want: "usage: load <path>"},
+		{name: "LoadFailed", command: "load missing.json", want: "cannot load"},
+		{name: "UnknownAccount", command: "print Jack", want: (&event.ErrAccountDoesNotExist{AccountID: "Jack"}).Error()},
+		{name: "InvalidEvent", command: `inject {"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":-5}}`, want: (&event.ErrInvalidAmount{AccountID: "Jack", Field: event.EventPayloadFieldAmount, Amount: -5}).Error()},
+		{name: "MalformedEvent", command: `inject {"Type":`, want: "invalid character ']' looking for beginning of value"},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := repl.New(repl.WithLoader(func(string) ([]event.Event, error) { return nil, errors.New("cannot load") }))
+
+			var out strings.Builder
+			require.NoError(t, s.Run(strings.NewReader(tt.command), &out))
+			assert.Equal(t, "> error: "+tt.want+"\n> \n", out.String())
+		})
+	}
+}
+
+func entryIndexes(entries []repl.Entry) []int {
+	indexes := []int{}
+	for _, entry := range entries {
+		indexes = append(indexes, entry.Index)
+	}
+
+	return indexes
+}
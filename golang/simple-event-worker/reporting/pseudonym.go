@@ -0,0 +1,70 @@
+package reporting
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"maps"
+	"sync"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+)
+
+// Pseudonymizer replaces account IDs, which may embed the names of customers, with pseudonyms: "acct-" followed by
+// the first 128 bits of the HMAC-SHA256 of the ID keyed by a secret salt, in hex. The same ID always gets the same
+// pseudonym with the same salt, so reports of several runs can be compared, while the ID cannot be guessed from
+// its pseudonym without the salt.
+//
+// It remembers the IDs it pseudonymized, so the mapping back to them can be kept for authorized use. It is safe for
+// concurrent use, e.g. by the logs of several services.
+type Pseudonymizer struct {
+	salt []byte
+
+	mu  sync.Mutex
+	ids map[string]string
+}
+
+func NewPseudonymizer(salt []byte) *Pseudonymizer {
+	return &Pseudonymizer{salt: salt, ids: map[string]string{}}
+}
+
+// Pseudonym returns the pseudonym of an account ID.
+func (p *Pseudonymizer) Pseudonym(accountID string) string {
+	mac := hmac.New(sha256.New, p.salt)
+	mac.Write([]byte(accountID))
+	pseudonym := "acct-" + hex.EncodeToString(mac.Sum(nil)[:16])
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ids[pseudonym] = accountID
+
+	return pseudonym
+}
+
+// Accounts returns `accounts` keyed by the pseudonyms of their keys, e.g. to build a Report of them.
+func (p *Pseudonymizer) Accounts(accounts map[string]event.Account) map[string]event.Account {
+	pseudonymized := make(map[string]event.Account, len(accounts))
+	for id, account := range accounts {
+		pseudonymized[p.Pseudonym(id)] = account
+	}
+
+	return pseudonymized
+}
+
+// Mapping returns the account IDs pseudonymized so far, by pseudonym.
+func (p *Pseudonymizer) Mapping() map[string]string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return maps.Clone(p.ids)
+}
+
+// WriteMapping writes the Mapping as a JSON object, whose keys are sorted.
+func (p *Pseudonymizer) WriteMapping(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(p.Mapping())
+}
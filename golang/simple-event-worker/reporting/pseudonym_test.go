@@ -0,0 +1,56 @@
+package reporting_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/reporting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPseudonymizer_Pseudonym(t *testing.T) {
+	p := reporting.NewPseudonymizer([]byte("s3cret"))
+
+	jack := p.Pseudonym("1-Jack")
+	assert.Regexp(t, `^acct-[0-9a-f]{32}$`, jack)
+	assert.NotContains(t, jack, "Jack")
+
+	// Pseudonyms are stable for a salt, and differ across IDs and salts.
+	assert.Equal(t, jack, p.Pseudonym("1-Jack"))
+	assert.NotEqual(t, jack, p.Pseudonym("2-Jen"))
+	assert.NotEqual(t, jack, reporting.NewPseudonymizer([]byte("other")).Pseudonym("1-Jack"))
+
+	assert.Equal(t, map[string]string{jack: "1-Jack", p.Pseudonym("2-Jen"): "2-Jen"}, p.Mapping())
+}
+
+func TestPseudonymizer_Report(t *testing.T) {
+	events := testEvents()
+	accounts, err := event.NewService().ProcessEvents(events)
+	require.NoError(t, err)
+
+	p := reporting.NewPseudonymizer([]byte("s3cret"))
+	report := reporting.New(p.Accounts(accounts), events, 5)
+
+	var text bytes.Buffer
+	require.NoError(t, report.Write(&text, reporting.FormatText))
+	for id := range accounts {
+		assert.NotContains(t, text.String(), id)
+	}
+
+	// The mapping tells the accounts of the report apart, and the totals are unchanged.
+	var mapping map[string]string
+	var buf bytes.Buffer
+	require.NoError(t, p.WriteMapping(&buf))
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &mapping))
+	require.Len(t, mapping, len(accounts))
+	for _, a := range report.TopAccounts {
+		require.True(t, strings.HasPrefix(a.AccountID, "acct-"))
+		account := accounts[mapping[a.AccountID]]
+		assert.Equal(t, account.Balance(), a.Balance)
+	}
+	assert.Equal(t, testReport(t, 5).Statuses, report.Statuses)
+}
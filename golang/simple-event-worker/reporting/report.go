@@ -0,0 +1,190 @@
+// Package reporting summarizes the final state of processed accounts and the events that produced it.
+package reporting
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// statuses fixes the order in which statuses are reported.
+var statuses = []string{
+	event.AccountStatusOutstanding,
+	event.AccountStatusSettled,
+	event.AccountStatusOverpaid,
+	event.AccountStatusRecalled,
+	event.AccountStatusWrittenOff,
+}
+
+type Report struct {
+	Statuses      []StatusSummary  `json:"Statuses"`
+	TopAccounts   []AccountSummary `json:"TopAccounts"`
+	TotalCharges  int              `json:"TotalCharges"`
+	TotalPayments int              `json:"TotalPayments"`
+	// TotalWrittenOff and TotalRecovered are the debt written off the accounts, and the part of it recovered so far.
+	// Unlike the transactions, they are totals of the accounts rather than of the events.
+	TotalWrittenOff int              `json:"TotalWrittenOff"`
+	TotalRecovered  int              `json:"TotalRecovered"`
+	EventTypes      []EventTypeCount `json:"EventTypes"`
+}
+
+type StatusSummary struct {
+	Status       string `json:"Status"`
+	Count        int    `json:"Count"`
+	TotalBalance int    `json:"TotalBalance"`
+}
+
+type AccountSummary struct {
+	AccountID string `json:"AccountID"`
+	Status    string `json:"Status"`
+	Balance   int    `json:"Balance"`
+}
+
+type EventTypeCount struct {
+	Type  string `json:"Type"`
+	Count int    `json:"Count"`
+}
+
+// New builds a report from the accounts returned by ProcessEvents and the events that were processed.
+// Only the `topN` accounts with the highest balance are listed, ties broken by AccountID.
+func New(accounts map[string]event.Account, events []event.Event, topN int) *Report {
+	r := &Report{
+		Statuses:    make([]StatusSummary, 0, len(statuses)),
+		TopAccounts: []AccountSummary{},
+		EventTypes:  []EventTypeCount{},
+	}
+
+	byStatus := map[string]int{}
+	for _, status := range statuses {
+		byStatus[status] = len(r.Statuses)
+		r.Statuses = append(r.Statuses, StatusSummary{Status: status})
+	}
+
+	all := make([]AccountSummary, 0, len(accounts))
+	for id, account := range accounts {
+		i, ok := byStatus[account.Status()]
+		if !ok {
+			i = len(r.Statuses)
+			byStatus[account.Status()] = i
+			r.Statuses = append(r.Statuses, StatusSummary{Status: account.Status()})
+		}
+		r.Statuses[i].Count++
+		r.Statuses[i].TotalBalance += account.Balance()
+		r.TotalWrittenOff += account.WrittenOff()
+		r.TotalRecovered += account.Recovered()
+
+		all = append(all, AccountSummary{AccountID: id, Status: account.Status(), Balance: account.Balance()})
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Balance != all[j].Balance {
+			return all[i].Balance > all[j].Balance
+		}
+		return all[i].AccountID < all[j].AccountID
+	})
+	if topN < len(all) {
+		all = all[:max(topN, 0)]
+	}
+	r.TopAccounts = append(r.TopAccounts, all...)
+
+	counts := map[string]int{}
+	for _, e := range events {
+		counts[e.Type]++
+
+		payload, ok := e.Payload.(*event.EventPayloadAccountTransactionReceived)
+		if !ok {
+			continue
+		}
+		switch e.Type {
+		case event.EventTypeAccountChargeReceived:
+			r.TotalCharges += payload.Amount
+		case event.EventTypeAccountPaymentReceived:
+			r.TotalPayments += payload.Amount
+		}
+	}
+
+	for eventType, count := range counts {
+		r.EventTypes = append(r.EventTypes, EventTypeCount{Type: eventType, Count: count})
+	}
+	sort.Slice(r.EventTypes, func(i, j int) bool {
+		return r.EventTypes[i].Type < r.EventTypes[j].Type
+	})
+
+	return r
+}
+
+// Write writes the report to w in the given format, either FormatText or FormatJSON.
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatText:
+		return r.WriteText(w)
+	case FormatJSON:
+		return r.WriteJSON(w)
+	default:
+		return &ErrUnsupportedFormat{Format: format}
+	}
+}
+
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+func (r *Report) WriteText(w io.Writer) error {
+	ew := &errWriter{w: w}
+
+	ew.printf("Accounts by status:\n")
+	for _, s := range r.Statuses {
+		ew.printf("  %s: {Count: %d, Total Balance: %d}\n", s.Status, s.Count, s.TotalBalance)
+	}
+
+	ew.printf("Top %d accounts by balance:\n", len(r.TopAccounts))
+	for i, a := range r.TopAccounts {
+		ew.printf("  %d. %s: {Status: %s, Balance: %d}\n", i+1, a.AccountID, a.Status, a.Balance)
+	}
+
+	ew.printf("Transactions:\n")
+	ew.printf("  Charges: %d\n", r.TotalCharges)
+	ew.printf("  Payments: %d\n", r.TotalPayments)
+
+	ew.printf("Write-offs:\n")
+	ew.printf("  Written off: %d\n", r.TotalWrittenOff)
+	ew.printf("  Recovered: %d\n", r.TotalRecovered)
+
+	ew.printf("Events by type:\n")
+	for _, e := range r.EventTypes {
+		ew.printf("  %s: %d\n", e.Type, e.Count)
+	}
+
+	return ew.err
+}
+
+// errWriter keeps the first write error so WriteText does not need to check every line.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...any) {
+	if ew.err != nil {
+		return
+	}
+	_, ew.err = fmt.Fprintf(ew.w, format, args...)
+}
+
+type ErrUnsupportedFormat struct {
+	Format string
+}
+
+func (e *ErrUnsupportedFormat) Error() string {
+	return fmt.Sprintf(`unsupported report format: "%s"`, e.Format)
+}
@@ -0,0 +1,175 @@
+package reporting_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/reporting"
+	"github.com/stretchr/testify/assert"
+)
+
+func testEvents() []event.Event {
+	return []event.Event{
+		{Type: event.EventTypeAccountCreated, AccountID: "Jack", Payload: &event.EventPayloadAccountCreated{Balance: 50}},
+		{Type: event.EventTypeAccountCreated, AccountID: "Jen", Payload: &event.EventPayloadAccountCreated{Balance: 100}},
+		{Type: event.EventTypeAccountCreated, AccountID: "Robert", Payload: &event.EventPayloadAccountCreated{Balance: 100}},
+		{Type: event.EventTypeAccountCreated, AccountID: "Olivia", Payload: &event.EventPayloadAccountCreated{Balance: 50}},
+		{Type: event.EventTypeAccountChargeReceived, AccountID: "Jack", Payload: &event.EventPayloadAccountTransactionReceived{Amount: 25}},
+		{Type: event.EventTypeAccountPaymentReceived, AccountID: "Jack", Payload: &event.EventPayloadAccountTransactionReceived{Amount: 75}},
+		{Type: event.EventTypeAccountPaymentReceived, AccountID: "Jen", Payload: &event.EventPayloadAccountTransactionReceived{Amount: 110}},
+		{Type: event.EventTypeAccountChargeReceived, AccountID: "Robert", Payload: &event.EventPayloadAccountTransactionReceived{Amount: 25}},
+		{Type: event.EventTypeAccountRecalled, AccountID: "Olivia", Payload: nil},
+		event.NewAccountCreatedEvent("Lucy", 80),
+		event.NewRecalledEvent("Lucy"),
+		event.NewWrittenOffEvent("Lucy"),
+		event.NewRecoveryEvent("Lucy", 20),
+	}
+}
+
+func testReport(t *testing.T, topN int) *reporting.Report {
+	t.Helper()
+
+	events := testEvents()
+	accounts, err := event.NewService().ProcessEvents(events)
+	assert.NoError(t, err)
+
+	return reporting.New(accounts, events, topN)
+}
+
+func TestReport_New(t *testing.T) {
+	subtests := []struct {
+		name string
+		topN int
+		want *reporting.Report
+	}{
+		{
+			name: "TopTwo",
+			topN: 2,
+			want: &reporting.Report{
+				Statuses: []reporting.StatusSummary{
+					{Status: event.AccountStatusOutstanding, Count: 1, TotalBalance: 125},
+					{Status: event.AccountStatusSettled, Count: 1, TotalBalance: 0},
+					{Status: event.AccountStatusOverpaid, Count: 1, TotalBalance: -10},
+					{Status: event.AccountStatusRecalled, Count: 1, TotalBalance: 50},
+					{Status: event.AccountStatusWrittenOff, Count: 1, TotalBalance: 0},
+				},
+				TopAccounts: []reporting.AccountSummary{
+					{AccountID: "Robert", Status: event.AccountStatusOutstanding, Balance: 125},
+					{AccountID: "Olivia", Status: event.AccountStatusRecalled, Balance: 50},
+				},
+				TotalCharges:    50,
+				TotalPayments:   185,
+				TotalWrittenOff: 80,
+				TotalRecovered:  20,
+				EventTypes: []reporting.EventTypeCount{
+					{Type: event.EventTypeAccountChargeReceived, Count: 2},
+					{Type: event.EventTypeAccountCreated, Count: 5},
+					{Type: event.EventTypeAccountPaymentReceived, Count: 2},
+					{Type: event.EventTypeAccountRecalled, Count: 2},
+					{Type: event.EventTypeAccountRecoveryReceived, Count: 1},
+					{Type: event.EventTypeAccountWrittenOff, Count: 1},
+				},
+			},
+		},
+		{
+			name: "TopNLargerThanAccounts",
+			topN: 10,
+			want: &reporting.Report{
+				Statuses: []reporting.StatusSummary{
+					{Status: event.AccountStatusOutstanding, Count: 1, TotalBalance: 125},
+					{Status: event.AccountStatusSettled, Count: 1, TotalBalance: 0},
+					{Status: event.AccountStatusOverpaid, Count: 1, TotalBalance: -10},
+					{Status: event.AccountStatusRecalled, Count: 1, TotalBalance: 50},
+					{Status: event.AccountStatusWrittenOff, Count: 1, TotalBalance: 0},
+				},
+				TopAccounts: []reporting.AccountSummary{
+					{AccountID: "Robert", Status: event.AccountStatusOutstanding, Balance: 125},
+					{AccountID: "Olivia", Status: event.AccountStatusRecalled, Balance: 50},
+					{AccountID: "Jack", Status: event.AccountStatusSettled, Balance: 0},
+					{AccountID: "Lucy", Status: event.AccountStatusWrittenOff, Balance: 0},
+					{AccountID: "Jen", Status: event.AccountStatusOverpaid, Balance: -10},
+				},
+				TotalCharges:    50,
+				TotalPayments:   185,
+				TotalWrittenOff: 80,
+				TotalRecovered:  20,
+				EventTypes: []reporting.EventTypeCount{
+					{Type: event.EventTypeAccountChargeReceived, Count: 2},
+					{Type: event.EventTypeAccountCreated, Count: 5},
+					{Type: event.EventTypeAccountPaymentReceived, Count: 2},
+					{Type: event.EventTypeAccountRecalled, Count: 2},
+					{Type: event.EventTypeAccountRecoveryReceived, Count: 1},
+					{Type: event.EventTypeAccountWrittenOff, Count: 1},
+				},
+			},
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, testReport(t, tt.topN))
+		})
+	}
+}
+
+func TestReport_New_NoEvents(t *testing.T) {
+	got := reporting.New(map[string]event.Account{}, []event.Event{}, 5)
+
+	assert.Len(t, got.Statuses, 5)
+	for _, s := range got.Statuses {
+		assert.Zero(t, s.Count)
+		assert.Zero(t, s.TotalBalance)
+	}
+	assert.Empty(t, got.TopAccounts)
+	assert.Zero(t, got.TotalWrittenOff)
+	assert.Zero(t, got.TotalRecovered)
+	assert.Empty(t, got.EventTypes)
+}
+
+func TestReport_Write(t *testing.T) {
+	report := testReport(t, 1)
+
+	t.Run("Text", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, report.Write(&buf, reporting.FormatText))
+		assert.Equal(t, `Accounts by status:
+  Outstanding: {Count: 1, Total Balance: 125}
+  Settled: {Count: 1, Total Balance: 0}
+  Overpaid: {Count: 1, Total Balance: -10}
+  Recalled: {Count: 1, Total Balance: 50}
+  WrittenOff: {Count: 1, Total Balance: 0}
+Top 1 accounts by balance:
+  1. Robert: {Status: Outstanding, Balance: 125}
+Transactions:
+  Charges: 50
+  Payments: 185
+Write-offs:
+  Written off: 80
+  Recovered: 20
+Events by type:
+  AccountChargeReceived: 2
+  AccountCreated: 5
+  AccountPaymentReceived: 2
+  AccountRecalled: 2
+  AccountRecoveryReceived: 1
+  AccountWrittenOff: 1
+`, buf.String())
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, report.Write(&buf, reporting.FormatJSON))
+
+		got := &reporting.Report{}
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), got))
+		assert.Equal(t, report, got)
+	})
+
+	t.Run("ErrUnsupportedFormat", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := report.Write(&buf, "xml")
+		assert.EqualError(t, err, (&reporting.ErrUnsupportedFormat{Format: "xml"}).Error())
+	})
+}
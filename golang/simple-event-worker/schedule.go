@@ -0,0 +1,104 @@
+package simpleeventworker
+
+import (
+	"slices"
+	"time"
+)
+
+// Clock tells a service the time to apply events as of. Events effective after it are parked until it advances.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc adapts a function to a Clock, e.g. `ClockFunc(func() time.Time { return asOf })` for a fixed time.
+type ClockFunc func() time.Time
+
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// SystemClock is the wall clock, the default Clock of a service.
+var SystemClock Clock = ClockFunc(time.Now)
+
+// WithClock sets the clock events are applied as of. By default, it is SystemClock.
+func WithClock(clock Clock) ServiceOption {
+	return func(s *EventService) {
+		s.clock = clock
+	}
+}
+
+// PendingQueue holds the events a service parked because they take effect after its clock, in the order they take
+// effect, and keeps the events parked by one batch for the next ones. A service has no queue unless it is given one
+// with WithPendingQueue, so it keeps no state across batches.
+//
+// A queue is not safe for concurrent use: a batch reads the due events from it and replaces them with the ones still
+// pending once it is applied, so the batches of the services sharing a queue must not overlap.
+type PendingQueue struct {
+	events []Event
+}
+
+// NewPendingQueue returns a queue holding `events`, e.g. the pending events recorded in a snapshot.
+func NewPendingQueue(events ...Event) *PendingQueue {
+	q := &PendingQueue{}
+	q.set(slices.Clone(events))
+
+	return q
+}
+
+// WithPendingQueue parks the future-dated events of every batch applied successfully in `queue`, and applies the events
+// of `queue` once they are due. A failing batch leaves the queue untouched, like the accounts, and so does a batch of
+// ProcessSource until its source committed it. Every tenant needs its own queue.
+func WithPendingQueue(queue *PendingQueue) ServiceOption {
+	return func(s *EventService) {
+		s.pending = queue
+	}
+}
+
+// Len returns the number of pending events.
+func (q *PendingQueue) Len() int {
+	return len(q.events)
+}
+
+// Events returns the pending events, in the order they take effect. Events taking effect at the same time are in the
+// order they were parked.
+func (q *PendingQueue) Events() []Event {
+	return slices.Clone(q.events)
+}
+
+// due splits the pending events into the ones effective at `now` and the ones still pending.
+func (q *PendingQueue) due(now time.Time) ([]Event, []Event) {
+	i := len(q.events)
+	for j, e := range q.events {
+		if e.EffectiveAt.After(now) {
+			i = j
+			break
+		}
+	}
+
+	return q.events[:i:i], q.events[i:]
+}
+
+// set replaces the pending events, keeping them sorted by EffectiveAt.
+func (q *PendingQueue) set(events []Event) {
+	slices.SortStableFunc(events, func(a, b Event) int {
+		return a.EffectiveAt.Compare(b.EffectiveAt)
+	})
+	q.events = events
+}
+
+// Pending returns the events the service parked until they take effect, in the order they take effect, or nil if it
+// has no pending queue.
+func (s *EventService) Pending() []Event {
+	if s.pending == nil {
+		return nil
+	}
+
+	return s.pending.Events()
+}
+
+// EffectiveFrom returns a copy of the event scheduled to take effect at `effectiveAt`,
+// e.g. `NewChargeEvent("Jack", 25).EffectiveFrom(nextMonth)`.
+func (e Event) EffectiveFrom(effectiveAt time.Time) Event {
+	e.EffectiveAt = effectiveAt
+	return e
+}
@@ -0,0 +1,258 @@
+package simpleeventworker_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a Clock tests advance by hand.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func TestSchedule_ApplyEvents(t *testing.T) {
+	clock := &fakeClock{now: march1.AddDate(0, 0, 14)}
+	s := event.NewService(event.WithClock(clock), event.WithPendingQueue(event.NewPendingQueue()))
+
+	installment := event.NewChargeEvent("Jack", 25).ForInvoice("INV-2").EffectiveFrom(april1.AddDate(0, 1, 0))
+	accounts, err := s.ProcessEvents([]event.Event{
+		event.NewAccountCreatedEvent("Jack", 50),
+		installment,
+		event.NewChargeEvent("Jack", 10).ForInvoice("INV-1").EffectiveFrom(april1),
+		// Events effective by now are applied on arrival.
+		event.NewPaymentEvent("Jack", 20).EffectiveFrom(march1),
+	})
+	require.NoError(t, err)
+	jack := accounts["Jack"]
+	assert.Equal(t, 30, jack.Balance())
+	// Pending events are in the order they take effect.
+	assert.Equal(t, []event.Event{
+		event.NewChargeEvent("Jack", 10).ForInvoice("INV-1").EffectiveFrom(april1),
+		installment,
+	}, s.Pending())
+
+	// Events become due once the clock reaches their EffectiveAt.
+	clock.now = april1
+	accounts, err = s.ApplyEvents(accounts, []event.Event{event.NewPaymentEvent("Jack", 5)})
+	require.NoError(t, err)
+	jack = accounts["Jack"]
+	assert.Equal(t, 35, jack.Balance())
+	assert.Equal(t, []event.OpenItem{{Amount: 50, Remaining: 25}, {Invoice: "INV-1", Amount: 10, Remaining: 10}}, jack.OpenItems())
+	assert.Equal(t, []event.Event{installment}, s.Pending())
+
+	clock.now = april1.AddDate(1, 0, 0)
+	accounts, err = s.ApplyEvents(accounts, nil)
+	require.NoError(t, err)
+	jack = accounts["Jack"]
+	assert.Equal(t, 60, jack.Balance())
+	assert.Empty(t, s.Pending())
+}
+
+func TestSchedule_ApplyEvents_FailingBatch(t *testing.T) {
+	clock := &fakeClock{now: march1}
+	queue := event.NewPendingQueue(event.NewChargeEvent("Jack", 25).EffectiveFrom(april1))
+	s := event.NewService(event.WithClock(clock), event.WithPendingQueue(queue))
+	initial, err := s.ProcessEvents([]event.Event{event.NewAccountCreatedEvent("Jack", 50)})
+	require.NoError(t, err)
+
+	// A failing batch neither applies the due events nor parks the new ones.
+	clock.now = april1
+	_, err = s.ApplyEvents(initial, []event.Event{
+		event.NewChargeEvent("Jack", 5).EffectiveFrom(april1.AddDate(0, 1, 0)),
+		event.NewPaymentEvent("Jen", 5),
+	})
+	assert.Equal(t, &event.ErrAccountDoesNotExist{AccountID: "Jen"}, err)
+	assert.Equal(t, []event.Event{event.NewChargeEvent("Jack", 25).EffectiveFrom(april1)}, queue.Events())
+}
+
+func TestSchedule_ProcessSource_CommitFailure(t *testing.T) {
+	clock := &fakeClock{now: march1}
+	due := event.NewPaymentEvent("Jack", 5).EffectiveFrom(march1)
+	queue := event.NewPendingQueue(due)
+	s := event.NewService(event.WithClock(clock), event.WithPendingQueue(queue))
+	initial := map[string]event.Account{"Jack": *event.NewAccount("Jack", 50)}
+	installment := event.NewChargeEvent("Jack", 25).EffectiveFrom(april1)
+
+	// A batch that is not committed leaves the queue as it was, since it is delivered again.
+	_, err := s.ProcessSource(context.Background(), initial, &fakeSource{batches: [][]event.Event{{installment}}, commitErr: errors.New("broker unavailable")})
+	assert.EqualError(t, err, "broker unavailable")
+	assert.Equal(t, []event.Event{due}, queue.Events())
+
+	// Once it is, the due event is applied, and the installment parked once.
+	accounts, err := s.ProcessSource(context.Background(), initial, &fakeSource{batches: [][]event.Event{{installment}}})
+	require.NoError(t, err)
+	jack := accounts["Jack"]
+	assert.Equal(t, 45, jack.Balance())
+	assert.Equal(t, []event.Event{installment}, queue.Events())
+}
+
+func TestSchedule_ApplyEvents_CustomErrors(t *testing.T) {
+	clock := &fakeClock{now: march1}
+
+	subtests := []struct {
+		name    string
+		pending []event.Event
+		events  []event.Event
+		want    error
+	}{
+		{
+			// A scheduled event is checked when it is parked, so an event that can never apply fails right away.
+			name:   "ErrInvalidAmount",
+			events: []event.Event{event.NewChargeEvent("Jack", -25).EffectiveFrom(april1)},
+			want:   &event.ErrInvalidAmount{AccountID: "Jack", Field: event.EventPayloadFieldAmount, Amount: -25},
+		},
+		{
+			name:   "ErrTenantMismatch",
+			events: []event.Event{event.NewChargeEvent("Jack", 25).InTenant("acme").EffectiveFrom(april1)},
+			want:   &event.ErrTenantMismatch{EventTenantID: "acme", AccountID: "Jack"},
+		},
+		{
+			// The state of the account is only checked once the event is due, e.g. a charge of an account recalled meanwhile.
+			name:    "ErrCannotTransactWithRecalledAccount",
+			pending: []event.Event{event.NewChargeEvent("Jack", 25).EffectiveFrom(march1)},
+			events:  []event.Event{event.NewAccountCreatedEvent("Jen", 0)},
+			want:    &event.ErrCannotTransactWithRecalledAccount{AccountID: "Jack"},
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			jack := event.NewAccount("Jack", 50)
+			jack.Recall()
+			s := event.NewService(event.WithClock(clock), event.WithPendingQueue(event.NewPendingQueue(tt.pending...)))
+
+			_, err := s.ApplyEvents(map[string]event.Account{"Jack": *jack}, tt.events)
+			assert.Equal(t, tt.want, err)
+			assert.Equal(t, len(tt.pending), len(s.Pending()))
+		})
+	}
+}
+
+func TestSchedule_NoPendingQueue(t *testing.T) {
+	clock := &fakeClock{now: march1}
+	s := event.NewService(event.WithClock(clock))
+	events := []event.Event{
+		event.NewAccountCreatedEvent("Jack", 50),
+		event.NewChargeEvent("Jack", 25).EffectiveFrom(march1),
+	}
+
+	// Without a queue, the service keeps no state across batches, so it can be reused, also concurrently.
+	want, err := s.ProcessEvents(events)
+	require.NoError(t, err)
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := s.ProcessEvents(events)
+			assert.NoError(t, err)
+			assert.Equal(t, want, got)
+		}()
+	}
+	wg.Wait()
+
+	// An event taking effect later has nowhere to be parked.
+	_, err = s.ProcessEvents(append(events, event.NewChargeEvent("Jack", 10).EffectiveFrom(april1)))
+	assert.Equal(t, &event.ErrNoPendingQueue{AccountID: "Jack", EffectiveAt: april1}, err)
+	assert.Nil(t, s.Pending())
+}
+
+func TestSchedule_DeadLetters(t *testing.T) {
+	clock := &fakeClock{now: april1}
+	sink := &fakeDeadLetterSink{}
+	outbox := &fakeOutbox{}
+	s := event.NewService(
+		event.WithClock(clock),
+		event.WithPendingQueue(event.NewPendingQueue(event.NewPaymentEvent("Jack", 25).EffectiveFrom(march1), event.NewPaymentEvent("Jen", 50).EffectiveFrom(march1))),
+		event.WithDeadLetterSink(sink),
+		event.WithOutbox(outbox),
+	)
+
+	_, err := s.ApplyEvents(map[string]event.Account{"Jen": *event.NewAccount("Jen", 50)}, []event.Event{event.NewRecalledEvent("Jen")})
+	require.NoError(t, err)
+
+	// The due events come first in the batch, so indexes count them.
+	require.Len(t, sink.quarantined, 1)
+	require.Len(t, sink.quarantined[0], 1)
+	assert.Equal(t, 0, sink.quarantined[0][0].Index)
+	assert.Equal(t, (&event.ErrAccountDoesNotExist{AccountID: "Jack"}).Error(), sink.quarantined[0][0].Reason)
+	require.Len(t, outbox.published, 1)
+	assert.Equal(t, []int{1, 2}, []int{outbox.published[0][0].EventIndex, outbox.published[0][1].EventIndex})
+	assert.Empty(t, s.Pending())
+}
+
+func TestSchedule_ApplyBatch(t *testing.T) {
+	clock := &fakeClock{now: april1}
+	due := event.NewPaymentEvent("Jen", 5).EffectiveFrom(march1)
+	s := event.NewService(
+		event.WithClock(clock),
+		event.WithPendingQueue(event.NewPendingQueue(due)),
+		event.WithDeadLetterSink(&fakeDeadLetterSink{}),
+	)
+	recalled := event.NewRecalledEvent("Jen")
+	installment := event.NewChargeEvent("Jen", 25).EffectiveFrom(april1.AddDate(0, 1, 0))
+
+	b, err := s.ApplyBatch(map[string]event.Account{"Jen": *event.NewAccount("Jen", 50)}, []event.Event{
+		recalled,
+		event.NewPaymentEvent("Jack", 700),
+		installment,
+	})
+	require.NoError(t, err)
+
+	// The due event is applied first, while the quarantined and parked ones are not applied.
+	assert.Equal(t, []event.Event{due, recalled}, b.Applied)
+	jen := b.Accounts["Jen"]
+	assert.Equal(t, 45, jen.Balance())
+	assert.Equal(t, []event.Event{installment}, s.Pending())
+}
+
+func TestSchedule_HashChain(t *testing.T) {
+	clock := &fakeClock{now: march1}
+	chain := event.NewHashChain()
+	s := event.NewService(event.WithClock(clock), event.WithPendingQueue(event.NewPendingQueue()), event.WithHashChain(chain))
+	events := []event.Event{
+		event.NewAccountCreatedEvent("Jack", 50),
+		event.NewChargeEvent("Jack", 25).EffectiveFrom(april1),
+	}
+
+	accounts, err := s.ProcessEvents(events)
+	require.NoError(t, err)
+
+	// The chain records the events as they were fed, not when they take effect, so it does not depend on the clock.
+	clock.now = april1
+	_, err = s.ApplyEvents(accounts, nil)
+	require.NoError(t, err)
+	assert.Equal(t, chainOf(events...).Head(), chain.Head())
+}
+
+func TestSchedule_ParseEvents(t *testing.T) {
+	input := `[{"Type":"AccountChargeReceived","AccountID":"Jack","Timestamp":"2026-03-01T00:00:00Z","EffectiveAt":"2026-04-01T00:00:00Z","Payload":{"Amount":25}}]`
+	want := []event.Event{event.NewChargeEvent("Jack", 25).At(march1).EffectiveFrom(april1)}
+
+	for _, fast := range []bool{false, true} {
+		events, err := event.NewService(event.WithFastDecoder(fast)).ParseEvents(strings.NewReader(input))
+		require.NoError(t, err, "fast: %v", fast)
+		assert.Equal(t, want, events, "fast: %v", fast)
+
+		_, err = event.NewService(event.WithFastDecoder(fast)).ParseEvents(strings.NewReader(`[{"Type":"AccountRecalled","AccountID":"Jack","EffectiveAt":"next month"}]`))
+		var effectiveAtErr *event.ErrInvalidEffectiveAt
+		assert.ErrorAs(t, err, &effectiveAtErr, "fast: %v", fast)
+	}
+
+	data, err := json.Marshal(want[0])
+	require.NoError(t, err)
+	assert.JSONEq(t, strings.Trim(input, "[]"), string(data))
+}
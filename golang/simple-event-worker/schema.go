@@ -0,0 +1,174 @@
+package simpleeventworker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// EventTypeChargePosted is the second generation of `AccountChargeReceived`, at version 2,
+// with a `Reference` in its payload besides the `Amount`.
+const EventTypeChargePosted = "ChargePosted"
+
+// Upcaster converts the events of an alias of an event type, or of another version of it, to a canonical event type
+// before the service sees them. The canonical event types are the ones processed by the service, at version 1.
+type Upcaster struct {
+	// Type and Version identify the events to upcast. An event without a Version is at version 1.
+	Type    string `json:"Type"`
+	Version int    `json:"Version"`
+	// To is the canonical event type the events are upcast to.
+	To string `json:"To"`
+	// Rename maps payload fields of the events to the fields of the canonical payload.
+	Rename map[string]string `json:"Rename,omitempty"`
+	// Drop lists the payload fields of the events without a counterpart in the canonical payload.
+	// Any other field is decoded as a field of the canonical payload, so strict mode still rejects unknown ones.
+	Drop []string `json:"Drop,omitempty"`
+}
+
+// upcastPayload renames and drops the fields of a payload object. Any other payload is left as it is,
+// so the canonical payload rejects it the way it rejects its own.
+func (u Upcaster) upcastPayload(payload []byte) []byte {
+	if len(u.Rename) == 0 && len(u.Drop) == 0 {
+		return payload
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return payload
+	}
+
+	buf := []byte{'{'}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return payload
+		}
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return payload
+		}
+
+		name := token.(string)
+		if slices.Contains(u.Drop, name) {
+			continue
+		}
+		if to, ok := u.Rename[name]; ok {
+			name = to
+		}
+
+		key, err := json.Marshal(name)
+		if err != nil {
+			return payload
+		}
+		if len(buf) > 1 {
+			buf = append(buf, ',')
+		}
+		buf = append(append(append(buf, key...), ':'), value...)
+	}
+
+	return append(buf, '}')
+}
+
+type schemaKey struct {
+	typ     string
+	version int
+}
+
+// Schema maps aliases and other versions of event types to the canonical event types.
+type Schema struct {
+	upcasters map[schemaKey]Upcaster
+	// types holds the types with an upcaster, whose other versions are unsupported versions rather than unsupported types.
+	types map[string]bool
+}
+
+// NewSchema builds a schema of `upcasters`. An upcaster is rejected with ErrInvalidUpcaster if it does not upcast to
+// a canonical event type, if its version is not positive, or if it would replace a canonical event type or
+// another upcaster.
+func NewSchema(upcasters ...Upcaster) (*Schema, error) {
+	s := &Schema{
+		upcasters: make(map[schemaKey]Upcaster, len(upcasters)),
+		types:     make(map[string]bool, len(upcasters)),
+	}
+
+	for _, u := range upcasters {
+		key := schemaKey{typ: u.Type, version: u.Version}
+		switch {
+		case !isCanonicalEventType(u.To):
+			return nil, &ErrInvalidUpcaster{Type: u.Type, Version: u.Version, Reason: fmt.Sprintf(`"%s" is not a canonical event type`, u.To)}
+		case u.Version < 1:
+			return nil, &ErrInvalidUpcaster{Type: u.Type, Version: u.Version, Reason: "version must be positive"}
+		case isCanonicalEventType(u.Type) && u.Version == 1:
+			return nil, &ErrInvalidUpcaster{Type: u.Type, Version: u.Version, Reason: "canonical event types cannot be upcast"}
+		}
+		if _, ok := s.upcasters[key]; ok {
+			return nil, &ErrInvalidUpcaster{Type: u.Type, Version: u.Version, Reason: "duplicate upcaster"}
+		}
+
+		s.upcasters[key] = u
+		s.types[u.Type] = true
+	}
+
+	return s, nil
+}
+
+// defaultSchema is built once, since Event.UnmarshalJSON uses it too.
+var defaultSchema = func() *Schema {
+	s, err := NewSchema(Upcaster{
+		Type:    EventTypeChargePosted,
+		Version: 2,
+		To:      EventTypeAccountChargeReceived,
+		Rename:  map[string]string{"Reference": EventPayloadFieldInvoice},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return s
+}()
+
+// DefaultSchema accepts `ChargePosted` events at version 2 as `AccountChargeReceived` events, whose `Reference` is the
+// `Invoice` they bill.
+func DefaultSchema() *Schema {
+	return defaultSchema
+}
+
+// WithSchema sets the schema ParseEvents upcasts events with. By default, it is DefaultSchema.
+func WithSchema(schema *Schema) ServiceOption {
+	return func(s *EventService) {
+		if schema == nil {
+			schema = defaultSchema
+		}
+		s.schema = schema
+	}
+}
+
+// resolve returns the upcaster of the events of type `typ` at `version`, if they need one.
+// Events of a canonical event type at version 1, and of unknown types, need none.
+func (s *Schema) resolve(typ string, version int) (Upcaster, bool, error) {
+	if version == 0 {
+		version = 1
+	}
+
+	if u, ok := s.upcasters[schemaKey{typ: typ, version: version}]; ok {
+		return u, true, nil
+	}
+	if isCanonicalEventType(typ) && version == 1 {
+		return Upcaster{}, false, nil
+	}
+	if isCanonicalEventType(typ) || s.types[typ] {
+		return Upcaster{}, false, &ErrUnsupportedEventVersion{Type: typ, Version: version}
+	}
+
+	return Upcaster{}, false, nil
+}
+
+func isCanonicalEventType(typ string) bool {
+	switch typ {
+	case EventTypeAccountCreated, EventTypeAccountChargeReceived, EventTypeAccountPaymentReceived, EventTypeAccountRecalled,
+		EventTypeAccountWrittenOff, EventTypeAccountRecoveryReceived:
+		return true
+	default:
+		return false
+	}
+}
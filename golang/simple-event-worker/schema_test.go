@@ -0,0 +1,152 @@
+package simpleeventworker_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchema_NewSchema_CustomErrors(t *testing.T) {
+	subtests := []struct {
+		name       string
+		upcasters  []event.Upcaster
+		wantReason string
+	}{
+		{
+			name:       "NotCanonical",
+			upcasters:  []event.Upcaster{{Type: "ChargePosted", Version: 2, To: "ChargePosted"}},
+			wantReason: `"ChargePosted" is not a canonical event type`,
+		},
+		{
+			name:       "VersionNotPositive",
+			upcasters:  []event.Upcaster{{Type: "ChargePosted", Version: 0, To: event.EventTypeAccountChargeReceived}},
+			wantReason: "version must be positive",
+		},
+		{
+			name:       "Canonical",
+			upcasters:  []event.Upcaster{{Type: event.EventTypeAccountPaymentReceived, Version: 1, To: event.EventTypeAccountChargeReceived}},
+			wantReason: "canonical event types cannot be upcast",
+		},
+		{
+			name: "Duplicate",
+			upcasters: []event.Upcaster{
+				{Type: "ChargePosted", Version: 2, To: event.EventTypeAccountChargeReceived},
+				{Type: "ChargePosted", Version: 2, To: event.EventTypeAccountPaymentReceived},
+			},
+			wantReason: "duplicate upcaster",
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := event.NewSchema(tt.upcasters...)
+			var e *event.ErrInvalidUpcaster
+			require.ErrorAs(t, err, &e)
+			assert.Equal(t, tt.wantReason, e.Reason)
+		})
+	}
+}
+
+func TestSchema_DefaultSchema(t *testing.T) {
+	// Both generations of charges can be in the same file.
+	input := `[
+		{"Type":"AccountCreated","AccountID":"Jack","Payload":{"Balance":50}},
+		{"Type":"AccountChargeReceived","AccountID":"Jack","Payload":{"Amount":25}},
+		{"Type":"ChargePosted","Version":2,"AccountID":"Jack","Payload":{"Amount":10,"Reference":"inv-1"}}
+	]`
+
+	for _, fast := range []bool{false, true} {
+		for _, strict := range []bool{false, true} {
+			s := event.NewService(event.WithFastDecoder(fast), event.WithStrictSchema(strict))
+
+			events, err := s.ParseEvents(strings.NewReader(input))
+			require.NoError(t, err, "fast: %v, strict: %v", fast, strict)
+			assert.Equal(t, []event.Event{
+				event.NewAccountCreatedEvent("Jack", 50),
+				event.NewChargeEvent("Jack", 25),
+				event.NewChargeEvent("Jack", 10).ForInvoice("inv-1"),
+			}, events, "fast: %v, strict: %v", fast, strict)
+		}
+	}
+
+	// Event.UnmarshalJSON upcasts with the default schema too.
+	var e event.Event
+	require.NoError(t, json.Unmarshal([]byte(`{"Type":"ChargePosted","Version":2,"AccountID":"Jack","Payload":{"Amount":10}}`), &e))
+	assert.Equal(t, event.NewChargeEvent("Jack", 10), e)
+}
+
+func TestSchema_ParseEvents_CustomErrors(t *testing.T) {
+	subtests := []struct {
+		name    string
+		input   string
+		strict  bool
+		wantErr error
+	}{
+		{
+			name:    "ErrUnsupportedEventVersion Alias",
+			input:   `[{"Type":"ChargePosted","AccountID":"Jack","Payload":{"Amount":10}}]`,
+			wantErr: &event.ErrUnsupportedEventVersion{Type: event.EventTypeChargePosted, Version: 1},
+		},
+		{
+			name:    "ErrUnsupportedEventVersion Canonical",
+			input:   `[{"Type":"AccountCreated","Version":2,"AccountID":"Jack","Payload":{"Balance":50}}]`,
+			wantErr: &event.ErrUnsupportedEventVersion{Type: event.EventTypeAccountCreated, Version: 2},
+		},
+		{
+			name:    "ErrUnsupportedEventType",
+			input:   `[{"Type":"ChargeVoided","Version":2,"AccountID":"Jack","Payload":{"Amount":10}}]`,
+			wantErr: &event.ErrUnsupportedEventType{Type: "ChargeVoided"},
+		},
+		{
+			name:    "ErrUnknownPayloadField",
+			input:   `[{"Type":"ChargePosted","Version":2,"AccountID":"Jack","Payload":{"Amount":10,"Note":"x"}}]`,
+			strict:  true,
+			wantErr: &event.ErrUnknownPayloadField{Field: "Note"},
+		},
+	}
+
+	for _, tt := range subtests {
+		for _, fast := range []bool{false, true} {
+			t.Run(tt.name, func(t *testing.T) {
+				s := event.NewService(event.WithFastDecoder(fast), event.WithStrictSchema(tt.strict))
+
+				_, err := s.ParseEvents(strings.NewReader(tt.input))
+				assert.Equal(t, tt.wantErr, err, "fast: %v", fast)
+			})
+		}
+	}
+}
+
+func TestSchema_WithSchema(t *testing.T) {
+	schema, err := event.NewSchema(
+		event.Upcaster{Type: "PaymentPosted", Version: 1, To: event.EventTypeAccountPaymentReceived, Rename: map[string]string{"Value": "Amount"}},
+		event.Upcaster{Type: event.EventTypeAccountCreated, Version: 2, To: event.EventTypeAccountCreated, Drop: []string{"Currency"}},
+	)
+	require.NoError(t, err)
+
+	input := `[
+		{"Type":"AccountCreated","Version":2,"AccountID":"Jack","Payload":{"Balance":50,"Currency":"PHP"}},
+		{"Type":"AccountCreated","AccountID":"Jen","Payload":{"Balance":10}},
+		{"Type":"PaymentPosted","AccountID":"Jack","Payload":{"Value":25}}
+	]`
+
+	for _, fast := range []bool{false, true} {
+		s := event.NewService(event.WithSchema(schema), event.WithFastDecoder(fast), event.WithStrictSchema(true))
+
+		events, err := s.ParseEvents(strings.NewReader(input))
+		require.NoError(t, err, "fast: %v", fast)
+		assert.Equal(t, []event.Event{
+			event.NewAccountCreatedEvent("Jack", 50),
+			event.NewAccountCreatedEvent("Jen", 10),
+			event.NewPaymentEvent("Jack", 25),
+		}, events, "fast: %v", fast)
+
+		// The schema replaces the default one.
+		_, err = s.ParseEvents(strings.NewReader(`[{"Type":"ChargePosted","Version":2,"AccountID":"Jack","Payload":{"Amount":10}}]`))
+		assert.Equal(t, &event.ErrUnsupportedEventType{Type: event.EventTypeChargePosted}, err, "fast: %v", fast)
+	}
+}
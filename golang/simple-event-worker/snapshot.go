@@ -0,0 +1,105 @@
+package simpleeventworker
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+)
+
+// snapshot is the serialized state of accounts written by WriteSnapshot. Accounts keep their open items and credit, so
+// that events applied on top of the snapshot allocate payments and age open items as if they had been processed along
+// with the events before it.
+type snapshot struct {
+	Accounts []accountJSON `json:"Accounts"`
+	Chain    *ChainHead    `json:"Chain,omitempty"`
+	// Pending holds the events parked until they take effect, so a later run on top of the snapshot applies them.
+	Pending []Event `json:"Pending,omitempty"`
+}
+
+// ChainHead records the HashChain of the events the accounts of a snapshot were folded from.
+type ChainHead struct {
+	Events int    `json:"Events"`
+	Head   string `json:"Head"`
+}
+
+// SnapshotOption configures optional contents of a snapshot written by WriteSnapshot.
+type SnapshotOption func(*snapshot)
+
+// WithSnapshotHashChain records the length and head of `chain` alongside the accounts.
+func WithSnapshotHashChain(chain *HashChain) SnapshotOption {
+	return func(s *snapshot) {
+		s.Chain = &ChainHead{Events: chain.Len(), Head: chain.Head()}
+	}
+}
+
+// WithSnapshotPendingQueue records the events of `queue` alongside the accounts.
+func WithSnapshotPendingQueue(queue *PendingQueue) SnapshotOption {
+	return func(s *snapshot) {
+		s.Pending = queue.Events()
+	}
+}
+
+// WriteSnapshot serializes the state of accounts, e.g. as returned by ProcessEvents, to w as JSON.
+// Accounts are sorted by ID, so the same state always produces the same output.
+func WriteSnapshot(w io.Writer, accounts map[string]Account, opts ...SnapshotOption) error {
+	s := snapshot{Accounts: make([]accountJSON, 0, len(accounts))}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	for id, account := range accounts {
+		a := newAccountJSON(account)
+		a.ID = id
+		s.Accounts = append(s.Accounts, a)
+	}
+	sort.Slice(s.Accounts, func(i, j int) bool {
+		return s.Accounts[i].ID < s.Accounts[j].ID
+	})
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(s)
+}
+
+// ReadSnapshot deserializes the state of accounts written by WriteSnapshot.
+// The status of each account must agree with its balance, unless it is recalled, and so must its open items and credit.
+func ReadSnapshot(r io.Reader) (map[string]Account, error) {
+	s := snapshot{}
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return nil, err
+	}
+
+	accounts := make(map[string]Account, len(s.Accounts))
+	for _, a := range s.Accounts {
+		if _, ok := accounts[a.ID]; ok {
+			return nil, &ErrInvalidSnapshotAccount{AccountID: a.ID, Reason: "duplicate ID"}
+		}
+
+		account, err := a.account()
+		if err != nil {
+			return nil, &ErrInvalidSnapshotAccount{AccountID: a.ID, Reason: err.Reason}
+		}
+		accounts[a.ID] = account
+	}
+
+	return accounts, nil
+}
+
+// ReadSnapshotChainHead reads the head of the HashChain recorded in a snapshot, or nil if there is none.
+func ReadSnapshotChainHead(r io.Reader) (*ChainHead, error) {
+	s := snapshot{}
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return nil, err
+	}
+
+	return s.Chain, nil
+}
+
+// ReadSnapshotPendingQueue reads the pending events recorded in a snapshot into a queue, empty if there are none.
+func ReadSnapshotPendingQueue(r io.Reader) (*PendingQueue, error) {
+	s := snapshot{}
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return nil, err
+	}
+
+	return NewPendingQueue(s.Pending...), nil
+}
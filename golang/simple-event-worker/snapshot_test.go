@@ -0,0 +1,160 @@
+package simpleeventworker_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot_WriteSnapshot(t *testing.T) {
+	recalled := event.NewAccount("Olivia", 50)
+	recalled.Recall()
+
+	accounts := map[string]event.Account{
+		"Jen":    *event.NewAccount("Jen", -10),
+		"Olivia": *recalled,
+		"Jack":   *event.NewAccount("Jack", 0),
+		"Lucy":   *writtenOffAccount("Lucy", 80),
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, event.WriteSnapshot(&buf, accounts))
+	assert.JSONEq(t, `{"Accounts":[
+		{"ID":"Jack","Status":"Settled","Balance":0},
+		{"ID":"Jen","Status":"Overpaid","Balance":-10},
+		{"ID":"Lucy","Status":"WrittenOff","Balance":0,"WrittenOff":80},
+		{"ID":"Olivia","Status":"Recalled","Balance":50}
+	]}`, buf.String())
+
+	got, err := event.ReadSnapshot(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, accounts, got)
+}
+
+func TestSnapshot_OpenItems(t *testing.T) {
+	invoicedAt := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	accounts, err := event.NewService().ProcessEvents([]event.Event{
+		event.NewAccountCreatedEvent("Jack", 50),
+		event.NewChargeEvent("Jack", 25).ForInvoice("INV-1").At(invoicedAt),
+		event.NewPaymentEvent("Jack", 60),
+		event.NewAccountCreatedEvent("Jen", 0),
+		event.NewPaymentEvent("Jen", 10),
+	})
+	require.NoError(t, err)
+
+	// Jen's credit is the one NewAccount makes of her balance, so it is left out.
+	var buf bytes.Buffer
+	require.NoError(t, event.WriteSnapshot(&buf, accounts))
+	assert.JSONEq(t, `{"Accounts":[
+		{"ID":"Jack","Status":"Outstanding","Balance":15,"OpenItems":[{"Invoice":"INV-1","Amount":25,"Remaining":15,"ChargedAt":"2026-03-01T00:00:00Z"}]},
+		{"ID":"Jen","Status":"Overpaid","Balance":-10}
+	]}`, buf.String())
+
+	got, err := event.ReadSnapshot(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, accounts, got)
+}
+
+func TestSnapshot_ReadSnapshot_CustomErrors(t *testing.T) {
+	subtests := []struct {
+		name  string
+		input string
+		want  error
+	}{
+		{
+			name:  "EmptyID",
+			input: `{"Accounts":[{"ID":"","Status":"Settled","Balance":0}]}`,
+			want:  &event.ErrInvalidSnapshotAccount{AccountID: "", Reason: "empty ID"},
+		},
+		{
+			name:  "DuplicateID",
+			input: `{"Accounts":[{"ID":"Jack","Status":"Settled","Balance":0},{"ID":"Jack","Status":"Settled","Balance":0}]}`,
+			want:  &event.ErrInvalidSnapshotAccount{AccountID: "Jack", Reason: "duplicate ID"},
+		},
+		{
+			name:  "StatusDoesNotMatchBalance",
+			input: `{"Accounts":[{"ID":"Jack","Status":"Settled","Balance":10}]}`,
+			want:  &event.ErrInvalidSnapshotAccount{AccountID: "Jack", Reason: `status "Settled" does not match balance 10`},
+		},
+		{
+			name:  "OpenItemsDoNotMatchBalance",
+			input: `{"Accounts":[{"ID":"Jack","Status":"Outstanding","Balance":10,"OpenItems":[{"Amount":25,"Remaining":5}]}]}`,
+			want:  &event.ErrInvalidSnapshotAccount{AccountID: "Jack", Reason: "open items and credit add up to 5, not balance 10"},
+		},
+		{
+			name:  "OpenItemOverpaid",
+			input: `{"Accounts":[{"ID":"Jack","Status":"Outstanding","Balance":10,"OpenItems":[{"Amount":5,"Remaining":10}]}]}`,
+			want:  &event.ErrInvalidSnapshotAccount{AccountID: "Jack", Reason: "open item with remaining amount 10 of 5"},
+		},
+		{
+			name:  "NegativeCredit",
+			input: `{"Accounts":[{"ID":"Jack","Status":"Outstanding","Balance":10,"Credit":-10}]}`,
+			want:  &event.ErrInvalidSnapshotAccount{AccountID: "Jack", Reason: "negative credit -10"},
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := event.ReadSnapshot(strings.NewReader(tt.input))
+			assert.EqualError(t, err, tt.want.Error())
+		})
+	}
+}
+
+func TestSnapshot_WithSnapshotHashChain(t *testing.T) {
+	accounts := map[string]event.Account{"Jack": *event.NewAccount("Jack", 50)}
+	chain := event.NewHashChain()
+	require.NoError(t, chain.Append(event.NewAccountCreatedEvent("Jack", 50)))
+
+	var buf bytes.Buffer
+	assert.NoError(t, event.WriteSnapshot(&buf, accounts, event.WithSnapshotHashChain(chain)))
+	data := buf.Bytes()
+	assert.JSONEq(t, `{
+		"Accounts":[{"ID":"Jack","Status":"Outstanding","Balance":50}],
+		"Chain":{"Events":1,"Head":"`+chain.Head()+`"}
+	}`, string(data))
+
+	got, err := event.ReadSnapshot(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, accounts, got)
+
+	head, err := event.ReadSnapshotChainHead(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, &event.ChainHead{Events: 1, Head: chain.Head()}, head)
+
+	// Snapshots written without a chain have no head.
+	buf.Reset()
+	assert.NoError(t, event.WriteSnapshot(&buf, accounts))
+	head, err = event.ReadSnapshotChainHead(&buf)
+	assert.NoError(t, err)
+	assert.Nil(t, head)
+}
+
+func TestSnapshot_WithSnapshotPendingQueue(t *testing.T) {
+	accounts := map[string]event.Account{"Jack": *event.NewAccount("Jack", 50)}
+	pending := []event.Event{event.NewChargeEvent("Jack", 25).ForInvoice("INV-1").EffectiveFrom(april1)}
+
+	var buf bytes.Buffer
+	assert.NoError(t, event.WriteSnapshot(&buf, accounts, event.WithSnapshotPendingQueue(event.NewPendingQueue(pending...))))
+	data := buf.Bytes()
+	assert.JSONEq(t, `{
+		"Accounts":[{"ID":"Jack","Status":"Outstanding","Balance":50}],
+		"Pending":[{"Type":"AccountChargeReceived","AccountID":"Jack","EffectiveAt":"2026-04-01T00:00:00Z","Payload":{"Amount":25,"Invoice":"INV-1"}}]
+	}`, string(data))
+
+	queue, err := event.ReadSnapshotPendingQueue(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, pending, queue.Events())
+
+	// Snapshots written without a queue have no pending events.
+	buf.Reset()
+	assert.NoError(t, event.WriteSnapshot(&buf, accounts, event.WithSnapshotPendingQueue(event.NewPendingQueue())))
+	queue, err = event.ReadSnapshotPendingQueue(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, queue.Len())
+}
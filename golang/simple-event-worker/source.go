@@ -0,0 +1,93 @@
+package simpleeventworker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"maps"
+)
+
+// Source is a pull-based supplier of events. It generalizes the io.Reader accepted by ParseEvents
+// to inputs that deliver events in batches, e.g. a topic of a message bus.
+type Source interface {
+	// Poll returns the next batch of events in order. It returns io.EOF once there are no more events to read.
+	Poll(ctx context.Context) ([]Event, error)
+	// Commit acknowledges that the batch returned by the last Poll was processed successfully.
+	// A batch that is never committed is delivered again to the next reader of the source.
+	Commit(ctx context.Context) error
+}
+
+// ReaderSource is a Source delivering all the events of an io.Reader as a single batch.
+type ReaderSource struct {
+	service *EventService
+	r       io.Reader
+	read    bool
+}
+
+// NewReaderSource reads `r` with the ParseEvents of `service`, so its schema mode and validation apply.
+func NewReaderSource(service *EventService, r io.Reader) *ReaderSource {
+	return &ReaderSource{
+		service: service,
+		r:       r,
+	}
+}
+
+func (s *ReaderSource) Poll(_ context.Context) ([]Event, error) {
+	if s.read {
+		return nil, io.EOF
+	}
+	s.read = true
+
+	return s.service.ParseEvents(s.r)
+}
+
+// Commit does nothing. An io.Reader has no position to acknowledge.
+func (s *ReaderSource) Commit(_ context.Context) error {
+	return nil
+}
+
+// ProcessSource folds the batches of a source on top of `initial`, the accounts the batches committed so far produced,
+// e.g. read back from a snapshot. A source resuming from its committed positions, like a restarted consumer, must be
+// given those accounts, and a source read from its beginning no accounts, as with ProcessEvents.
+// `initial` is never modified.
+func (s *EventService) ProcessSource(ctx context.Context, initial map[string]Account, source Source) (map[string]Account, error) {
+	accounts := maps.Clone(initial)
+	if accounts == nil {
+		accounts = map[string]Account{}
+	}
+
+	for batch := 0; ; batch++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		events, err := source.Poll(ctx)
+		if errors.Is(err, io.EOF) {
+			return accounts, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		b, err := s.applyBatch(accounts, events)
+		if err != nil {
+			s.rollbackOutbox()
+			return nil, err
+		}
+
+		// A batch that is not committed is delivered again, so it must leave the pending queue and the hash chain as they were.
+		if err := source.Commit(ctx); err != nil {
+			s.rollbackOutbox()
+			return nil, err
+		}
+		s.commitBatch(b)
+		// The batch will not be delivered again, so a CommitOutbox may release its domain events.
+		if err := s.commitOutbox(); err != nil {
+			return nil, err
+		}
+		s.logger.Debug("batch committed", slog.Int("batch", batch), slog.Int("events", len(events)))
+
+		accounts = b.Accounts
+	}
+}
@@ -0,0 +1,156 @@
+package simpleeventworker_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSource delivers the given batches in order and records which ones were committed.
+type fakeSource struct {
+	batches   [][]event.Event
+	polled    int
+	committed []int
+	commitErr error
+}
+
+func (f *fakeSource) Poll(_ context.Context) ([]event.Event, error) {
+	if f.polled == len(f.batches) {
+		return nil, io.EOF
+	}
+	f.polled++
+
+	return f.batches[f.polled-1], nil
+}
+
+func (f *fakeSource) Commit(_ context.Context) error {
+	if f.commitErr != nil {
+		return f.commitErr
+	}
+	f.committed = append(f.committed, f.polled-1)
+
+	return nil
+}
+
+func TestEvent_ApplyEvents(t *testing.T) {
+	s := event.NewService()
+
+	initial, err := s.ProcessEvents([]event.Event{
+		{Type: event.EventTypeAccountCreated, AccountID: "Jack", Payload: &event.EventPayloadAccountCreated{Balance: 50}},
+	})
+	assert.NoError(t, err)
+
+	t.Run("Success", func(t *testing.T) {
+		got, err := s.ApplyEvents(initial, []event.Event{
+			{Type: event.EventTypeAccountChargeReceived, AccountID: "Jack", Payload: &event.EventPayloadAccountTransactionReceived{Amount: 25}},
+			{Type: event.EventTypeAccountCreated, AccountID: "Jen", Payload: &event.EventPayloadAccountCreated{Balance: 10}},
+		})
+		assert.NoError(t, err)
+		jack := event.NewAccount("Jack", 50)
+		_ = jack.RecordTransaction(25)
+		assert.Equal(t, map[string]event.Account{
+			"Jack": *jack,
+			"Jen":  *event.NewAccount("Jen", 10),
+		}, got)
+		assert.Equal(t, map[string]event.Account{"Jack": *event.NewAccount("Jack", 50)}, initial)
+	})
+
+	t.Run("ErrAccountAlreadyExists", func(t *testing.T) {
+		_, err := s.ApplyEvents(initial, []event.Event{
+			{Type: event.EventTypeAccountChargeReceived, AccountID: "Jack", Payload: &event.EventPayloadAccountTransactionReceived{Amount: 25}},
+			{Type: event.EventTypeAccountCreated, AccountID: "Jack", Payload: &event.EventPayloadAccountCreated{Balance: 10}},
+		})
+		assert.EqualError(t, err, (&event.ErrAccountAlreadyExists{AccountID: "Jack"}).Error())
+		assert.Equal(t, map[string]event.Account{"Jack": *event.NewAccount("Jack", 50)}, initial)
+	})
+
+	t.Run("NilAccounts", func(t *testing.T) {
+		got, err := s.ApplyEvents(nil, []event.Event{})
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]event.Account{}, got)
+	})
+}
+
+func TestEvent_ProcessSource(t *testing.T) {
+	created := event.Event{Type: event.EventTypeAccountCreated, AccountID: "Jack", Payload: &event.EventPayloadAccountCreated{Balance: 50}}
+	charged := event.Event{Type: event.EventTypeAccountChargeReceived, AccountID: "Jack", Payload: &event.EventPayloadAccountTransactionReceived{Amount: 25}}
+	orphan := event.Event{Type: event.EventTypeAccountChargeReceived, AccountID: "Jen", Payload: &event.EventPayloadAccountTransactionReceived{Amount: 25}}
+	jack := event.NewAccount("Jack", 50)
+	_ = jack.RecordTransaction(25)
+	_ = jack.RecordTransaction(25)
+
+	subtests := []struct {
+		name          string
+		initial       map[string]event.Account
+		source        *fakeSource
+		want          map[string]event.Account
+		wantErr       error
+		wantCommitted []int
+	}{
+		{
+			name:          "CommitsEachBatch",
+			source:        &fakeSource{batches: [][]event.Event{{created}, {charged, charged}}},
+			want:          map[string]event.Account{"Jack": *jack},
+			wantCommitted: []int{0, 1},
+		},
+		{
+			// A source resuming after committed batches starts from the accounts they produced.
+			name:          "StartsFromInitialAccounts",
+			initial:       map[string]event.Account{"Jack": *event.NewAccount("Jack", 50)},
+			source:        &fakeSource{batches: [][]event.Event{{charged, charged}}},
+			want:          map[string]event.Account{"Jack": *jack},
+			wantCommitted: []int{0},
+		},
+		{
+			name:          "NoBatches",
+			source:        &fakeSource{},
+			want:          map[string]event.Account{},
+			wantCommitted: nil,
+		},
+		{
+			name:          "FailedBatchIsNotCommitted",
+			source:        &fakeSource{batches: [][]event.Event{{created}, {charged, orphan}, {charged}}},
+			wantErr:       &event.ErrAccountDoesNotExist{AccountID: "Jen"},
+			wantCommitted: []int{0},
+		},
+		{
+			name:          "CommitError",
+			source:        &fakeSource{batches: [][]event.Event{{created}}, commitErr: errors.New("broker unavailable")},
+			wantErr:       errors.New("broker unavailable"),
+			wantCommitted: nil,
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := event.NewService().ProcessSource(context.Background(), tt.initial, tt.source)
+			if tt.wantErr != nil {
+				assert.EqualError(t, err, tt.wantErr.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			}
+			assert.Equal(t, tt.wantCommitted, tt.source.committed)
+		})
+	}
+}
+
+func TestEvent_ReaderSource(t *testing.T) {
+	s := event.NewService()
+	source := event.NewReaderSource(s, strings.NewReader(`[
+		{"Type":"AccountCreated","AccountID":"Jack","Payload":{"Balance":50}},
+		{"Type":"AccountPaymentReceived","AccountID":"Jack","Payload":{"Amount":60}}
+	]`))
+
+	got, err := s.ProcessSource(context.Background(), nil, source)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]event.Account{"Jack": *event.NewAccount("Jack", -10)}, got)
+
+	_, err = source.Poll(context.Background())
+	assert.ErrorIs(t, err, io.EOF)
+}
@@ -0,0 +1,17 @@
+package simpleeventworker
+
+// WithTenant pins the service to the accounts of a tenant. By default, the service only accepts events without a TenantID.
+//
+// A service folds a single namespace of AccountIDs, so an event of another tenant is rejected with ErrTenantMismatch
+// rather than applied to an account of the same ID. The tenant package splits inputs of several tenants.
+func WithTenant(tenantID string) ServiceOption {
+	return func(s *EventService) {
+		s.tenantID = tenantID
+	}
+}
+
+// InTenant returns a copy of the event for the account of a tenant, e.g. `NewChargeEvent("Jack", 25).InTenant("acme")`.
+func (e Event) InTenant(tenantID string) Event {
+	e.TenantID = tenantID
+	return e
+}
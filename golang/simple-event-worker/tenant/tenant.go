@@ -0,0 +1,183 @@
+// Package tenant processes the events of several tenants (lending partners) in one input, each in its own namespace
+// of accounts, since AccountIDs are only unique within a tenant.
+//
+// The events of each tenant are applied by their own EventService, pinned to the tenant with event.WithTenant,
+// so a failing event only fails the batch of its tenant. Inputs without TenantIDs are a single DefaultTenantID.
+// The indexes of events in logs and domain events are indexes within the events of their tenant.
+package tenant
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+)
+
+// DefaultTenantID is the tenant of events without a TenantID.
+const DefaultTenantID = ""
+
+type ErrTenantFailed struct {
+	TenantID string
+	Err      error
+}
+
+func (e *ErrTenantFailed) Error() string {
+	return fmt.Sprintf(`events of tenant "%s" failed: %s`, e.TenantID, e.Err)
+}
+
+func (e *ErrTenantFailed) Unwrap() error {
+	return e.Err
+}
+
+// Option configures a Processor.
+type Option func(*Processor)
+
+// WithDefaultPolicy sets the options of the EventService of every tenant, e.g. instrumentation shared by all tenants.
+func WithDefaultPolicy(opts ...event.ServiceOption) Option {
+	return func(p *Processor) {
+		p.defaults = opts
+	}
+}
+
+// WithPolicy sets the options of the EventService of a tenant, e.g. its own outbox.
+// They are applied after the default policy, so they take precedence over it.
+func WithPolicy(tenantID string, opts ...event.ServiceOption) Option {
+	return func(p *Processor) {
+		p.policies[tenantID] = opts
+	}
+}
+
+// Processor applies the events of each tenant with the EventService of its policy.
+type Processor struct {
+	defaults []event.ServiceOption
+	policies map[string][]event.ServiceOption
+}
+
+func NewProcessor(opts ...Option) *Processor {
+	p := &Processor{policies: map[string][]event.ServiceOption{}}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+// Service returns the EventService applying the events of a tenant.
+func (p *Processor) Service(tenantID string) *event.EventService {
+	opts := slices.Concat(p.defaults, p.policies[tenantID], []event.ServiceOption{event.WithTenant(tenantID)})
+	return event.NewService(opts...)
+}
+
+// Result holds the accounts of each tenant after applying a batch.
+type Result struct {
+	// Accounts holds the accounts of each tenant by ID. A tenant whose events failed keeps its initial accounts.
+	Accounts map[string]map[string]event.Account
+	// Errors holds the error of each tenant whose events failed.
+	Errors map[string]error
+	// Applied holds the events applied to the accounts of each tenant whose events succeeded, as in event.Batch.
+	Applied map[string][]event.Event
+}
+
+// ProcessEvents is ApplyEvents starting from no accounts.
+func (p *Processor) ProcessEvents(events []event.Event) *Result {
+	return p.ApplyEvents(nil, events)
+}
+
+// ApplyEvents applies the events of each tenant on top of its accounts in `initial`, which are never modified.
+// If an event of a tenant fails, none of the events of that tenant are applied, but the other tenants are unaffected.
+// Tenants are applied one at a time, in the order of their IDs.
+func (p *Processor) ApplyEvents(initial map[string]map[string]event.Account, events []event.Event) *Result {
+	r := &Result{
+		Accounts: make(map[string]map[string]event.Account, len(initial)),
+		Errors:   map[string]error{},
+		Applied:  map[string][]event.Event{},
+	}
+	maps.Copy(r.Accounts, initial)
+
+	batches := Split(events)
+	for _, tenantID := range slices.Sorted(maps.Keys(batches)) {
+		before := r.Accounts[tenantID]
+		if before == nil {
+			before = map[string]event.Account{}
+		}
+
+		b, err := p.Service(tenantID).ApplyBatch(before, batches[tenantID])
+		if err != nil {
+			r.Errors[tenantID] = err
+			r.Accounts[tenantID] = before
+			continue
+		}
+		r.Accounts[tenantID] = b.Accounts
+		r.Applied[tenantID] = b.Applied
+	}
+
+	return r
+}
+
+// Split groups events by tenant, keeping the order of the events of each tenant.
+func Split(events []event.Event) map[string][]event.Event {
+	batches := map[string][]event.Event{}
+	for _, e := range events {
+		batches[e.TenantID] = append(batches[e.TenantID], e)
+	}
+
+	return batches
+}
+
+// Tenants returns the IDs of the tenants of the result, sorted.
+func (r *Result) Tenants() []string {
+	return slices.Sorted(maps.Keys(r.Accounts))
+}
+
+// Err returns an error wrapping the ErrTenantFailed of each tenant whose events failed, or nil if none did.
+func (r *Result) Err() error {
+	errs := make([]error, 0, len(r.Errors))
+	for _, tenantID := range slices.Sorted(maps.Keys(r.Errors)) {
+		errs = append(errs, &ErrTenantFailed{TenantID: tenantID, Err: r.Errors[tenantID]})
+	}
+
+	return errors.Join(errs...)
+}
+
+// tenantEscaper escapes the "/" of a TenantID, and the "%" of its escapes, so the first "/" of an AccountKey always
+// ends the tenant. tenantUnescaper reverses it.
+var (
+	tenantEscaper   = strings.NewReplacer("%", "%25", "/", "%2F")
+	tenantUnescaper = strings.NewReplacer("%25", "%", "%2F", "/")
+)
+
+// AccountKey identifies an account across tenants as "<tenant>/<account>", or as its AccountID in DefaultTenantID,
+// so single-tenant accounts keep their key. Keys never collide: a "/" or "%" of the TenantID is escaped as "%2F" or
+// "%25", and an AccountID of DefaultTenantID containing a "/" is keyed as "/<account>". SplitAccountKey reverses it.
+func AccountKey(tenantID, accountID string) string {
+	if tenantID == DefaultTenantID && !strings.Contains(accountID, "/") {
+		return accountID
+	}
+
+	return tenantEscaper.Replace(tenantID) + "/" + accountID
+}
+
+// SplitAccountKey returns the TenantID and AccountID of a key built by AccountKey.
+func SplitAccountKey(key string) (tenantID, accountID string) {
+	escaped, accountID, ok := strings.Cut(key, "/")
+	if !ok {
+		return DefaultTenantID, key
+	}
+
+	return tenantUnescaper.Replace(escaped), accountID
+}
+
+// All returns the accounts of every tenant by AccountKey.
+func (r *Result) All() map[string]event.Account {
+	all := map[string]event.Account{}
+	for tenantID, accounts := range r.Accounts {
+		for id, account := range accounts {
+			all[AccountKey(tenantID, id)] = account
+		}
+	}
+
+	return all
+}
@@ -0,0 +1,217 @@
+package tenant_test
+
+import (
+	"errors"
+	"testing"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/nogurenn/assorted-programs/simple-event-worker/tenant"
+	"github.com/stretchr/testify/assert"
+)
+
+// balances summarizes the accounts of each tenant by their balance.
+func balances(accounts map[string]map[string]event.Account) map[string]map[string]int {
+	got := map[string]map[string]int{}
+	for tenantID, tenantAccounts := range accounts {
+		got[tenantID] = map[string]int{}
+		for id, account := range tenantAccounts {
+			got[tenantID][id] = account.Balance()
+		}
+	}
+
+	return got
+}
+
+func TestTenant_ProcessEvents(t *testing.T) {
+	subtests := []struct {
+		name   string
+		events []event.Event
+		want   map[string]map[string]int
+		errs   map[string]error
+	}{
+		{
+			name: "SingleTenant",
+			events: []event.Event{
+				event.NewAccountCreatedEvent("Jack", 50),
+				event.NewChargeEvent("Jack", 25),
+			},
+			want: map[string]map[string]int{tenant.DefaultTenantID: {"Jack": 75}},
+			errs: map[string]error{},
+		},
+		{
+			name: "SameAccountIDInTenants",
+			events: []event.Event{
+				event.NewAccountCreatedEvent("Jack", 50).InTenant("acme"),
+				event.NewAccountCreatedEvent("Jack", 10).InTenant("globex"),
+				event.NewPaymentEvent("Jack", 20).InTenant("acme"),
+				event.NewAccountCreatedEvent("Jack", 5),
+			},
+			want: map[string]map[string]int{
+				tenant.DefaultTenantID: {"Jack": 5},
+				"acme":                 {"Jack": 30},
+				"globex":               {"Jack": 10},
+			},
+			errs: map[string]error{},
+		},
+		{
+			name: "FailingTenantIsIsolated",
+			events: []event.Event{
+				event.NewAccountCreatedEvent("Jack", 50).InTenant("acme"),
+				event.NewAccountCreatedEvent("Jack", 10).InTenant("globex"),
+				event.NewChargeEvent("Jen", 20).InTenant("acme"),
+				event.NewPaymentEvent("Jack", 10).InTenant("globex"),
+			},
+			want: map[string]map[string]int{
+				"acme":   {},
+				"globex": {"Jack": 0},
+			},
+			errs: map[string]error{"acme": &event.ErrAccountDoesNotExist{AccountID: "Jen"}},
+		},
+		{
+			name:   "NoEvents",
+			events: []event.Event{},
+			want:   map[string]map[string]int{},
+			errs:   map[string]error{},
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tenant.NewProcessor().ProcessEvents(tt.events)
+			assert.Equal(t, tt.want, balances(got.Accounts))
+			assert.Equal(t, tt.errs, got.Errors)
+		})
+	}
+}
+
+func TestTenant_ApplyEvents(t *testing.T) {
+	p := tenant.NewProcessor()
+	first := p.ProcessEvents([]event.Event{
+		event.NewAccountCreatedEvent("Jack", 50).InTenant("acme"),
+		event.NewAccountCreatedEvent("Jack", 10).InTenant("globex"),
+	})
+	assert.NoError(t, first.Err())
+
+	second := p.ApplyEvents(first.Accounts, []event.Event{
+		event.NewChargeEvent("Jack", 5).InTenant("acme"),
+		event.NewChargeEvent("Jen", 5).InTenant("globex"),
+	})
+
+	assert.Equal(t, map[string]map[string]int{
+		"acme":   {"Jack": 55},
+		"globex": {"Jack": 10},
+	}, balances(second.Accounts))
+	assert.Equal(t, map[string]map[string]int{
+		"acme":   {"Jack": 50},
+		"globex": {"Jack": 10},
+	}, balances(first.Accounts), "the initial accounts are left untouched")
+}
+
+type fakeOutbox struct {
+	published []event.DomainEvent
+}
+
+func (f *fakeOutbox) Publish(events []event.DomainEvent) error {
+	f.published = append(f.published, events...)
+	return nil
+}
+
+func TestTenant_WithPolicy(t *testing.T) {
+	shared, acme := &fakeOutbox{}, &fakeOutbox{}
+	p := tenant.NewProcessor(
+		tenant.WithDefaultPolicy(event.WithOutbox(shared)),
+		tenant.WithPolicy("acme", event.WithOutbox(acme)),
+	)
+
+	r := p.ProcessEvents([]event.Event{
+		event.NewAccountCreatedEvent("Jack", 50).InTenant("acme"),
+		event.NewAccountCreatedEvent("Jack", 50).InTenant("globex"),
+		event.NewRecalledEvent("Jack").InTenant("acme"),
+		event.NewRecalledEvent("Jack").InTenant("globex"),
+	})
+	assert.NoError(t, r.Err())
+
+	assert.Len(t, acme.published, 1)
+	assert.Equal(t, "acme", acme.published[0].TenantID)
+	assert.Len(t, shared.published, 1)
+	assert.Equal(t, "globex", shared.published[0].TenantID)
+}
+
+func TestTenant_Result(t *testing.T) {
+	r := tenant.NewProcessor().ProcessEvents([]event.Event{
+		event.NewAccountCreatedEvent("Jack", 50),
+		event.NewAccountCreatedEvent("Jack", 10).InTenant("globex"),
+		event.NewRecalledEvent("Jen").InTenant("acme"),
+		event.NewRecalledEvent("Jen").InTenant("initech"),
+	})
+
+	assert.Equal(t, []string{tenant.DefaultTenantID, "acme", "globex", "initech"}, r.Tenants())
+
+	all := r.All()
+	assert.Len(t, all, 2)
+	jack, globexJack := all["Jack"], all["globex/Jack"]
+	assert.Equal(t, 50, jack.Balance())
+	assert.Equal(t, 10, globexJack.Balance())
+	assert.Equal(t, map[string][]event.Event{
+		tenant.DefaultTenantID: {event.NewAccountCreatedEvent("Jack", 50)},
+		"globex":               {event.NewAccountCreatedEvent("Jack", 10).InTenant("globex")},
+	}, r.Applied, "failing tenants applied no events")
+
+	err := r.Err()
+	var tenantErr *tenant.ErrTenantFailed
+	assert.True(t, errors.As(err, &tenantErr))
+	assert.Equal(t, "acme", tenantErr.TenantID)
+	assert.EqualError(t, err, `events of tenant "acme" failed: account with ID does not exist: "Jen"`+"\n"+
+		`events of tenant "initech" failed: account with ID does not exist: "Jen"`)
+	assert.ErrorIs(t, err, tenantErr.Err)
+
+	assert.NoError(t, tenant.NewProcessor().ProcessEvents(nil).Err())
+}
+
+func TestTenant_AccountKey(t *testing.T) {
+	subtests := []struct {
+		name      string
+		tenantID  string
+		accountID string
+		want      string
+	}{
+		{name: "DefaultTenant", tenantID: tenant.DefaultTenantID, accountID: "Jack", want: "Jack"},
+		{name: "Tenant", tenantID: "acme", accountID: "Jack", want: "acme/Jack"},
+		{name: "SlashInAccountID", tenantID: "acme", accountID: "b/Jack", want: "acme/b/Jack"},
+		{name: "SlashInTenantID", tenantID: "acme/b", accountID: "Jack", want: "acme%2Fb/Jack"},
+		{name: "PercentInTenantID", tenantID: "acme%2Fb", accountID: "Jack", want: "acme%252Fb/Jack"},
+		{name: "SlashInDefaultAccountID", tenantID: tenant.DefaultTenantID, accountID: "acme/Jack", want: "/acme/Jack"},
+	}
+
+	keys := map[string]bool{}
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := tenant.AccountKey(tt.tenantID, tt.accountID)
+			assert.Equal(t, tt.want, key)
+
+			tenantID, accountID := tenant.SplitAccountKey(key)
+			assert.Equal(t, tt.tenantID, tenantID)
+			assert.Equal(t, tt.accountID, accountID)
+		})
+		keys[tenant.AccountKey(tt.tenantID, tt.accountID)] = true
+	}
+	assert.Len(t, keys, len(subtests))
+}
+
+func TestTenant_Result_AmbiguousIDs(t *testing.T) {
+	// The same "<tenant>/<account>" spelled across the tenant and the account are different accounts.
+	r := tenant.NewProcessor().ProcessEvents([]event.Event{
+		event.NewAccountCreatedEvent("acme/Jack", 10),
+		event.NewAccountCreatedEvent("Jack", 20).InTenant("acme"),
+		event.NewAccountCreatedEvent("b/Jack", 30).InTenant("acme"),
+		event.NewAccountCreatedEvent("Jack", 40).InTenant("acme/b"),
+	})
+	assert.NoError(t, r.Err())
+
+	all := r.All()
+	assert.Len(t, all, 4)
+	for key, account := range all {
+		tenantID, accountID := tenant.SplitAccountKey(key)
+		assert.Equal(t, r.Accounts[tenantID][accountID], account)
+	}
+}
@@ -0,0 +1,68 @@
+package simpleeventworker_test
+
+import (
+	"testing"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenant_InTenant(t *testing.T) {
+	e := event.NewChargeEvent("Jack", 25)
+
+	got := e.InTenant("acme")
+	assert.Equal(t, event.Event{Type: event.EventTypeAccountChargeReceived, TenantID: "acme", AccountID: "Jack", Payload: e.Payload}, got)
+	assert.Empty(t, e.TenantID, "the original event is left untouched")
+}
+
+func TestTenant_WithTenant(t *testing.T) {
+	subtests := []struct {
+		name   string
+		tenant string
+		events []event.Event
+		want   map[string]string
+		err    error
+	}{
+		{
+			name:   "SameTenant",
+			tenant: "acme",
+			events: []event.Event{
+				event.NewAccountCreatedEvent("Jack", 50).InTenant("acme"),
+				event.NewPaymentEvent("Jack", 50).InTenant("acme"),
+			},
+			want: map[string]string{"Jack": event.AccountStatusSettled},
+		},
+		{
+			name:   "ErrTenantMismatch OtherTenant",
+			tenant: "acme",
+			events: []event.Event{
+				event.NewAccountCreatedEvent("Jack", 50).InTenant("acme"),
+				event.NewPaymentEvent("Jack", 50).InTenant("globex"),
+			},
+			err: &event.ErrTenantMismatch{TenantID: "acme", EventTenantID: "globex", AccountID: "Jack"},
+		},
+		{
+			name:   "ErrTenantMismatch NoTenant",
+			tenant: "acme",
+			events: []event.Event{event.NewAccountCreatedEvent("Jack", 50)},
+			err:    &event.ErrTenantMismatch{TenantID: "acme", EventTenantID: "", AccountID: "Jack"},
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			accounts, err := event.NewService(event.WithTenant(tt.tenant)).ProcessEvents(tt.events)
+			if tt.err != nil {
+				assert.Equal(t, tt.err, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			got := map[string]string{}
+			for id, account := range accounts {
+				got[id] = account.Status()
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
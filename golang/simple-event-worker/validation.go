@@ -0,0 +1,34 @@
+package simpleeventworker
+
+// Validate checks an event against the rules its producers are expected to follow:
+//   - An event is always associated to an AccountID.
+//   - An event carries the payload type of its event type. Events built by hand may not.
+//   - Initial balances, charges, payments and recoveries are never negative. The event type decides the sign of a transaction.
+//
+// Validate does not check the order of events. That is the job of ProcessEvents.
+func (e Event) Validate() error {
+	if e.AccountID == "" {
+		return &ErrEmptyAccountID{Type: e.Type}
+	}
+
+	switch e.Type {
+	case EventTypeAccountCreated:
+		p, err := e.accountCreatedPayload()
+		if err != nil {
+			return err
+		}
+		if p.Balance < 0 {
+			return &ErrInvalidAmount{AccountID: e.AccountID, Field: EventPayloadFieldBalance, Amount: p.Balance}
+		}
+	case EventTypeAccountChargeReceived, EventTypeAccountPaymentReceived, EventTypeAccountRecoveryReceived:
+		p, err := e.transactionPayload()
+		if err != nil {
+			return err
+		}
+		if p.Amount < 0 {
+			return &ErrInvalidAmount{AccountID: e.AccountID, Field: EventPayloadFieldAmount, Amount: p.Amount}
+		}
+	}
+
+	return nil
+}
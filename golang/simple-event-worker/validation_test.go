@@ -0,0 +1,82 @@
+package simpleeventworker_test
+
+import (
+	"testing"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvent_Validate_Success(t *testing.T) {
+	subtests := []struct {
+		name  string
+		event event.Event
+	}{
+		{
+			name:  "AccountCreated",
+			event: event.Event{Type: event.EventTypeAccountCreated, AccountID: "Jack", Payload: &event.EventPayloadAccountCreated{Balance: 50}},
+		},
+		{
+			name:  "AccountCreated ZeroBalance",
+			event: event.Event{Type: event.EventTypeAccountCreated, AccountID: "Jack", Payload: &event.EventPayloadAccountCreated{Balance: 0}},
+		},
+		{
+			name:  "AccountChargeReceived",
+			event: event.Event{Type: event.EventTypeAccountChargeReceived, AccountID: "Jack", Payload: &event.EventPayloadAccountTransactionReceived{Amount: 25}},
+		},
+		{
+			name:  "AccountPaymentReceived",
+			event: event.Event{Type: event.EventTypeAccountPaymentReceived, AccountID: "Jack", Payload: &event.EventPayloadAccountTransactionReceived{Amount: 25}},
+		},
+		{
+			name:  "AccountRecalled",
+			event: event.Event{Type: event.EventTypeAccountRecalled, AccountID: "Jack", Payload: nil},
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.NoError(t, tt.event.Validate())
+		})
+	}
+}
+
+func TestEvent_Validate_CustomErrors(t *testing.T) {
+	subtests := []struct {
+		name  string
+		event event.Event
+		want  error
+	}{
+		{
+			name:  "ErrEmptyAccountID",
+			event: event.Event{Type: event.EventTypeAccountRecalled, AccountID: "", Payload: nil},
+			want:  &event.ErrEmptyAccountID{Type: event.EventTypeAccountRecalled},
+		},
+		{
+			name:  "ErrInvalidAmount AccountCreated",
+			event: event.Event{Type: event.EventTypeAccountCreated, AccountID: "Jack", Payload: &event.EventPayloadAccountCreated{Balance: -50}},
+			want:  &event.ErrInvalidAmount{AccountID: "Jack", Field: event.EventPayloadFieldBalance, Amount: -50},
+		},
+		{
+			name:  "ErrInvalidAmount AccountChargeReceived",
+			event: event.Event{Type: event.EventTypeAccountChargeReceived, AccountID: "Jack", Payload: &event.EventPayloadAccountTransactionReceived{Amount: -25}},
+			want:  &event.ErrInvalidAmount{AccountID: "Jack", Field: event.EventPayloadFieldAmount, Amount: -25},
+		},
+		{
+			name:  "ErrInvalidAmount AccountPaymentReceived",
+			event: event.Event{Type: event.EventTypeAccountPaymentReceived, AccountID: "Jack", Payload: &event.EventPayloadAccountTransactionReceived{Amount: -25}},
+			want:  &event.ErrInvalidAmount{AccountID: "Jack", Field: event.EventPayloadFieldAmount, Amount: -25},
+		},
+		{
+			name:  "ErrInvalidPayload",
+			event: event.Event{Type: event.EventTypeAccountChargeReceived, AccountID: "Jack", Payload: nil},
+			want:  &event.ErrInvalidPayload{Type: event.EventTypeAccountChargeReceived, AccountID: "Jack", Payload: "<nil>"},
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.event.Validate())
+		})
+	}
+}
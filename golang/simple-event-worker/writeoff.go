@@ -0,0 +1,52 @@
+package simpleeventworker
+
+// WriteOff gives up on collecting the debt of a recalled account: its remaining positive balance moves to its
+// written-off amount, and the account becomes WrittenOff, with a zero balance. A negative balance, i.e. credit owed to
+// the customer, is not debt, so it stays. Like a recalled account, a written-off account takes no charges or payments,
+// only recoveries. See RecordRecovery.
+func (a *Account) WriteOff() error {
+	if !a.IsRecalled() {
+		return &ErrCannotWriteOffAccount{AccountID: a.ID, Status: a.status}
+	}
+
+	amount := max(a.balance, 0)
+	a.writtenOff += amount
+	a.balance -= amount
+	// The written-off debt is every open item, since an account has either open items or credit.
+	a.items = nil
+	a.status = AccountStatusWrittenOff
+
+	return nil
+}
+
+// RecordRecovery records a payment against the written-off amount of the account, e.g. collected by an agency
+// after the write-off. It leaves the balance as it is, and cannot exceed what is left to recover.
+func (a *Account) RecordRecovery(amount int) error {
+	if !a.IsWrittenOff() {
+		return &ErrCannotRecoverAccount{AccountID: a.ID, Status: a.status}
+	}
+	if amount < 0 {
+		return &ErrInvalidAmount{AccountID: a.ID, Field: EventPayloadFieldAmount, Amount: amount}
+	}
+	if unrecovered := a.writtenOff - a.recovered; amount > unrecovered {
+		return &ErrRecoveryExceedsWriteOff{AccountID: a.ID, Amount: amount, Unrecovered: unrecovered}
+	}
+
+	a.recovered += amount
+
+	return nil
+}
+
+func (a *Account) IsWrittenOff() bool {
+	return a.status == AccountStatusWrittenOff
+}
+
+// WrittenOff returns the debt written off the account, recovered or not, or 0 if it is not written off.
+func (a *Account) WrittenOff() int {
+	return a.writtenOff
+}
+
+// Recovered returns the part of the written-off amount of the account recovered so far.
+func (a *Account) Recovered() int {
+	return a.recovered
+}
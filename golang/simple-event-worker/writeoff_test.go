@@ -0,0 +1,202 @@
+package simpleeventworker_test
+
+import (
+	"testing"
+
+	event "github.com/nogurenn/assorted-programs/simple-event-worker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func recalledAccount(id string, balance int) *event.Account {
+	account := event.NewAccount(id, balance)
+	account.Recall()
+
+	return account
+}
+
+func writtenOffAccount(id string, balance int) *event.Account {
+	account := recalledAccount(id, balance)
+	_ = account.WriteOff()
+
+	return account
+}
+
+func TestAccount_WriteOff(t *testing.T) {
+	subtests := []struct {
+		name           string
+		account        *event.Account
+		wantBalance    int
+		wantWrittenOff int
+	}{
+		{name: "Outstanding", account: recalledAccount("Jack", 80), wantBalance: 0, wantWrittenOff: 80},
+		{name: "Settled", account: recalledAccount("Jack", 0), wantBalance: 0, wantWrittenOff: 0},
+		// Credit owed to the customer is not debt, so it is not written off.
+		{name: "Overpaid", account: recalledAccount("Jack", -10), wantBalance: -10, wantWrittenOff: 0},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, tt.account.WriteOff())
+			assert.Equal(t, event.AccountStatusWrittenOff, tt.account.Status())
+			assert.True(t, tt.account.IsWrittenOff())
+			assert.False(t, tt.account.IsRecalled())
+			assert.Equal(t, tt.wantBalance, tt.account.Balance())
+			assert.Equal(t, tt.wantWrittenOff, tt.account.WrittenOff())
+			assert.Empty(t, tt.account.OpenItems())
+
+			// A written-off account stays written off, and takes no charges or payments.
+			tt.account.Recall()
+			assert.True(t, tt.account.IsWrittenOff())
+			assert.Equal(t, &event.ErrCannotTransactWithRecalledAccount{AccountID: "Jack"}, tt.account.RecordTransaction(10))
+			assert.Equal(t, &event.ErrCannotTransactWithRecalledAccount{AccountID: "Jack"}, tt.account.RecordTransaction(-10))
+		})
+	}
+}
+
+func TestAccount_WriteOff_CustomErrors(t *testing.T) {
+	subtests := []struct {
+		name    string
+		account *event.Account
+		want    error
+	}{
+		{name: "Outstanding", account: event.NewAccount("Jack", 80), want: &event.ErrCannotWriteOffAccount{AccountID: "Jack", Status: event.AccountStatusOutstanding}},
+		{name: "AlreadyWrittenOff", account: writtenOffAccount("Jack", 80), want: &event.ErrCannotWriteOffAccount{AccountID: "Jack", Status: event.AccountStatusWrittenOff}},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := *tt.account
+			assert.Equal(t, tt.want, tt.account.WriteOff())
+			assert.Equal(t, before, *tt.account)
+		})
+	}
+}
+
+func TestAccount_RecordRecovery(t *testing.T) {
+	account := writtenOffAccount("Jack", 80)
+
+	require.NoError(t, account.RecordRecovery(30))
+	require.NoError(t, account.RecordRecovery(50))
+	assert.Equal(t, 80, account.Recovered())
+	assert.Equal(t, 80, account.WrittenOff())
+	assert.Equal(t, 0, account.Balance())
+	assert.Equal(t, event.AccountStatusWrittenOff, account.Status())
+}
+
+func TestAccount_RecordRecovery_CustomErrors(t *testing.T) {
+	subtests := []struct {
+		name    string
+		account *event.Account
+		amount  int
+		want    error
+	}{
+		{
+			name:    "Outstanding",
+			account: event.NewAccount("Jack", 80),
+			amount:  10,
+			want:    &event.ErrCannotRecoverAccount{AccountID: "Jack", Status: event.AccountStatusOutstanding},
+		},
+		{
+			// A recalled account is recovered once it is written off.
+			name:    "Recalled",
+			account: recalledAccount("Jack", 80),
+			amount:  10,
+			want:    &event.ErrCannotRecoverAccount{AccountID: "Jack", Status: event.AccountStatusRecalled},
+		},
+		{
+			name:    "ExceedsWriteOff",
+			account: writtenOffAccount("Jack", 80),
+			amount:  90,
+			want:    &event.ErrRecoveryExceedsWriteOff{AccountID: "Jack", Amount: 90, Unrecovered: 80},
+		},
+		{
+			name:    "NegativeAmount",
+			account: writtenOffAccount("Jack", 80),
+			amount:  -10,
+			want:    &event.ErrInvalidAmount{AccountID: "Jack", Field: event.EventPayloadFieldAmount, Amount: -10},
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.account.RecordRecovery(tt.amount))
+			assert.Zero(t, tt.account.Recovered())
+		})
+	}
+}
+
+func TestWriteOff_ProcessEvents(t *testing.T) {
+	outbox := &fakeOutbox{}
+	s := event.NewService(event.WithOutbox(outbox))
+
+	accounts, err := s.ProcessEvents([]event.Event{
+		event.NewAccountCreatedEvent("Jack", 50),
+		event.NewChargeEvent("Jack", 30).ForInvoice("INV-1"),
+		event.NewRecalledEvent("Jack"),
+		event.NewWrittenOffEvent("Jack"),
+		event.NewRecoveryEvent("Jack", 25),
+		// Recalling a written-off account leaves it written off.
+		event.NewRecalledEvent("Jack"),
+		event.NewRecoveryEvent("Jack", 15).ForInvoice("INV-1"),
+	})
+	require.NoError(t, err)
+
+	jack := accounts["Jack"]
+	assert.Equal(t, event.AccountStatusWrittenOff, jack.Status())
+	assert.Equal(t, 0, jack.Balance())
+	assert.Equal(t, 80, jack.WrittenOff())
+	assert.Equal(t, 40, jack.Recovered())
+
+	require.Len(t, outbox.published, 1)
+	assert.Equal(t, []event.DomainEvent{
+		{Type: event.DomainEventTypeAccountRecalledFinal, AccountID: "Jack", EventIndex: 2, FromStatus: event.AccountStatusOutstanding, ToStatus: event.AccountStatusRecalled, Balance: 80},
+		{Type: event.DomainEventTypeAccountWrittenOff, AccountID: "Jack", EventIndex: 3, FromStatus: event.AccountStatusRecalled, ToStatus: event.AccountStatusWrittenOff, Balance: 0, WrittenOff: 80},
+	}, outbox.published[0])
+}
+
+func TestWriteOff_ProcessEvents_CustomErrors(t *testing.T) {
+	subtests := []struct {
+		name   string
+		events []event.Event
+		want   error
+	}{
+		{
+			name:   "WriteOffOfActiveAccount",
+			events: []event.Event{event.NewAccountCreatedEvent("Jack", 50), event.NewWrittenOffEvent("Jack")},
+			want:   &event.ErrCannotWriteOffAccount{AccountID: "Jack", Status: event.AccountStatusOutstanding},
+		},
+		{
+			name:   "WriteOffOfMissingAccount",
+			events: []event.Event{event.NewWrittenOffEvent("Jack")},
+			want:   &event.ErrAccountDoesNotExist{AccountID: "Jack"},
+		},
+		{
+			name:   "RecoveryOfRecalledAccount",
+			events: []event.Event{event.NewAccountCreatedEvent("Jack", 50), event.NewRecalledEvent("Jack"), event.NewRecoveryEvent("Jack", 10)},
+			want:   &event.ErrCannotRecoverAccount{AccountID: "Jack", Status: event.AccountStatusRecalled},
+		},
+		{
+			name:   "RecoveryExceedsWriteOff",
+			events: []event.Event{event.NewAccountCreatedEvent("Jack", 50), event.NewRecalledEvent("Jack"), event.NewWrittenOffEvent("Jack"), event.NewRecoveryEvent("Jack", 60)},
+			want:   &event.ErrRecoveryExceedsWriteOff{AccountID: "Jack", Amount: 60, Unrecovered: 50},
+		},
+		{
+			name:   "PaymentOfWrittenOffAccount",
+			events: []event.Event{event.NewAccountCreatedEvent("Jack", 50), event.NewRecalledEvent("Jack"), event.NewWrittenOffEvent("Jack"), event.NewPaymentEvent("Jack", 10)},
+			want:   &event.ErrCannotTransactWithRecalledAccount{AccountID: "Jack"},
+		},
+		{
+			name:   "ErrInvalidPayload",
+			events: []event.Event{event.NewAccountCreatedEvent("Jack", 50), {Type: event.EventTypeAccountRecoveryReceived, AccountID: "Jack"}},
+			want:   &event.ErrInvalidPayload{Type: event.EventTypeAccountRecoveryReceived, AccountID: "Jack", Payload: "<nil>"},
+		},
+	}
+
+	for _, tt := range subtests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := event.NewService().ProcessEvents(tt.events)
+			assert.Equal(t, tt.want, err)
+		})
+	}
+}